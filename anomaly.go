@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Anomaly is a file left out of the comparison because its name is unsafe
+// to compare or display, as found in malicious or corrupted archives.
+type Anomaly struct {
+	Path   string `json:"path"` // quoted, with unprintable characters escaped
+	Side   string `json:"side"` // source or target
+	Reason string `json:"reason"`
+}
+
+// pathAnomaly returns why a relative path is unsafe, or an empty string.
+func pathAnomaly(name string) string {
+	if !utf8.ValidString(name) {
+		return "invalid UTF-8"
+	}
+	if reason := traversalAnomaly(name); reason != "" {
+		return reason
+	}
+	for _, r := range name {
+		switch {
+		case unicode.IsControl(r):
+			return "control character"
+		case unicode.Is(unicode.Bidi_Control, r):
+			// Reorders the displayed name, hiding its real extension
+			return "bidirectional control character"
+		}
+	}
+	return ""
+}
+
+// traversalAnomaly returns why an archive entry name would escape the
+// directory it is extracted to, or an empty string. Both slashes count as
+// separators, since either one is a separator on Windows.
+func traversalAnomaly(name string) string {
+	slashed := strings.ReplaceAll(name, "\\", "/")
+	if strings.HasPrefix(slashed, "/") || (len(name) >= 2 && name[1] == ':' && unicode.IsLetter(rune(name[0]))) {
+		return "absolute path"
+	}
+	for _, elem := range strings.Split(slashed, "/") {
+		if elem == ".." {
+			return "path traversal"
+		}
+	}
+	return ""
+}
+
+// safeJoin joins dir and an entry name taken from an archive or a remote
+// listing, failing if the result would not be inside dir.
+func safeJoin(dir, name string) (string, error) {
+	if reason := traversalAnomaly(name); reason != "" {
+		return "", fmt.Errorf("unsafe name %s: %s", strconv.Quote(name), reason)
+	}
+	return filepath.Join(dir, name), nil
+}
+
+// checkPath records an anomaly for an unsafe path and reports whether the
+// path is safe to compare.
+func checkPath(result *ComparisonResult, name, side string) bool {
+	reason := pathAnomaly(name)
+	if reason == "" {
+		return true
+	}
+	result.Anomalies = append(result.Anomalies, Anomaly{Path: strconv.Quote(name), Side: side, Reason: reason})
+	return false
+}
+
+func sortAnomalies(anomalies []Anomaly) {
+	sort.Slice(anomalies, func(i, j int) bool {
+		if anomalies[i].Path != anomalies[j].Path {
+			return anomalies[i].Path < anomalies[j].Path
+		}
+		return anomalies[i].Side < anomalies[j].Side
+	})
+}
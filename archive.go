@@ -0,0 +1,157 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// archiveFormat identifies how the entries of a nested archive are read.
+type archiveFormat int
+
+const (
+	notArchive archiveFormat = iota
+	zipArchive
+	tarArchive
+	tarGzArchive
+)
+
+// detectArchiveFormat classifies a file by its name. Zip-based formats such
+// as jar or whl are read as plain zip archives.
+func detectArchiveFormat(name string) archiveFormat {
+	name = strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		return tarGzArchive
+	case strings.HasSuffix(name, ".tar"):
+		return tarArchive
+	}
+	switch path.Ext(name) {
+	case ".zip", ".jar", ".war", ".whl", ".nupkg":
+		return zipArchive
+	}
+	return notArchive
+}
+
+// archiveContentsEqual reports whether two archives contain the same set of
+// entries with the same contents. Entry order, timestamps, ownership
+// (uid/gid), permissions and compression settings are all ignored, so
+// archives rebuilt from identical content compare as equal. Archives
+// exceeding limits are not read.
+func archiveContentsEqual(file1, file2 sourceFile, limits ArchiveLimits) bool {
+	format := detectArchiveFormat(file1.name)
+	if format == notArchive {
+		return false
+	}
+
+	entries1, err := readArchiveEntries(file1, format, limits)
+	if err != nil {
+		return false
+	}
+	entries2, err := readArchiveEntries(file2, format, limits)
+	if err != nil {
+		return false
+	}
+
+	if len(entries1) != len(entries2) {
+		return false
+	}
+	for name, content := range entries1 {
+		if other, ok := entries2[name]; !ok || !bytes.Equal(content, other) {
+			return false
+		}
+	}
+	return true
+}
+
+// readArchiveEntries returns the regular file entries of an archive keyed by
+// their names. Archives with entries escaping the archive root, through
+// ".." or absolute names, or exceeding the archive limits are rejected.
+func readArchiveEntries(file sourceFile, format archiveFormat, limits ArchiveLimits) (map[string][]byte, error) {
+	content, err := readFileContent(file)
+	if err != nil {
+		return nil, err
+	}
+
+	budget := newArchiveBudget(file.String(), limits)
+	switch format {
+	case zipArchive:
+		return readZipEntries(content, budget)
+	case tarGzArchive:
+		zr, err := gzip.NewReader(bytes.NewReader(content))
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		return readTarEntries(&guardedReader{r: zr, budget: budget, compressed: int64(len(content))}, budget)
+	default:
+		return readTarEntries(bytes.NewReader(content), budget)
+	}
+}
+
+func readZipEntries(content []byte, budget *archiveBudget) (map[string][]byte, error) {
+	r, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return nil, err
+	}
+	if err := checkZipLimits(budget.archive, r, budget.limits); err != nil {
+		return nil, err
+	}
+
+	entries := make(map[string][]byte)
+	for _, f := range r.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		if reason := traversalAnomaly(f.Name); reason != "" {
+			return nil, fmt.Errorf("unsafe entry name %s: %s", strconv.Quote(f.Name), reason)
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, err
+		}
+		entries[f.Name] = data
+	}
+	return entries, nil
+}
+
+func readTarEntries(r io.Reader, budget *archiveBudget) (map[string][]byte, error) {
+	tr := tar.NewReader(r)
+	entries := make(map[string][]byte)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		if reason := traversalAnomaly(hdr.Name); reason != "" {
+			return nil, fmt.Errorf("unsafe entry name %s: %s", strconv.Quote(hdr.Name), reason)
+		}
+		if err := budget.add(hdr.Name, hdr.Size, -1); err != nil {
+			return nil, err
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, err
+		}
+		// Some tools write "./name", others "name"
+		entries[strings.TrimPrefix(hdr.Name, "./")] = data
+	}
+	return entries, nil
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"archive/zip"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adnsv/gitparator/testsupport"
+)
+
+func TestCompareZipTargets(t *testing.T) {
+	tests := []struct {
+		name      string
+		configure func(t *testing.T, config *Config)
+	}{
+		{"two zips", func(t *testing.T, config *Config) {
+			config.SourceZip = testsupport.Zip(t, "project-main/", sourceFiles)
+			config.TargetZip = testsupport.Zip(t, "project-1.0/", targetFiles)
+		}},
+		{"strip components", func(t *testing.T, config *Config) {
+			config.SourceDir = testsupport.Dir(t, sourceFiles)
+			config.TargetZip = testsupport.Zip(t, "dist/project-1.0/", targetFiles)
+			config.ZipStripComponents = 2
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := compareFixtures(t, func(config *Config) {
+				tt.configure(t, config)
+			})
+			if got := classified(result); !reflect.DeepEqual(got, wantClassified) {
+				t.Errorf("classified = %v, want %v", got, wantClassified)
+			}
+		})
+	}
+}
+
+func TestCompareArchiveBytes(t *testing.T) {
+	read := func(path string) []byte {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return content
+	}
+	source := read(testsupport.Zip(t, "", sourceFiles))
+	target := read(testsupport.Zip(t, "", targetFiles))
+
+	config := defaultConfig(t)
+	result, err := compareArchiveBytes(source, target, &config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := classified(result); !reflect.DeepEqual(got, wantClassified) {
+		t.Errorf("classified = %v, want %v", got, wantClassified)
+	}
+}
+
+func TestCompareArchiveAnomalies(t *testing.T) {
+	target := testsupport.Files{"README.md": "# project\n", "../escape.txt": "outside\n"}
+
+	result := compareFixtures(t, func(config *Config) {
+		config.SourceDir = testsupport.Dir(t, testsupport.Files{"README.md": "# project\n"})
+		config.TargetZip = testsupport.Zip(t, "", target)
+	})
+	want := map[string][]string{"identical": {"README.md"}}
+	if got := classified(result); !reflect.DeepEqual(got, want) {
+		t.Errorf("classified = %v, want %v", got, want)
+	}
+	if len(result.Anomalies) != 1 || result.Anomalies[0].Path != `"../escape.txt"` || result.Anomalies[0].Side != "target" {
+		t.Errorf("anomalies = %+v, want ../escape.txt in the target", result.Anomalies)
+	}
+}
+
+// rebuiltZip writes files into a zip archive as another tool would, in
+// reverse order, with other timestamps and without compression.
+func rebuiltZip(t *testing.T, files testsupport.Files) string {
+	t.Helper()
+	name := filepath.Join(t.TempDir(), "rebuilt.zip")
+	f, err := os.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zw := zip.NewWriter(f)
+	var paths []string
+	for p := range files {
+		paths = append(paths, p)
+	}
+	for i := len(paths) - 1; i >= 0; i-- {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: paths[i], Method: zip.Store, Modified: time.Now()})
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(files[paths[i]]))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return name
+}
+
+func TestCompareArchiveMetadata(t *testing.T) {
+	content := testsupport.Files{"a.txt": "a\n", "b/c.txt": "c\n"}
+	read := func(path string) string {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+	source := testsupport.Files{"bundle.zip": read(testsupport.Zip(t, "", content))}
+	target := testsupport.Files{"bundle.zip": read(rebuiltZip(t, content))}
+
+	for _, ignore := range []bool{false, true} {
+		result := compareFixtures(t, func(config *Config) {
+			config.SourceDir = testsupport.Dir(t, source)
+			config.TargetPath = testsupport.Dir(t, target)
+			config.IgnoreArchiveMetadata = ignore
+		})
+		want := map[string][]string{"different": {"bundle.zip"}}
+		if ignore {
+			want = map[string][]string{"identical": {"bundle.zip"}}
+		}
+		if got := classified(result); !reflect.DeepEqual(got, want) {
+			t.Errorf("ignore_archive_metadata=%v: classified = %v, want %v", ignore, got, want)
+		}
+	}
+}
+
+func TestZipLimits(t *testing.T) {
+	archive := testsupport.Zip(t, "", testsupport.Files{"zeros.bin": strings.Repeat("\x00", 4<<20)})
+	r, err := zip.OpenReader(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	err = checkZipLimits(archive, &r.Reader, ArchiveLimits{MaxRatio: 100})
+	var limitErr *limitError
+	if !errors.As(err, &limitErr) || limitErr.limit != "max_ratio" {
+		t.Errorf("checking a zip bomb: %v", err)
+	}
+	if err := checkZipLimits(archive, &r.Reader, ArchiveLimits{}); err != nil {
+		t.Errorf("checking without limits: %v", err)
+	}
+}
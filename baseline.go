@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+)
+
+// Difference is a single path reported as differing between source and
+// target, together with the kind of difference.
+type Difference struct {
+	Path string `json:"path"`
+	Kind string `json:"kind"` // "different", "source-only" or "target-only"
+}
+
+// BaselineDrift lists the differences that appeared or disappeared since
+// the baseline result was recorded.
+type BaselineDrift struct {
+	Baseline   string       `json:"baseline"`
+	BaselineAt string       `json:"baseline_at,omitempty"`
+	New        []Difference `json:"new"`
+	Resolved   []Difference `json:"resolved"`
+}
+
+// loadBaseline reads a result previously written as JSON output.
+func loadBaseline(file string) (ComparisonResult, error) {
+	var baseline ComparisonResult
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return baseline, fmt.Errorf("error reading baseline: %w", err)
+	}
+	if err := json.Unmarshal(data, &baseline); err != nil {
+		return baseline, fmt.Errorf("error parsing baseline %s: %w", file, err)
+	}
+	return baseline, nil
+}
+
+// differences flattens the differing paths of a result. A path whose kind
+// changes between runs (e.g. from target-only to different) counts as both
+// resolved and new.
+func differences(result ComparisonResult) map[Difference]bool {
+	set := make(map[Difference]bool)
+	for _, p := range result.DifferentFiles {
+		set[Difference{p, "different"}] = true
+	}
+	for _, p := range result.SourceOnlyFiles {
+		set[Difference{p, "source-only"}] = true
+	}
+	for _, p := range result.TargetOnlyFiles {
+		set[Difference{p, "target-only"}] = true
+	}
+	return set
+}
+
+// computeDrift compares the current result with the baseline.
+func computeDrift(baselineFile string, baseline, current ComparisonResult) *BaselineDrift {
+	drift := &BaselineDrift{Baseline: baselineFile}
+	if !baseline.StartedAt.IsZero() {
+		drift.BaselineAt = baseline.StartedAt.Format(time.RFC3339)
+	}
+
+	before := differences(baseline)
+	after := differences(current)
+	for d := range after {
+		if !before[d] {
+			drift.New = append(drift.New, d)
+		}
+	}
+	for d := range before {
+		if !after[d] {
+			drift.Resolved = append(drift.Resolved, d)
+		}
+	}
+	sortDifferences(drift.New)
+	sortDifferences(drift.Resolved)
+	return drift
+}
+
+func sortDifferences(list []Difference) {
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Path != list[j].Path {
+			return list[i].Path < list[j].Path
+		}
+		return list[i].Kind < list[j].Kind
+	})
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/adnsv/gitparator/testsupport"
+)
+
+func TestCompareBaseline(t *testing.T) {
+	baseline := ComparisonResult{
+		DifferentFiles:  []string{"src/main.go"},
+		SourceOnlyFiles: []string{"src/fixed.go"},
+	}
+	data, err := json.Marshal(baseline)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(t.TempDir(), "baseline.json")
+	if err := os.WriteFile(file, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	result := compareFixtures(t, func(config *Config) {
+		config.SourceDir = testsupport.Dir(t, sourceFiles)
+		config.TargetPath = testsupport.Dir(t, targetFiles)
+		config.Baseline = file
+	})
+	want := &BaselineDrift{
+		Baseline: file,
+		New:      []Difference{{"docs/old.md", "target-only"}, {"src/new.go", "source-only"}},
+		Resolved: []Difference{{"src/fixed.go", "source-only"}},
+	}
+	if !reflect.DeepEqual(result.Drift, want) {
+		t.Errorf("drift = %+v, want %+v", result.Drift, want)
+	}
+}
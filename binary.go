@@ -0,0 +1,42 @@
+package main
+
+import "bytes"
+
+// binarySniffLen is how much of a file is inspected for NUL bytes, the same
+// heuristic git uses to tell binary files from text.
+const binarySniffLen = 8000
+
+func isBinary(content []byte) bool {
+	return bytes.IndexByte(content[:min(len(content), binarySniffLen)], 0) >= 0
+}
+
+// SizeDelta describes how the size of a binary file changed from the target
+// (the reference) to the source.
+type SizeDelta struct {
+	SourceSize int64   `json:"source_size"`
+	TargetSize int64   `json:"target_size"`
+	Delta      int64   `json:"delta"`   // source minus target, in bytes
+	Percent    float64 `json:"percent"` // relative to the target size; 0 for an empty target
+	Exceeds    bool    `json:"exceeds_threshold"`
+}
+
+// binarySizeDelta returns the size delta of a pair when either side is
+// binary, or nil for text. Growth beyond threshold percent is flagged; a
+// threshold of zero or less disables flagging.
+func binarySizeDelta(source, target fileSniff, threshold float64) *SizeDelta {
+	// UTF-16 text has NUL bytes too, but is diffed as text once transcoded
+	if source.encoding != encodingBinary && target.encoding != encodingBinary {
+		return nil
+	}
+
+	d := &SizeDelta{
+		SourceSize: source.size,
+		TargetSize: target.size,
+		Delta:      source.size - target.size,
+	}
+	if d.TargetSize > 0 {
+		d.Percent = float64(d.Delta) / float64(d.TargetSize) * 100
+	}
+	d.Exceeds = threshold > 0 && d.Delta > 0 && (d.TargetSize == 0 || d.Percent > threshold)
+	return d
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Outcomes of a pair comparison, as recorded in the cache.
+const (
+	outcomeIdentical    = "identical"
+	outcomeFormatting   = "formatting"
+	outcomeVersionBump  = "version-bump"
+	outcomeRecompressed = "recompressed"
+	outcomeDifferent    = "different"
+)
+
+// cacheVersion changes whenever the cache file layout does.
+const cacheVersion = 1
+
+// comparisonCache remembers the content hashes of files on disk and the
+// outcome of comparing pairs of contents, so that repeated runs over mostly
+// unchanged trees neither re-read nor re-diff unchanged files.
+//
+// File hashes are keyed by path and trusted while the size and
+// modification time are unchanged. Pair results are keyed by the contents
+// of both sides, so they also hold for fresh clones of the same commit,
+// and are dropped when the settings that affect the outcome change.
+type comparisonCache struct {
+	Version  int                   `json:"version"`
+	Settings string                `json:"settings"`
+	Files    map[string]cachedHash `json:"files"`
+	Pairs    map[string]cachedPair `json:"pairs"`
+
+	path string
+	used map[string]bool // entries looked up or stored during this run
+}
+
+type cachedHash struct {
+	Size    int64  `json:"size"`
+	ModTime int64  `json:"mtime"` // Unix nanoseconds
+	Hash    string `json:"hash"`
+}
+
+type cachedPair struct {
+	Outcome string `json:"outcome"`
+	Diff    string `json:"diff,omitempty"`
+}
+
+// loadComparisonCache reads the cache file at path. A missing file, or one
+// written by another version, starts an empty cache.
+func loadComparisonCache(path string, config *Config) (*comparisonCache, error) {
+	settings := cacheSettings(config)
+	c := &comparisonCache{path: path, used: make(map[string]bool)}
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("error reading cache file: %w", err)
+	}
+	if err == nil && json.Unmarshal(data, c) == nil && c.Version == cacheVersion {
+		if c.Settings != settings {
+			c.Pairs = nil
+		}
+	} else {
+		c.Files, c.Pairs = nil, nil
+	}
+	if c.Files == nil {
+		c.Files = make(map[string]cachedHash)
+	}
+	if c.Pairs == nil {
+		c.Pairs = make(map[string]cachedPair)
+	}
+	c.Version, c.Settings = cacheVersion, settings
+	return c, nil
+}
+
+// cacheSettings fingerprints the settings that decide whether two contents
+// compare equal and how their diff is rendered.
+func cacheSettings(config *Config) string {
+	data, _ := json.Marshal(struct {
+		Version               string
+		CompareStrategies     []CompareStrategy
+		CodeAware             bool
+		VersionBumps          bool
+		Recompressed          bool
+		SemanticCompare       bool
+		SubstituteTokens      []Substitution
+		NormalizeCmd          []FormatterRule
+		Normalize             []NormalizeRule
+		Plugins               []Plugin
+		CommandLimits         CommandLimits
+		IgnoreHunks           []string
+		IgnoreArchiveMetadata bool
+		ArchiveLimits         ArchiveLimits
+		RedactPatterns        []string
+		DetailedDiff          bool
+		SyntaxHighlight       bool
+		DiffContext           int
+		IntralineDiff         bool
+		DiffChunkLines        int
+		MaxDiffLines          int
+	}{
+		appVersion(),
+		config.CompareStrategies,
+		config.CodeAware,
+		config.VersionBumps,
+		config.Recompressed,
+		config.SemanticCompare,
+		config.SubstituteTokens,
+		config.NormalizeCmd,
+		config.Normalize,
+		config.Plugins,
+		config.CommandLimits,
+		config.IgnoreHunks,
+		config.IgnoreArchiveMetadata,
+		config.ArchiveLimits,
+		config.RedactPatterns,
+		config.DetailedDiff,
+		config.SyntaxHighlight,
+		config.DiffContext,
+		config.IntralineDiff,
+		config.DiffChunkLines,
+		config.MaxDiffLines,
+	})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// hash returns the content hash of a file, reading the file only if it is
+// not on disk or changed since it was cached.
+func (c *comparisonCache) hash(file sourceFile) (string, error) {
+	var key string
+	var info fs.FileInfo
+	if p, ok := file.diskPath(); ok {
+		abs, err := filepath.Abs(p)
+		if err != nil {
+			return "", err
+		}
+		if info, err = os.Stat(abs); err != nil {
+			return "", err
+		}
+		key = "file:" + toSlash(abs)
+		if h, ok := c.Files[key]; ok && h.Size == info.Size() && h.ModTime == info.ModTime().UnixNano() {
+			c.used[key] = true
+			return h.Hash, nil
+		}
+	}
+
+	r, _, err := openFileContent(file)
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return "", err
+	}
+	hash := hex.EncodeToString(h.Sum(nil))
+	if key != "" {
+		c.Files[key] = cachedHash{Size: info.Size(), ModTime: info.ModTime().UnixNano(), Hash: hash}
+		c.used[key] = true
+	}
+	return hash, nil
+}
+
+// pairKey returns the key of a pair's cached result and whether both
+// sides hold the same bytes. The paths are part of the key, since the
+// comparison strategy and the diff rendering depend on the file name.
+func (c *comparisonCache) pairKey(pair filePair) (key string, same, ok bool) {
+	sourceHash, err := c.hash(pair.SourceFile)
+	if err != nil {
+		return "", false, false
+	}
+	targetHash, err := c.hash(pair.TargetFile)
+	if err != nil {
+		return "", false, false
+	}
+	key = strings.Join([]string{"pair:" + toSlash(pair.SourcePath), toSlash(pair.TargetPath), sourceHash, targetHash}, "\x00")
+	return key, sourceHash == targetHash, true
+}
+
+// lookup returns the cached result of comparing a pair, and the key under
+// which to store the result otherwise. Pairs of equal contents are
+// identical without a cached result.
+func (c *comparisonCache) lookup(pair filePair) (key string, result cachedPair, ok bool) {
+	if c == nil {
+		return "", cachedPair{}, false
+	}
+	key, same, ok := c.pairKey(pair)
+	if !ok {
+		return "", cachedPair{}, false
+	}
+	if same {
+		result, ok = cachedPair{Outcome: outcomeIdentical}, true
+	} else {
+		result, ok = c.Pairs[key]
+	}
+	if ok {
+		c.used[key] = true
+	}
+	return key, result, ok
+}
+
+// store records the result of comparing a pair under the key returned by
+// lookup.
+func (c *comparisonCache) store(key string, result cachedPair) {
+	if c == nil || key == "" {
+		return
+	}
+	c.Pairs[key] = result
+	c.used[key] = true
+}
+
+// save writes the cache file, keeping only the entries of this run so the
+// file does not grow without bound.
+func (c *comparisonCache) save() error {
+	if c == nil {
+		return nil
+	}
+	for key := range c.Files {
+		if !c.used[key] {
+			delete(c.Files, key)
+		}
+	}
+	for key := range c.Pairs {
+		if !c.used[key] {
+			delete(c.Pairs, key)
+		}
+	}
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	if dir := filepath.Dir(c.path); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	// Write beside the cache file and rename, so an interrupted run never
+	// leaves a truncated cache behind
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, c.path)
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/adnsv/gitparator/testsupport"
+)
+
+func TestCompareCache(t *testing.T) {
+	source := testsupport.Dir(t, sourceFiles)
+	target := testsupport.Dir(t, targetFiles)
+	cacheFile := filepath.Join(t.TempDir(), "cache.json")
+	compare := func() ComparisonResult {
+		return compareFixtures(t, func(config *Config) {
+			config.SourceDir, config.TargetPath = source, target
+			config.CacheFile = cacheFile
+		})
+	}
+
+	// Equal contents are known from their hashes, even on the first run
+	first, second := compare(), compare()
+	if first.Stats.CacheHits != 1 || second.Stats.CacheHits != 2 {
+		t.Errorf("cache hits = %d then %d, want 1 then 2", first.Stats.CacheHits, second.Stats.CacheHits)
+	}
+	if got := classified(second); !reflect.DeepEqual(got, wantClassified) {
+		t.Errorf("classified from the cache = %v, want %v", got, wantClassified)
+	}
+	// Repeating an identical comparison yields the same ID
+	if first.Metadata.RunID == "" || first.Metadata.RunID != second.Metadata.RunID {
+		t.Errorf("run IDs = %q and %q, want the same", first.Metadata.RunID, second.Metadata.RunID)
+	}
+}
+
+func TestCacheSettings(t *testing.T) {
+	config := defaultConfig(t)
+	loose := cacheSettings(&config)
+	config.ArchiveLimits.MaxRatio /= 10
+	if cacheSettings(&config) == loose {
+		t.Error("results cached under looser archive limits are reused under stricter ones")
+	}
+}
@@ -0,0 +1,20 @@
+//go:build !(js && wasm)
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+func main() {
+	var config Config
+	if err := newRootCommand(&config).Execute(); err != nil {
+		fmt.Println(err)
+		if errors.As(err, new(usageError)) {
+			os.Exit(2)
+		}
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/go-git/go-git/v5"
+	gitconfig "github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+// prepareTarget makes targetDir hold the requested revision of the target
+// repository. With reuse enabled, an existing clone of the same URL is
+// fetched and checked out instead of being cloned again. Messages are
+// written to out and the sideband output of the remote to progress, which
+// may be nil.
+func prepareTarget(config *Config, targetDir string, out, progress io.Writer) error {
+	if config.ReuseClone {
+		repo, err := git.PlainOpen(targetDir)
+		if err == nil && originURL(repo) == config.TargetURL {
+			fmt.Fprintf(out, "Reusing clone in %s\n", targetDir)
+			return updateClone(repo, config, progress)
+		}
+	}
+	return cloneRepo(config, targetDir, progress)
+}
+
+func originURL(repo *git.Repository) string {
+	remote, err := repo.Remote("origin")
+	if err != nil || len(remote.Config().URLs) == 0 {
+		return ""
+	}
+	return remote.Config().URLs[0]
+}
+
+// updateClone fetches the requested reference (or all branches and tags when
+// single-branch mode is off) into an existing clone and checks it out.
+func updateClone(repo *git.Repository, config *Config, progress io.Writer) error {
+	depth := config.CloneDepth
+	if config.FullHistory {
+		depth = 0
+	}
+
+	refName, err := requestedRef(repo, config)
+	if err != nil {
+		return err
+	}
+
+	var refSpecs []gitconfig.RefSpec
+	if config.SingleBranch {
+		refSpecs = append(refSpecs, fetchSpec(refName))
+	} else {
+		refSpecs = append(refSpecs,
+			"+refs/heads/*:refs/remotes/origin/*",
+			"+refs/tags/*:refs/tags/*")
+	}
+
+	err = repo.Fetch(&git.FetchOptions{
+		RemoteName: "origin",
+		RefSpecs:   refSpecs,
+		Depth:      depth,
+		Progress:   progress,
+		Force:      true,
+	})
+	if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+		return fmt.Errorf("error fetching %s: %w", refName, err)
+	}
+
+	// Annotated tags are peeled to their commit by ResolveRevision
+	local := refName
+	if refName.IsBranch() {
+		local = plumbing.NewRemoteReferenceName("origin", refName.Short())
+	}
+	hash, err := repo.ResolveRevision(plumbing.Revision(local.String()))
+	if err != nil {
+		return fmt.Errorf("error resolving %s: %w", refName, err)
+	}
+
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return err
+	}
+	return worktree.Checkout(&git.CheckoutOptions{Hash: *hash, Force: true})
+}
+
+// requestedRef returns the branch or tag selected in config, or the remote's
+// default branch when neither is set.
+func requestedRef(repo *git.Repository, config *Config) (plumbing.ReferenceName, error) {
+	if config.Branch != "" {
+		return plumbing.NewBranchReferenceName(config.Branch), nil
+	}
+	if config.Tag != "" {
+		return plumbing.NewTagReferenceName(config.Tag), nil
+	}
+
+	remote, err := repo.Remote("origin")
+	if err != nil {
+		return "", err
+	}
+	refs, err := remote.List(&git.ListOptions{})
+	if err != nil {
+		return "", fmt.Errorf("error listing remote references: %w", err)
+	}
+	for _, ref := range refs {
+		if ref.Name() == plumbing.HEAD && ref.Type() == plumbing.SymbolicReference {
+			return ref.Target(), nil
+		}
+	}
+	return "", fmt.Errorf("cannot determine the default branch of %s", config.TargetURL)
+}
+
+func fetchSpec(refName plumbing.ReferenceName) gitconfig.RefSpec {
+	if refName.IsBranch() {
+		return gitconfig.RefSpec(fmt.Sprintf("+%s:%s", refName, plumbing.NewRemoteReferenceName("origin", refName.Short())))
+	}
+	return gitconfig.RefSpec(fmt.Sprintf("+%s:%s", refName, refName))
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/lexers"
+)
+
+// codeLexers maps the extensions of languages compared token by token to
+// their chroma lexer names.
+var codeLexers = map[string]string{
+	".go":  "go",
+	".js":  "javascript",
+	".mjs": "javascript",
+	".cjs": "javascript",
+	".jsx": "react",
+	".py":  "python",
+}
+
+// formattingOnlyDifference reports whether two versions of a supported
+// source file have the same token sequence, i.e. differ only in layout such
+// as spacing, line breaks or alignment (e.g. gofmt'd vs not).
+func formattingOnlyDifference(pair filePair) bool {
+	name, ok := codeLexers[strings.ToLower(path.Ext(toSlash(pair.SourcePath)))]
+	if !ok {
+		return false
+	}
+	lexer := lexers.Get(name)
+	if lexer == nil {
+		return false
+	}
+
+	source, err1 := readFileContent(pair.SourceFile)
+	target, err2 := readFileContent(pair.TargetFile)
+	if err1 != nil || err2 != nil {
+		return false
+	}
+	tokens1, ok1 := codeTokens(lexer, string(source), name == "python")
+	tokens2, ok2 := codeTokens(lexer, string(target), name == "python")
+	return ok1 && ok2 && slices.Equal(tokens1, tokens2)
+}
+
+// codeTokens returns the significant tokens of content. Whitespace is
+// dropped and runs of whitespace inside comments are collapsed. For
+// indentation-sensitive languages, line breaks and indentation levels are
+// kept, with indentation measured by rank so that re-indenting with a
+// different width still counts as formatting.
+func codeTokens(lexer chroma.Lexer, content string, indentSensitive bool) ([]string, bool) {
+	iterator, err := lexer.Tokenise(nil, content)
+	if err != nil {
+		return nil, false
+	}
+
+	const indentMarker = "\x00indent:"
+	var tokens []string
+	var widths []int
+	atLineStart := true
+	for _, token := range iterator.Tokens() {
+		value := token.Value
+		if token.Type == chroma.Error {
+			return nil, false
+		}
+		if strings.TrimSpace(value) == "" {
+			if !indentSensitive {
+				continue
+			}
+			if strings.Contains(value, "\n") {
+				if len(tokens) > 0 && tokens[len(tokens)-1] != "\n" {
+					tokens = append(tokens, "\n")
+				}
+				atLineStart = true
+				value = value[strings.LastIndex(value, "\n")+1:]
+			}
+			if atLineStart && value != "" {
+				width := indentWidth(value)
+				tokens = append(tokens, indentMarker+string(rune(width)))
+				widths = append(widths, width)
+				atLineStart = false
+			}
+			continue
+		}
+		atLineStart = false
+		if token.Type.InCategory(chroma.Comment) {
+			value = strings.Join(strings.Fields(value), " ")
+		}
+		tokens = append(tokens, token.Type.String()+"\x00"+value)
+	}
+
+	// Replace indentation widths by their rank among the widths in use
+	slices.Sort(widths)
+	widths = slices.Compact(widths)
+	for i, t := range tokens {
+		if width, ok := strings.CutPrefix(t, indentMarker); ok {
+			rank, _ := slices.BinarySearch(widths, int([]rune(width)[0]))
+			tokens[i] = indentMarker + string(rune(rank))
+		}
+	}
+	return tokens, true
+}
+
+func indentWidth(s string) int {
+	width := 0
+	for _, r := range s {
+		if r == '\t' {
+			width += 8 - width%8
+		} else {
+			width++
+		}
+	}
+	return width
+}
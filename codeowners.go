@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/adnsv/gitparator/gitignore"
+)
+
+// codeownersLocations are the places GitHub looks for a CODEOWNERS file,
+// in order.
+var codeownersLocations = []string{".github/CODEOWNERS", "CODEOWNERS", "docs/CODEOWNERS"}
+
+// codeOwners restricts a comparison to the paths owned by some owners.
+type codeOwners struct {
+	file   string
+	rules  *gitignore.Stack
+	owners [][]string // owners of each rule, by its index
+	wanted []string
+	prefix string // source subdirectory, as CODEOWNERS paths are relative to the repository root
+}
+
+// loadCodeOwners reads the CODEOWNERS file of the source repository, or
+// codeowners_file if set.
+func loadCodeOwners(config *Config) (*codeOwners, error) {
+	file := config.CodeownersFile
+	if file == "" {
+		if config.SourceZip != "" {
+			return nil, fmt.Errorf("--owned-by needs codeowners_file when comparing --source-zip")
+		}
+		for _, location := range codeownersLocations {
+			candidate := filepath.Join(config.SourceDir, filepath.FromSlash(location))
+			if _, err := os.Stat(candidate); err == nil {
+				file = candidate
+				break
+			}
+		}
+		if file == "" {
+			return nil, fmt.Errorf("--owned-by needs a CODEOWNERS file, but %s has none in .github/, the root, or docs/", config.SourceDir)
+		}
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("error reading CODEOWNERS: %w", err)
+	}
+	defer f.Close()
+
+	c := &codeOwners{file: file, rules: gitignore.NewStack("/"), wanted: config.OwnedBy}
+	c.rules.IgnoreCase = config.IgnoreCase
+	if config.SourceZip == "" && config.SourceSubdir != "" {
+		c.prefix = toSlash(filepath.Clean(config.SourceSubdir))
+	}
+	var patterns []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		var owners []string
+		for _, owner := range fields[1:] {
+			if strings.HasPrefix(owner, "#") {
+				break // trailing comment
+			}
+			owners = append(owners, owner)
+		}
+		patterns = append(patterns, fields[0])
+		c.owners = append(c.owners, owners)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading CODEOWNERS: %w", err)
+	}
+	c.rules.PushPatternsFrom(patterns, file)
+	return c, nil
+}
+
+// owns reports whether relPath, relative to the compared tree, is owned by
+// one of the wanted owners. The last matching rule decides, as on GitHub;
+// team and user names are matched case-insensitively.
+func (c *codeOwners) owns(relPath string) bool {
+	_, rule := c.rules.Match("/" + path.Join(c.prefix, relPath))
+	if rule.Pattern == "" {
+		return false
+	}
+	for _, owner := range c.owners[rule.Index] {
+		for _, wanted := range c.wanted {
+			if strings.EqualFold(owner, wanted) {
+				return true
+			}
+		}
+	}
+	return false
+}
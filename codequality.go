@@ -0,0 +1,86 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// codeQualityIssue is an entry of a GitLab Code Quality report, see
+// https://docs.gitlab.com/ee/ci/testing/code_quality.html#implement-a-custom-tool
+type codeQualityIssue struct {
+	Description string              `json:"description"`
+	CheckName   string              `json:"check_name"`
+	Fingerprint string              `json:"fingerprint"`
+	Severity    string              `json:"severity"`
+	Location    codeQualityLocation `json:"location"`
+}
+
+type codeQualityLocation struct {
+	Path  string           `json:"path"`
+	Lines codeQualityLines `json:"lines"`
+}
+
+type codeQualityLines struct {
+	Begin int `json:"begin"`
+}
+
+// codeQualityIssues maps each differing or missing file, and each possible
+// secret, to an issue so that merge requests show the drift.
+func codeQualityIssues(result ComparisonResult) []codeQualityIssue {
+	issues := []codeQualityIssue{}
+	add := func(check, severity, path string, line int, description string) {
+		sum := sha256.Sum256([]byte(fmt.Sprintf("%s\x00%s\x00%d\x00%s", check, path, line, description)))
+		issues = append(issues, codeQualityIssue{
+			Description: description,
+			CheckName:   check,
+			Fingerprint: hex.EncodeToString(sum[:16]),
+			Severity:    severity,
+			Location:    codeQualityLocation{Path: toSlash(path), Lines: codeQualityLines{Begin: max(line, 1)}},
+		})
+	}
+
+	for _, p := range result.DifferentFiles {
+		add("gitparator/different", "minor", p, 1, "File differs from the target")
+	}
+	for _, p := range result.FormattingOnlyFiles {
+		add("gitparator/formatting-only", "info", p, 1, "File differs from the target in formatting only")
+	}
+	for _, p := range result.VersionBumpFiles {
+		add("gitparator/version-bump", "info", p, 1, "File differs from the target by a version bump only")
+	}
+	for _, p := range result.RecompressedFiles {
+		add("gitparator/recompressed", "info", p, 1, "File differs from the target in compression only")
+	}
+	for _, p := range result.SourceOnlyFiles {
+		add("gitparator/source-only", "minor", p, 1, "File does not exist in the target")
+	}
+	for _, p := range result.TargetOnlyFiles {
+		add("gitparator/target-only", "major", p, 1, "File from the target is missing")
+	}
+	for _, a := range result.Anomalies {
+		add("gitparator/unsafe-path", "critical", a.Path, 1, fmt.Sprintf("File name in the %s is unsafe (%s) and was not compared", a.Side, a.Reason))
+	}
+	for _, v := range result.LineEndings {
+		add("gitparator/line-endings", "minor", v.Path, v.Line, fmt.Sprintf("File has %s line endings, the policy requires %s", v.Found, v.Expected))
+	}
+	for _, s := range result.Secrets {
+		add("gitparator/secret", "critical", s.Path, s.Line, fmt.Sprintf("Possible %s in %s file", s.Rule, s.Side))
+	}
+	return issues
+}
+
+// generateCodeQualityReport writes the result as a GitLab Code Quality
+// artifact.
+func generateCodeQualityReport(result ComparisonResult, outputFile string) error {
+	data, err := json.MarshalIndent(codeQualityIssues(result), "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding code quality report: %w", err)
+	}
+	if err := os.WriteFile(outputFile, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("error creating code quality report: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+)
+
+// ArchiveLimits bounds the data decompressed from archives, so a zip bomb
+// fails the run with a clear error instead of exhausting memory or disk.
+type ArchiveLimits struct {
+	MaxEntrySize int64   `mapstructure:"max_entry_size" json:"max_entry_size"` // bytes per entry; 0 for no limit
+	MaxTotalSize int64   `mapstructure:"max_total_size" json:"max_total_size"` // bytes per archive; 0 for no limit
+	MaxRatio     float64 `mapstructure:"max_ratio" json:"max_ratio"`           // decompressed to compressed size; 0 for no limit
+}
+
+// ratioMinSize is the decompressed size below which MaxRatio is not
+// enforced, since small files of repeated bytes compress extremely well.
+const ratioMinSize = 1 << 20
+
+// limitError reports data decompressed from an archive beyond one of the
+// archive limits, so callers can tell a refused archive from a broken one.
+type limitError struct {
+	archive string
+	limit   string // key of the exceeded limit in archive_limits
+	detail  string
+}
+
+func (e *limitError) Error() string {
+	return fmt.Sprintf("%s: %s (archive_limits.%s)", e.archive, e.detail, e.limit)
+}
+
+// archiveBudget accounts for the data decompressed from one archive.
+type archiveBudget struct {
+	archive string
+	limits  ArchiveLimits
+	total   int64
+}
+
+func newArchiveBudget(archive string, limits ArchiveLimits) *archiveBudget {
+	return &archiveBudget{archive: archive, limits: limits}
+}
+
+// add accounts for an entry of the given decompressed and compressed sizes
+// and fails if it exceeds a limit. compressed is negative for entries
+// stored without compression of their own, which are not checked against
+// MaxRatio.
+func (b *archiveBudget) add(name string, size, compressed int64) error {
+	l := b.limits
+	if l.MaxEntrySize > 0 && size > l.MaxEntrySize {
+		return &limitError{b.archive, "max_entry_size", fmt.Sprintf("entry %s decompresses to %d bytes, over the limit of %d", name, size, l.MaxEntrySize)}
+	}
+	if l.MaxRatio > 0 && compressed >= 0 && size >= ratioMinSize && float64(size) > l.MaxRatio*float64(compressed) {
+		return &limitError{b.archive, "max_ratio", fmt.Sprintf("entry %s decompresses %d bytes to %d, over the ratio limit of %g", name, compressed, size, l.MaxRatio)}
+	}
+	b.total += size
+	if l.MaxTotalSize > 0 && b.total > l.MaxTotalSize {
+		return &limitError{b.archive, "max_total_size", fmt.Sprintf("entries decompress to more than %d bytes", l.MaxTotalSize)}
+	}
+	return nil
+}
+
+// checkZipLimits checks the entries of a zip archive against the limits
+// before any of them is read. The sizes in the headers can be trusted,
+// since archive/zip fails reads of entries exceeding their declared size.
+func checkZipLimits(archive string, r *zip.Reader, limits ArchiveLimits) error {
+	budget := newArchiveBudget(archive, limits)
+	for _, f := range r.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		if err := budget.add(f.Name, int64(f.UncompressedSize64), int64(f.CompressedSize64)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// guardedReader reads a decompressed stream, such as a gzipped tarball,
+// failing once it grows beyond the total size or ratio limits. compressed
+// is the size of the compressed input.
+type guardedReader struct {
+	r          io.Reader
+	budget     *archiveBudget
+	compressed int64
+	read       int64
+}
+
+func (g *guardedReader) Read(p []byte) (int, error) {
+	n, err := g.r.Read(p)
+	g.read += int64(n)
+	l := g.budget.limits
+	if l.MaxTotalSize > 0 && g.read > l.MaxTotalSize {
+		return n, &limitError{g.budget.archive, "max_total_size", fmt.Sprintf("decompresses to more than %d bytes", l.MaxTotalSize)}
+	}
+	if l.MaxRatio > 0 && g.read >= ratioMinSize && float64(g.read) > l.MaxRatio*float64(g.compressed) {
+		return n, &limitError{g.budget.archive, "max_ratio", fmt.Sprintf("decompresses %d bytes to more than %d, over the ratio limit of %g", g.compressed, g.read, l.MaxRatio)}
+	}
+	return n, err
+}
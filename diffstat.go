@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// ANSI colors of the diffstat bars, as used by git.
+const (
+	ansiGreen = "\x1b[32m"
+	ansiRed   = "\x1b[31m"
+	ansiReset = "\x1b[m"
+)
+
+// diffstatWidth is the widest bar of a diffstat line.
+const diffstatWidth = 50
+
+// diffstatEntry counts the changed lines of one differing file. Binary
+// files have no line counts.
+type diffstatEntry struct {
+	path       string
+	insertions int
+	deletions  int
+	binary     bool
+}
+
+// countLineChanges returns the number of lines the target inserts and
+// deletes relative to the source, after the same preparation as the
+// detailed diff.
+func (run *runState) countLineChanges(pair filePair) diffstatEntry {
+	entry := diffstatEntry{path: toSlash(pair.SourcePath)}
+	source, target, err := run.readPair(pair, run.config.NormalizeCmd)
+	if err != nil || isBinary(source) || isBinary(target) {
+		entry.binary = true
+		return entry
+	}
+
+	dmp := diffmatchpatch.New()
+	chars1, chars2, linePatches := dmp.DiffLinesToChars(string(source), string(target))
+	for _, diff := range dmp.DiffCharsToLines(dmp.DiffMain(chars1, chars2, false), linePatches) {
+		n := countLines(diff.Text)
+		switch diff.Type {
+		case diffmatchpatch.DiffInsert:
+			entry.insertions += n
+		case diffmatchpatch.DiffDelete:
+			entry.deletions += n
+		}
+	}
+	return entry
+}
+
+// printDiffstat writes a git-style summary of the differing files: one
+// line per file with a bar of its insertions and deletions, followed by
+// the totals.
+func (run *runState) printDiffstat(w io.Writer, pairs []filePair, color bool) {
+	if len(pairs) == 0 {
+		return
+	}
+	entries := make([]diffstatEntry, 0, len(pairs))
+	nameWidth, maxChanges := 0, 0
+	for _, pair := range pairs {
+		e := run.countLineChanges(pair)
+		entries = append(entries, e)
+		nameWidth = max(nameWidth, len(e.path))
+		maxChanges = max(maxChanges, e.insertions+e.deletions)
+	}
+	countWidth := len(fmt.Sprint(maxChanges))
+
+	insertions, deletions := 0, 0
+	for _, e := range entries {
+		if e.binary {
+			fmt.Fprintf(w, " %-*s | %*s\n", nameWidth, e.path, countWidth, "Bin")
+			continue
+		}
+		insertions += e.insertions
+		deletions += e.deletions
+
+		// Scale the bars down to fit, keeping at least one mark for a
+		// side with changes
+		plus, minus := e.insertions, e.deletions
+		if maxChanges > diffstatWidth {
+			plus = scaleBar(plus, maxChanges)
+			minus = scaleBar(minus, maxChanges)
+		}
+		bar := colorize(strings.Repeat("+", plus), ansiGreen, color) +
+			colorize(strings.Repeat("-", minus), ansiRed, color)
+		fmt.Fprintf(w, " %-*s | %*d %s\n", nameWidth, e.path, countWidth, e.insertions+e.deletions, bar)
+	}
+
+	summary := fmt.Sprintf(" %d file%s changed", len(entries), plural(len(entries)))
+	if insertions > 0 || deletions == 0 {
+		summary += fmt.Sprintf(", %d insertion%s(+)", insertions, plural(insertions))
+	}
+	if deletions > 0 || insertions == 0 {
+		summary += fmt.Sprintf(", %d deletion%s(-)", deletions, plural(deletions))
+	}
+	fmt.Fprintln(w, summary)
+}
+
+func scaleBar(n, maxChanges int) int {
+	if n == 0 {
+		return 0
+	}
+	return max(1, n*diffstatWidth/maxChanges)
+}
+
+func colorize(s, code string, color bool) string {
+	if !color || s == "" {
+		return s
+	}
+	return code + s + ansiReset
+}
+
+func plural(n int) string {
+	if n == 1 {
+		return ""
+	}
+	return "s"
+}
+
+// useColor reports whether console output is colored: not with --no-color
+// or NO_COLOR set, and only on a terminal.
+func useColor(config *Config) bool {
+	if config.NoColor || os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	fi, err := os.Stdout.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// difftoolArgs expands the {source}, {target} and {path} placeholders of a
+// difftool command. The files are appended when the command names
+// neither side.
+func difftoolArgs(command, source, target, relPath string) []string {
+	args := strings.Fields(command)
+	named := false
+	for i, arg := range args {
+		if strings.Contains(arg, "{source}") || strings.Contains(arg, "{target}") {
+			named = true
+		}
+		args[i] = strings.NewReplacer("{source}", source, "{target}", target, "{path}", relPath).Replace(arg)
+	}
+	if !named {
+		args = append(args, source, target)
+	}
+	return args
+}
+
+// runDifftool opens the differing files in the configured external diff
+// tool, one pair at a time like git difftool. With prompting, the user
+// picks which files to open from stdin.
+func runDifftool(pairs []filePair, config *Config, in io.Reader) error {
+	if len(pairs) == 0 {
+		return nil
+	}
+	// Archive entries are written out, so the tool gets real files
+	tmp, err := os.MkdirTemp("", "gitparator-difftool-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+
+	input := bufio.NewReader(in)
+	for i, pair := range pairs {
+		if config.DifftoolPrompt {
+			fmt.Printf("\nViewing (%d/%d): '%s'\nLaunch '%s' [Y/n/q]? ", i+1, len(pairs), pair.SourcePath, config.Difftool)
+			answer, err := input.ReadString('\n')
+			if err != nil && answer == "" {
+				return nil
+			}
+			switch strings.ToLower(strings.TrimSpace(answer)) {
+			case "n", "no":
+				continue
+			case "q", "quit":
+				return nil
+			}
+		}
+
+		source, err := difftoolFile(pair.SourceFile, filepath.Join(tmp, fmt.Sprint(i), "source"))
+		if err != nil {
+			return err
+		}
+		target, err := difftoolFile(pair.TargetFile, filepath.Join(tmp, fmt.Sprint(i), "target"))
+		if err != nil {
+			return err
+		}
+		args := difftoolArgs(config.Difftool, source, target, toSlash(pair.SourcePath))
+		cmd := exec.Command(args[0], args[1:]...)
+		cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+		// Diff tools exit non-zero when files differ, so only failures to
+		// start are errors
+		if err := cmd.Run(); err != nil {
+			if _, ok := err.(*exec.ExitError); !ok {
+				return fmt.Errorf("error running difftool: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// difftoolFile returns a path on disk holding file, writing files not on
+// disk below dir under their base name, which keeps the extension for
+// the tool's syntax detection.
+func difftoolFile(file sourceFile, dir string) (string, error) {
+	if p, ok := file.diskPath(); ok {
+		return p, nil
+	}
+	content, err := readFileContent(file)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	name := filepath.Join(dir, path.Base(file.name))
+	return name, os.WriteFile(name, content, 0644)
+}
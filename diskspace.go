@@ -0,0 +1,102 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+// errFreeSpaceUnknown is reported when the platform or file system cannot
+// tell how much space is available.
+var errFreeSpaceUnknown = errors.New("cannot determine free space")
+
+// githubRepoURL extracts owner and name from GitHub clone URLs in https or
+// scp-like ssh form.
+var githubRepoURL = regexp.MustCompile(`^(?:https?://|ssh://git@|git@)github\.com[/:]([^/]+)/([^/]+?)(?:\.git)?/?$`)
+
+// estimateCloneSize returns the size in bytes advertised by the forge for
+// the repository at url, or 0 when no estimate is available. GitHub reports
+// the size of the full history, which over-estimates a shallow clone.
+func estimateCloneSize(url string) int64 {
+	m := githubRepoURL.FindStringSubmatch(url)
+	if m == nil {
+		return 0
+	}
+
+	var payload struct {
+		Size int64 `json:"size"` // in KiB
+	}
+	endpoint := "https://api.github.com/repos/" + m[1] + "/" + m[2]
+	if err := getJSON(endpoint, forgeAPIHeaders("github", endpoint), &payload); err != nil {
+		return 0
+	}
+	return payload.Size * 1024
+}
+
+// checkDiskSpace verifies that the file system holding dir has at least
+// required bytes available.
+func checkDiskSpace(dir string, required int64) error {
+	if required <= 0 {
+		return nil
+	}
+
+	// The clone directory usually does not exist yet; check its nearest existing ancestor
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	for {
+		if _, err := os.Stat(dir); err == nil {
+			break
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+
+	available, err := freeDiskSpace(dir)
+	if err != nil {
+		return fmt.Errorf("%w in %s: %v", errFreeSpaceUnknown, dir, err)
+	}
+	if available < uint64(required) {
+		return fmt.Errorf("not enough free space in %s: %s required, %s available",
+			dir, formatBytes(uint64(required)), formatBytes(available))
+	}
+	return nil
+}
+
+// formatBytes renders a byte count with a binary unit suffix.
+func formatBytes(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := uint64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// preCloneSpaceCheck fails early when the temp location cannot hold the
+// clone, using the larger of the forge estimate and the configured minimum.
+// Warnings are written to out.
+func preCloneSpaceCheck(config *Config, out io.Writer) error {
+	required := config.MinFreeSpace * 1024 * 1024
+	if estimate := estimateCloneSize(config.TargetURL); estimate > required {
+		required = estimate
+	}
+	err := checkDiskSpace(config.TempDir, required)
+	if errors.Is(err, errFreeSpaceUnknown) {
+		// Unsupported platforms should not block cloning
+		fmt.Fprintln(out, "Warning:", err)
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/fs"
+	"sort"
+)
+
+// DuplicateGroup is a set of files with the same content, within the source,
+// within the target or across both, such as copy-pasted files that could be
+// deduplicated or synced from a single origin. A file found at the same path
+// on both sides only is not a duplicate.
+type DuplicateGroup struct {
+	SHA256 string   `json:"sha256"`
+	Size   int64    `json:"size"`
+	Source []string `json:"source"` // paths in the source
+	Target []string `json:"target"` // paths in the target
+}
+
+// duplicateFile is a file taking part in duplicate detection.
+type duplicateFile struct {
+	side    string
+	relPath string
+	file    sourceFile
+}
+
+// findDuplicates groups the non-empty source and target files, given as
+// relative path to file, by content. Only files sharing their size are
+// read, so unique sizes cost a stat.
+func findDuplicates(sourceFiles, targetFiles map[string]sourceFile) []DuplicateGroup {
+	bySize := make(map[int64][]duplicateFile)
+	add := func(side string, files map[string]sourceFile) {
+		for relPath, file := range files {
+			fi, err := fs.Stat(file.tree, file.name)
+			if err != nil || fi.Size() == 0 {
+				continue
+			}
+			bySize[fi.Size()] = append(bySize[fi.Size()], duplicateFile{side, relPath, file})
+		}
+	}
+	add("source", sourceFiles)
+	add("target", targetFiles)
+
+	byContent := make(map[string]*DuplicateGroup)
+	for size, files := range bySize {
+		if len(files) < 2 {
+			continue
+		}
+		for _, f := range files {
+			content, err := readFileContent(f.file)
+			if err != nil {
+				continue
+			}
+			sum := sha256.Sum256(content)
+			key := hex.EncodeToString(sum[:])
+			group := byContent[key]
+			if group == nil {
+				group = &DuplicateGroup{SHA256: key, Size: size}
+				byContent[key] = group
+			}
+			if f.side == "source" {
+				group.Source = append(group.Source, f.relPath)
+			} else {
+				group.Target = append(group.Target, f.relPath)
+			}
+		}
+	}
+
+	var groups []DuplicateGroup
+	for _, group := range byContent {
+		sort.Strings(group.Source)
+		sort.Strings(group.Target)
+		sameFile := len(group.Source) == 1 && len(group.Target) == 1 && group.Source[0] == group.Target[0]
+		if len(group.Source)+len(group.Target) < 2 || sameFile {
+			continue
+		}
+		groups = append(groups, *group)
+	}
+	// Largest savings first
+	sort.Slice(groups, func(i, j int) bool {
+		wi := groups[i].Size * int64(len(groups[i].Source)+len(groups[i].Target))
+		wj := groups[j].Size * int64(len(groups[j].Source)+len(groups[j].Target))
+		if wi != wj {
+			return wi > wj
+		}
+		return groups[i].SHA256 < groups[j].SHA256
+	})
+	return groups
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/base64"
+	"unicode/utf8"
+)
+
+// EmbeddedFile holds the contents of one side of a small file in the JSON
+// result. Binary or non-UTF-8 contents are base64 encoded.
+type EmbeddedFile struct {
+	Encoding string `json:"encoding"` // "utf-8" or "base64"
+	Content  string `json:"content"`
+}
+
+// EmbeddedContents holds both sides of a differing file; a side is nil when
+// the file only exists on the other side.
+type EmbeddedContents struct {
+	Source *EmbeddedFile `json:"source,omitempty"`
+	Target *EmbeddedFile `json:"target,omitempty"`
+}
+
+// embedFile prepares contents for embedding, or returns nil if they are
+// larger than maxSize bytes.
+func (run *runState) embedFile(content []byte, maxSize int64) *EmbeddedFile {
+	if int64(len(content)) > maxSize {
+		return nil
+	}
+	content = run.redactContent(content)
+	if utf8.Valid(content) && !isBinary(content) {
+		return &EmbeddedFile{Encoding: "utf-8", Content: string(content)}
+	}
+	return &EmbeddedFile{Encoding: "base64", Content: base64.StdEncoding.EncodeToString(content)}
+}
+
+// embedOneSided reads a file that only exists on one side for embedding, or
+// returns nil if it cannot be read or is larger than maxSize bytes.
+func (run *runState) embedOneSided(file sourceFile, maxSize int64) *EmbeddedFile {
+	content, err := readFileContent(file)
+	if err != nil {
+		return nil
+	}
+	return run.embedFile(content, maxSize)
+}
+
+// embedContents embeds both sides of a differing file when each fits in
+// maxSize bytes.
+func (run *runState) embedContents(result *ComparisonResult, path string, source, target []byte, maxSize int64) {
+	c := EmbeddedContents{Source: run.embedFile(source, maxSize), Target: run.embedFile(target, maxSize)}
+	if c.Source != nil && c.Target != nil {
+		result.Contents[path] = c
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Encodings detected in compared files.
+const (
+	encodingUTF8    = "utf-8"
+	encodingUTF16LE = "utf-16le"
+	encodingUTF16BE = "utf-16be"
+	encodingLatin1  = "iso-8859-1"
+	encodingBinary  = "binary"
+)
+
+// FileEncoding is the encoding of both sides of a differing file, recorded
+// when either side is not UTF-8 text.
+type FileEncoding struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+}
+
+// detectEncoding tells UTF-16 text, which has NUL bytes in most code units
+// of ASCII characters, from binary content, and Latin-1 text from UTF-8 by
+// its invalid UTF-8 sequences.
+func detectEncoding(content []byte) string {
+	return sniffEncoding(content, int64(len(content)))
+}
+
+// sniffEncoding is detectEncoding for a file of size bytes of which only
+// prefix has been read. Latin-1 text is only recognized by invalid UTF-8
+// sequences within the prefix.
+func sniffEncoding(prefix []byte, size int64) string {
+	if encoding := utf16Encoding(prefix, size); encoding != "" {
+		return encoding
+	}
+	if isBinary(prefix) {
+		return encodingBinary
+	}
+	if int64(len(prefix)) < size {
+		// The prefix may end within a multibyte character
+		for i := 1; i < utf8.UTFMax && i <= len(prefix); i++ {
+			if utf8.RuneStart(prefix[len(prefix)-i]) {
+				if !utf8.FullRune(prefix[len(prefix)-i:]) {
+					prefix = prefix[:len(prefix)-i]
+				}
+				break
+			}
+		}
+	}
+	if !utf8.Valid(prefix) {
+		return encodingLatin1
+	}
+	return encodingUTF8
+}
+
+// utf16Encoding returns the byte order of UTF-16 content of size bytes, by
+// its byte order mark or, without one, by NUL bytes in only the high byte
+// of code units.
+func utf16Encoding(content []byte, size int64) string {
+	switch {
+	case bytes.HasPrefix(content, []byte{0xff, 0xfe}):
+		return encodingUTF16LE
+	case bytes.HasPrefix(content, []byte{0xfe, 0xff}):
+		return encodingUTF16BE
+	case size < 4 || size%2 != 0:
+		return ""
+	}
+
+	sample := content[:min(len(content), binarySniffLen)&^1]
+	var even, odd int
+	for i := 0; i < len(sample); i += 2 {
+		if sample[i] == 0 {
+			even++
+		}
+		if sample[i+1] == 0 {
+			odd++
+		}
+	}
+	units := len(sample) / 2
+	switch {
+	case even == 0 && odd*2 >= units:
+		return encodingUTF16LE
+	case odd == 0 && even*2 >= units:
+		return encodingUTF16BE
+	}
+	return ""
+}
+
+// toUTF8 transcodes UTF-16 and Latin-1 text to UTF-8, dropping a byte order
+// mark, so it diffs line by line like any text. Other content is returned
+// unchanged.
+func toUTF8(content []byte) []byte {
+	switch detectEncoding(content) {
+	case encodingUTF16LE:
+		return decodeUTF16(content, binary.LittleEndian)
+	case encodingUTF16BE:
+		return decodeUTF16(content, binary.BigEndian)
+	case encodingLatin1:
+		// Latin-1 bytes are the first 256 code points
+		runes := make([]rune, len(content))
+		for i, b := range content {
+			runes[i] = rune(b)
+		}
+		return []byte(string(runes))
+	}
+	return content
+}
+
+func decodeUTF16(content []byte, order binary.ByteOrder) []byte {
+	units := make([]uint16, len(content)/2)
+	for i := range units {
+		units[i] = order.Uint16(content[2*i:])
+	}
+	if len(units) > 0 && units[0] == 0xfeff {
+		units = units[1:]
+	}
+	return []byte(string(utf16.Decode(units)))
+}
+
+// pairEncoding returns the encodings of a pair's files when either side is
+// UTF-16 or Latin-1 text, or nil.
+func pairEncoding(source, target fileSniff) *FileEncoding {
+	encoding := &FileEncoding{Source: source.encoding, Target: target.encoding}
+	transcoded := func(e string) bool { return e != encodingUTF8 && e != encodingBinary }
+	if !transcoded(encoding.Source) && !transcoded(encoding.Target) {
+		return nil
+	}
+	return encoding
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSniffEncoding(t *testing.T) {
+	long := strings.Repeat("a", binarySniffLen-1) + "é and more"
+	tests := []struct {
+		name   string
+		prefix string
+		size   int64
+		want   string
+	}{
+		{"whole utf-8", "héllo\n", 7, encodingUTF8},
+		{"whole latin-1", "h\xe9llo\n", 6, encodingLatin1},
+		{"prefix ends within a character", long[:binarySniffLen], int64(len(long)), encodingUTF8},
+		{"invalid within the prefix", "h\xe9llo" + long[:100], int64(len(long)), encodingLatin1},
+		{"binary", "\x89PNG\x00\x00", 1 << 20, encodingBinary},
+		{"utf-16le", "h\x00i\x00", 1 << 20, encodingUTF16LE},
+		{"utf-16 of odd size", "h\x00i\x00", 1<<20 + 1, encodingBinary},
+		{"utf-16be bom", "\xfe\xff\x00h", 4, encodingUTF16BE},
+	}
+	for _, tt := range tests {
+		if got := sniffEncoding([]byte(tt.prefix), tt.size); got != tt.want {
+			t.Errorf("%s: sniffEncoding = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
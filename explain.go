@@ -0,0 +1,216 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/adnsv/gitparator/gitignore"
+	"github.com/spf13/cobra"
+)
+
+// PathExplanation describes how the configuration treats a relative path.
+type PathExplanation struct {
+	Path           string      `json:"path"`
+	ExcludedBy     string      `json:"excluded_by,omitempty"`  // matching exclude_paths entry
+	ExcludedDir    string      `json:"excluded_dir,omitempty"` // excluded parent directory, if not the path itself
+	IgnoredBy      *IgnoreRule `json:"ignored_by,omitempty"`
+	Strategy       string      `json:"strategy"`
+	NormalizeRules []int       `json:"normalize_rules,omitempty"` // 1-based indices of matching rules
+	Notes          []string    `json:"notes,omitempty"`
+}
+
+// IgnoreRule locates the line of an ignore file that excluded a path.
+type IgnoreRule struct {
+	File    string `json:"file"`
+	Line    int    `json:"line"`
+	Pattern string `json:"pattern"`
+}
+
+func (r IgnoreRule) String() string {
+	return fmt.Sprintf("%s:%d: %s", toSlash(r.File), r.Line, r.Pattern)
+}
+
+// newExplainCommand creates the explain subcommand, which reports why a
+// path of the source tree is excluded from comparisons.
+func newExplainCommand(config *Config) *cobra.Command {
+	return &cobra.Command{
+		Use:   "explain <path>",
+		Short: "Report why a path of the source tree is excluded",
+		Long: `Explain reports which exclude_paths entry or which .gitignore line (file
+and line number) excludes a path, given relative to the source directory
+(--source-dir and --source-subdir). Like git check-ignore, it also
+considers .git/info/exclude and the global excludes file, and reports
+paths excluded along with a parent directory. The comparison strategy and
+normalization rules applying to the path are listed as well.`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			e, err := explainPath(config, args[0])
+			if err != nil {
+				fmt.Println("Error:", err)
+				os.Exit(1)
+			}
+			printExplanation(e)
+		},
+	}
+}
+
+func printExplanation(e PathExplanation) {
+	fmt.Println("Path:", e.Path)
+	switch {
+	case e.ExcludedBy != "" && e.ExcludedDir != "":
+		fmt.Printf("Excluded: directory %s matches exclude_paths entry %q\n", e.ExcludedDir, e.ExcludedBy)
+	case e.ExcludedBy != "":
+		fmt.Printf("Excluded: matches exclude_paths entry %q\n", e.ExcludedBy)
+	case e.IgnoredBy != nil && e.ExcludedDir != "":
+		fmt.Printf("Ignored: directory %s matches %s\n", e.ExcludedDir, e.IgnoredBy)
+	case e.IgnoredBy != nil:
+		fmt.Println("Ignored: matches", e.IgnoredBy)
+	default:
+		fmt.Println("Not excluded")
+	}
+	fmt.Println("Strategy:", e.Strategy)
+	for _, i := range e.NormalizeRules {
+		fmt.Printf("Normalize rule: #%d\n", i)
+	}
+	for _, note := range e.Notes {
+		fmt.Println("Note:", note)
+	}
+}
+
+// explainPath reports which exclusions, comparison strategy and
+// normalization rules apply to relPath. With respect_gitignore, the ignore
+// files of the source tree are consulted as a scan of it would.
+func explainPath(config *Config, relPath string) (PathExplanation, error) {
+	relPath = strings.Trim(toSlash(filepath.Clean(relPath)), "/")
+	e := PathExplanation{Path: relPath, Strategy: comparisonStrategy(relPath, config)}
+
+	if err := validateCompareStrategies(config.CompareStrategies); err != nil {
+		return e, err
+	}
+	if _, err := compileNormalizeRules(config.Normalize); err != nil {
+		return e, err
+	}
+	for i, rule := range config.Normalize {
+		if len(rule.Paths) == 0 || shouldExclude(relPath, rule.Paths) {
+			e.NormalizeRules = append(e.NormalizeRules, i+1)
+		}
+	}
+
+	var dir string
+	var levels []ignoreLevel
+	if config.RespectGitignore {
+		dir = filepath.Clean(filepath.Join(config.SourceDir, config.SourceSubdir))
+		for _, file := range repoExcludeFiles(dir) {
+			if level, err := readIgnoreFile(file); err == nil && len(level.patterns) > 0 {
+				level.dir = dir
+				levels = append(levels, level)
+			}
+		}
+	}
+
+	// A scan stops at excluded directories, so check every parent first
+	parts := strings.Split(relPath, "/")
+	for i := range parts {
+		current := strings.Join(parts[:i+1], "/")
+		if config.RespectGitignore {
+			parent := filepath.Join(dir, filepath.FromSlash(strings.Join(parts[:i], "/")))
+			if level, err := readIgnoreFile(filepath.Join(parent, ".gitignore")); err == nil && len(level.patterns) > 0 {
+				level.dir = parent
+				levels = append(levels, level)
+			}
+		}
+
+		for _, pattern := range config.ExcludePaths {
+			if shouldExclude(current, []string{pattern}) {
+				e.ExcludedBy = pattern
+				break
+			}
+		}
+		if e.ExcludedBy == "" && config.RespectGitignore {
+			isDir := current != relPath
+			if !isDir {
+				fi, err := os.Stat(filepath.Join(dir, filepath.FromSlash(current)))
+				isDir = err == nil && fi.IsDir()
+			}
+			rule, ignored := matchIgnoreLevels(dir, current, isDir, levels, config.IgnoreCase)
+			if ignored {
+				e.IgnoredBy = &rule
+			} else if rule.Pattern != "" && current == relPath {
+				e.Notes = append(e.Notes, fmt.Sprintf("re-included by %s", rule))
+			}
+		}
+		if e.ExcludedBy != "" || e.IgnoredBy != nil {
+			if current != relPath {
+				e.ExcludedDir = current
+			}
+			break
+		}
+	}
+
+	if config.RespectGitignore {
+		e.Notes = append(e.Notes, "ignore files were read from the source tree; the target tree may ignore other paths")
+	}
+	if e.ExcludedBy != "" || e.IgnoredBy != nil {
+		e.Notes = append(e.Notes, "excluded paths are listed in the report but not compared")
+	}
+	return e, nil
+}
+
+// ignoreLevel holds the patterns of one ignore file with their line
+// numbers and the directory they apply to.
+type ignoreLevel struct {
+	file     string
+	dir      string
+	patterns []string
+	lines    []int
+}
+
+// readIgnoreFile reads the patterns of an ignore file as parseGitignore
+// does, remembering the line each pattern came from.
+func readIgnoreFile(path string) (ignoreLevel, error) {
+	level := ignoreLevel{file: path}
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return level, nil
+		}
+		return level, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		level.patterns = append(level.patterns, line)
+		level.lines = append(level.lines, n)
+	}
+	return level, scanner.Err()
+}
+
+// matchIgnoreLevels evaluates relPath, a directory if isDir, against the
+// levels as a scan does and returns the pattern that decided the outcome. The returned rule is
+// empty if no pattern matched; ignored is false if the deciding pattern is
+// negated. Patterns match case-insensitively if ignoreCase is set.
+func matchIgnoreLevels(dir, relPath string, isDir bool, levels []ignoreLevel, ignoreCase bool) (rule IgnoreRule, ignored bool) {
+	stack := gitignore.NewStack(dir)
+	stack.IgnoreCase = ignoreCase
+	for _, level := range levels {
+		stack.PushPatternsIn(level.dir, level.patterns, level.file)
+	}
+	match := stack.Match
+	if isDir {
+		match = stack.MatchDir
+	}
+	ignored, source := match(filepath.Join(dir, filepath.FromSlash(relPath)))
+	if source.Pattern == "" {
+		return IgnoreRule{}, false
+	}
+	line := levels[source.Level].lines[source.Index]
+	return IgnoreRule{File: source.Origin, Line: line, Pattern: source.Pattern}, ignored
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// FormatterRule pipes files matching Paths through an external formatter,
+// such as gofmt or prettier, before they are compared and diffed.
+type FormatterRule struct {
+	Paths   []string `mapstructure:"paths" json:"paths"`
+	Command string   `mapstructure:"command" json:"command"` // program and arguments, reading stdin and writing stdout
+}
+
+type formatterKey struct {
+	command string
+	content [sha256.Size]byte
+}
+
+func validateFormatterRules(rules []FormatterRule) error {
+	for i, rule := range rules {
+		if len(strings.Fields(rule.Command)) == 0 {
+			return fmt.Errorf("normalize_cmd %d: no command given", i+1)
+		}
+		if len(rule.Paths) == 0 {
+			return fmt.Errorf("normalize_cmd %d: no paths given", i+1)
+		}
+	}
+	return nil
+}
+
+// formatterFor returns the formatter command of the first rule matching
+// relPath, or "" if none matches.
+func formatterFor(relPath string, rules []FormatterRule) string {
+	relPath = toSlash(relPath)
+	for _, rule := range rules {
+		if shouldExclude(relPath, rule.Paths) {
+			return rule.Command
+		}
+	}
+	return ""
+}
+
+// formatContent runs content through the formatter configured for
+// relPath. Content is returned unchanged when no formatter applies or the
+// formatter fails, e.g. on a syntax error. Output is cached for the run,
+// since each differing file is formatted for the comparison and again for
+// its diff.
+func (run *runState) formatContent(relPath string, content []byte, rules []FormatterRule) []byte {
+	command := formatterFor(relPath, rules)
+	if command == "" {
+		return content
+	}
+	key := formatterKey{command, sha256.Sum256(content)}
+	if out, ok := run.formatted[key]; ok {
+		return out
+	}
+
+	out, err := runLimited(command, content, run.config.CommandLimits)
+	if err != nil {
+		log.Printf("Warning: formatter %q failed on %s, comparing it unformatted: %v", command, relPath, err)
+		out = content
+	}
+	run.formatted[key] = out
+	return out
+}
+
+// readPair reads both sides of a pair, prepared for comparison: template
+// tokens are substituted into the target, then both sides are formatted by
+// the configured formatters.
+func (run *runState) readPair(pair filePair, rules []FormatterRule) (source, target []byte, err error) {
+	if source, err = readFileContent(pair.SourceFile); err != nil {
+		return nil, nil, err
+	}
+	if target, err = readFileContent(pair.TargetFile); err != nil {
+		return nil, nil, err
+	}
+	target = run.substituteContent(target)
+	return run.formatContent(pair.SourcePath, source, rules), run.formatContent(pair.TargetPath, target, rules), nil
+}
+
+// preparedEqual reports whether a pair is equal once both sides are
+// prepared by readPair.
+func (run *runState) preparedEqual(pair filePair, rules []FormatterRule) bool {
+	if run.substitutions == nil && formatterFor(pair.SourcePath, rules) == "" && formatterFor(pair.TargetPath, rules) == "" {
+		return false
+	}
+	source, target, err := run.readPair(pair, rules)
+	return err == nil && bytes.Equal(source, target)
+}
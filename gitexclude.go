@@ -0,0 +1,104 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	gitconfig "github.com/go-git/go-git/v5/config"
+)
+
+// repoExcludeFiles returns the files of ignore patterns git applies to the
+// working tree containing dir besides .gitignore files, in increasing
+// precedence: the excludes file named by core.excludesFile (by default
+// $XDG_CONFIG_HOME/git/ignore) and .git/info/exclude. Nothing is returned
+// when dir is not in a git working tree. The files may not exist.
+func repoExcludeFiles(dir string) []string {
+	gitDir := findGitDir(dir)
+	if gitDir == "" {
+		return nil
+	}
+	commonDir := gitDir
+	// Linked worktrees share info/exclude and config with the main one
+	if data, err := os.ReadFile(filepath.Join(gitDir, "commondir")); err == nil {
+		commonDir = resolveGitPath(gitDir, strings.TrimSpace(string(data)))
+	}
+
+	var files []string
+	if path := globalExcludesFile(commonDir); path != "" {
+		files = append(files, path)
+	}
+	return append(files, filepath.Join(commonDir, "info", "exclude"))
+}
+
+// findGitDir returns the git directory of the working tree containing dir,
+// following the "gitdir:" file of linked worktrees and submodules, or an
+// empty string.
+func findGitDir(dir string) string {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+	for {
+		dotGit := filepath.Join(dir, ".git")
+		if fi, err := os.Stat(dotGit); err == nil {
+			if fi.IsDir() {
+				return dotGit
+			}
+			data, err := os.ReadFile(dotGit)
+			if err != nil {
+				return ""
+			}
+			line := strings.TrimSpace(string(data))
+			if !strings.HasPrefix(line, "gitdir:") {
+				return ""
+			}
+			return resolveGitPath(dir, strings.TrimSpace(strings.TrimPrefix(line, "gitdir:")))
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+func resolveGitPath(base, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(base, path)
+}
+
+// globalExcludesFile returns the path of the excludes file, as configured
+// in the repository or the user's git config, or git's default location.
+func globalExcludesFile(gitDir string) string {
+	var path string
+	if f, err := os.Open(filepath.Join(gitDir, "config")); err == nil {
+		if cfg, err := gitconfig.ReadConfig(f); err == nil {
+			path = cfg.Raw.Section("core").Options.Get("excludesfile")
+		}
+		f.Close()
+	}
+	if path == "" {
+		if cfg, err := gitconfig.LoadConfig(gitconfig.GlobalScope); err == nil {
+			path = cfg.Raw.Section("core").Options.Get("excludesfile")
+		}
+	}
+
+	home, _ := os.UserHomeDir()
+	switch {
+	case path == "~" || strings.HasPrefix(path, "~/"):
+		if home == "" {
+			return ""
+		}
+		return filepath.Join(home, path[1:])
+	case path != "":
+		return path
+	case os.Getenv("XDG_CONFIG_HOME") != "":
+		return filepath.Join(os.Getenv("XDG_CONFIG_HOME"), "git", "ignore")
+	case home != "":
+		return filepath.Join(home, ".config", "git", "ignore")
+	}
+	return ""
+}
@@ -9,57 +9,141 @@ import (
 
 type Stack struct {
 	patterns [][]string
+	origins  []string
+	dirs     []string // directory of each level relative to basePath, "" for basePath
 	basePath string
+
+	// IgnoreCase matches patterns case-insensitively, as git does with
+	// core.ignoreCase on case-insensitive file systems.
+	IgnoreCase bool
+}
+
+// PatternRef identifies the pattern that decided whether a path is ignored.
+type PatternRef struct {
+	Pattern string // as pushed, including a leading "!" of negated patterns
+	Level   int    // position of the level in the stack, 0 being the bottom
+	Index   int    // position of the pattern within its level
+	Origin  string // origin given with the level, such as the file it was read from
 }
 
 func NewStack(basePath string) *Stack {
 	basePath = filepath.ToSlash(basePath)
 	return &Stack{
 		patterns: make([][]string, 0),
+		origins:  make([]string, 0),
+		dirs:     make([]string, 0),
 		basePath: basePath,
 	}
 }
 
 func (s *Stack) PushPatterns(patterns []string) {
+	s.PushPatternsFrom(patterns, "")
+}
+
+// PushPatternsFrom pushes a level of patterns, recording origin so Match
+// can report where a deciding pattern came from.
+func (s *Stack) PushPatternsFrom(patterns []string, origin string) {
+	s.PushPatternsIn(s.basePath, patterns, origin)
+}
+
+// PushPatternsIn pushes a level of patterns defined in the directory dir,
+// such as those of dir/.gitignore. As in git, the patterns only apply to
+// paths below dir and are matched relative to it, so a pattern containing
+// a slash or starting with one is anchored to dir.
+func (s *Stack) PushPatternsIn(dir string, patterns []string, origin string) {
 	normalizedPatterns := make([]string, len(patterns))
 	for i, pattern := range patterns {
 		normalizedPatterns[i] = filepath.ToSlash(pattern)
 	}
+	relDir, err := filepath.Rel(s.basePath, filepath.ToSlash(dir))
+	relDir = filepath.ToSlash(relDir)
+	if err != nil || relDir == "." || strings.HasPrefix(relDir, "..") {
+		relDir = ""
+	}
 	s.patterns = append(s.patterns, normalizedPatterns)
+	s.origins = append(s.origins, origin)
+	s.dirs = append(s.dirs, relDir)
 }
 
 func (s *Stack) PopPatterns() {
 	if len(s.patterns) > 0 {
 		s.patterns = s.patterns[:len(s.patterns)-1]
+		s.origins = s.origins[:len(s.origins)-1]
+		s.dirs = s.dirs[:len(s.dirs)-1]
+	}
+}
+
+// matchPattern matches a path against a pattern, honoring IgnoreCase.
+func (s *Stack) matchPattern(pattern, path string) bool {
+	if s.IgnoreCase {
+		return wildpath.MatchFold(pattern, path)
 	}
+	return wildpath.Match(pattern, path)
 }
 
 func (s *Stack) ShouldIgnore(path string) bool {
+	ignored, _ := s.Match(path)
+	return ignored
+}
+
+// Match reports whether path is ignored, along with the pattern that
+// decided the outcome: the last matching pattern of the topmost level with
+// a match. source is the zero PatternRef if no pattern matched; a negated
+// pattern decides that the path is not ignored.
+func (s *Stack) Match(path string) (ignored bool, source PatternRef) {
+	return s.match(path, false)
+}
+
+// ShouldIgnoreDir reports whether the directory at path is ignored as a
+// whole, so callers can skip descending into it. Unlike ShouldIgnore, it
+// also honors patterns that only match directories, such as "build/".
+func (s *Stack) ShouldIgnoreDir(path string) bool {
+	ignored, _ := s.MatchDir(path)
+	return ignored
+}
+
+// MatchDir is like Match for the directory at path, as used by
+// ShouldIgnoreDir.
+func (s *Stack) MatchDir(path string) (ignored bool, source PatternRef) {
+	return s.match(path, true)
+}
+
+func (s *Stack) match(path string, isDir bool) (ignored bool, source PatternRef) {
 	// Normalize input path to forward slashes
 	path = filepath.ToSlash(path)
 
 	// Make path relative to base directory
 	relPath, err := filepath.Rel(s.basePath, path)
 	if err != nil {
-		return false
+		return false, PatternRef{}
 	}
 	// Ensure relative path uses forward slashes
 	relPath = filepath.ToSlash(relPath)
 
 	// Check if path is outside base directory
 	if strings.HasPrefix(relPath, "..") {
-		return false
+		return false, PatternRef{}
 	}
 
 	// Process patterns from most specific (last) to least specific (first)
 	for i := len(s.patterns) - 1; i >= 0; i-- {
 		levelPatterns := s.patterns[i]
+		levelPath := relPath
+		if dir := s.dirs[i]; dir != "" {
+			// Patterns of a nested directory only apply below it
+			if !strings.HasPrefix(relPath, dir+"/") {
+				continue
+			}
+			levelPath = relPath[len(dir)+1:]
+		}
 		levelResult := false
 		foundMatch := false
+		var levelSource PatternRef
 
 		// Process patterns within each level from first to last
 		for j := 0; j < len(levelPatterns); j++ {
 			pattern := levelPatterns[j]
+			ref := PatternRef{Pattern: pattern, Level: i, Index: j, Origin: s.origins[i]}
 			// Skip empty patterns
 			if pattern == "" {
 				continue
@@ -70,8 +154,9 @@ func (s *Stack) ShouldIgnore(path string) bool {
 				pattern = pattern[1:] // Remove the ! prefix
 			}
 
-			// Handle absolute path patterns
-			if strings.HasPrefix(pattern, "/") {
+			// Handle absolute path patterns, anchored to the level's directory
+			isAnchored := strings.HasPrefix(pattern, "/")
+			if isAnchored {
 				pattern = pattern[1:] // Remove leading slash
 			}
 
@@ -79,42 +164,54 @@ func (s *Stack) ShouldIgnore(path string) bool {
 			if strings.HasSuffix(pattern, "/") {
 				dirPattern := strings.TrimSuffix(pattern, "/")
 				// Try matching both with and without **/ prefix for directory patterns
-				matched := wildpath.Match("**/"+dirPattern+"/**/*", relPath)
+				matched := !isAnchored && s.matchPattern("**/"+dirPattern+"/**/*", levelPath)
 				if !matched {
-					matched = wildpath.Match(dirPattern+"/**/*", relPath)
+					matched = s.matchPattern(dirPattern+"/**/*", levelPath)
+				}
+				// The directory itself
+				if !matched && isDir {
+					matched = (!isAnchored && s.matchPattern("**/"+dirPattern, levelPath)) || s.matchPattern(dirPattern, levelPath)
 				}
 				if matched {
 					foundMatch = true
 					levelResult = !isNegated
+					levelSource = ref
 				}
 				continue
 			}
 
+			// A trailing "/**" matches everything inside, but not the
+			// directory itself, so its contents can be re-included
+			if strings.HasSuffix(pattern, "/**") {
+				pattern += "/*"
+			}
+
 			// For patterns without slashes, try both with and without **/ prefix
 			matched := false
-			if !strings.Contains(pattern, "/") {
+			if !strings.Contains(pattern, "/") && !isAnchored {
 				// Try with **/ prefix first
-				matched = wildpath.Match("**/"+pattern, relPath)
+				matched = s.matchPattern("**/"+pattern, levelPath)
 				if !matched {
 					// If that fails, try without prefix
-					matched = wildpath.Match(pattern, relPath)
+					matched = s.matchPattern(pattern, levelPath)
 				}
 			} else {
 				// For patterns with slashes, use as-is
-				matched = wildpath.Match(pattern, relPath)
+				matched = s.matchPattern(pattern, levelPath)
 			}
 
 			if matched {
 				foundMatch = true
 				levelResult = !isNegated
+				levelSource = ref
 			}
 		}
 
 		// If we found any match in this level, return its result
 		if foundMatch {
-			return levelResult
+			return levelResult, levelSource
 		}
 	}
 
-	return false
+	return false, PatternRef{}
 }
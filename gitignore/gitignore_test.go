@@ -437,3 +437,197 @@ func TestStack_PatternOrder(t *testing.T) {
 		})
 	}
 }
+
+func TestStack_Match(t *testing.T) {
+	stack := NewStack("/project")
+	stack.PushPatternsFrom([]string{"*.log", "build/"}, "/project/.gitignore")
+	stack.PushPatternsFrom([]string{"*.txt", "!keep.txt"}, "/project/docs/.gitignore")
+
+	tests := []struct {
+		name           string
+		testPath       string
+		expectedIgnore bool
+		expectedSource PatternRef
+	}{
+		{
+			name:           "match in bottom level",
+			testPath:       "/project/debug.log",
+			expectedIgnore: true,
+			expectedSource: PatternRef{Pattern: "*.log", Level: 0, Index: 0, Origin: "/project/.gitignore"},
+		},
+		{
+			name:           "directory pattern",
+			testPath:       "/project/build/out.bin",
+			expectedIgnore: true,
+			expectedSource: PatternRef{Pattern: "build/", Level: 0, Index: 1, Origin: "/project/.gitignore"},
+		},
+		{
+			name:           "match in top level",
+			testPath:       "/project/docs/notes.txt",
+			expectedIgnore: true,
+			expectedSource: PatternRef{Pattern: "*.txt", Level: 1, Index: 0, Origin: "/project/docs/.gitignore"},
+		},
+		{
+			name:           "negated pattern decides",
+			testPath:       "/project/docs/keep.txt",
+			expectedIgnore: false,
+			expectedSource: PatternRef{Pattern: "!keep.txt", Level: 1, Index: 1, Origin: "/project/docs/.gitignore"},
+		},
+		{
+			name:           "no match",
+			testPath:       "/project/main.go",
+			expectedIgnore: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ignored, source := stack.Match(tt.testPath)
+			if ignored != tt.expectedIgnore {
+				t.Errorf("Stack.Match() ignored = %v, want %v", ignored, tt.expectedIgnore)
+			}
+			if source != tt.expectedSource {
+				t.Errorf("Stack.Match() source = %+v, want %+v", source, tt.expectedSource)
+			}
+		})
+	}
+}
+
+func TestStack_ShouldIgnoreDir(t *testing.T) {
+	tests := []struct {
+		name           string
+		patterns       []string
+		testPath       string
+		expectedIgnore bool
+	}{
+		{
+			name:           "directory-only pattern",
+			patterns:       []string{"build/"},
+			testPath:       "/project/build",
+			expectedIgnore: true,
+		},
+		{
+			name:           "directory-only pattern in subdirectory",
+			patterns:       []string{"node_modules/"},
+			testPath:       "/project/web/node_modules",
+			expectedIgnore: true,
+		},
+		{
+			name:           "plain name pattern",
+			patterns:       []string{"node_modules"},
+			testPath:       "/project/node_modules",
+			expectedIgnore: true,
+		},
+		{
+			name:           "negated directory",
+			patterns:       []string{"build/", "!build/"},
+			testPath:       "/project/build",
+			expectedIgnore: false,
+		},
+		{
+			name:           "trailing double star spares the directory",
+			patterns:       []string{"foo/**", "!foo/keep"},
+			testPath:       "/project/foo",
+			expectedIgnore: false,
+		},
+		{
+			name:           "trailing double star prunes subdirectories",
+			patterns:       []string{"foo/**"},
+			testPath:       "/project/foo/sub",
+			expectedIgnore: true,
+		},
+		{
+			name:           "file pattern does not prune",
+			patterns:       []string{"*.log"},
+			testPath:       "/project/logs",
+			expectedIgnore: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stack := NewStack("/project")
+			stack.PushPatterns(tt.patterns)
+			if got := stack.ShouldIgnoreDir(tt.testPath); got != tt.expectedIgnore {
+				t.Errorf("Stack.ShouldIgnoreDir() = %v, want %v", got, tt.expectedIgnore)
+			}
+		})
+	}
+
+	// A directory-only pattern does not match a file of that name
+	stack := NewStack("/project")
+	stack.PushPatterns([]string{"build/"})
+	if stack.ShouldIgnore("/project/build") {
+		t.Errorf("Stack.ShouldIgnore() = true for a file matching a directory-only pattern")
+	}
+
+	// Contents ignored by a trailing "/**" can be re-included
+	stack = NewStack("/project")
+	stack.PushPatterns([]string{"foo/**", "!foo/keep"})
+	if stack.ShouldIgnoreDir("/project/foo") || stack.ShouldIgnore("/project/foo/keep") || !stack.ShouldIgnore("/project/foo/other") {
+		t.Errorf("foo/** with !foo/keep: keep must be included, other ignored")
+	}
+}
+
+func TestStack_PushPatternsIn(t *testing.T) {
+	tests := []struct {
+		name           string
+		testPath       string
+		expectedIgnore bool
+	}{
+		{
+			name:           "pattern applies below its directory",
+			testPath:       "/project/web/debug.log",
+			expectedIgnore: true,
+		},
+		{
+			name:           "pattern does not apply outside its directory",
+			testPath:       "/project/debug.log",
+			expectedIgnore: false,
+		},
+		{
+			name:           "pattern with slash is anchored to its directory",
+			testPath:       "/project/web/dist/app.js",
+			expectedIgnore: true,
+		},
+		{
+			name:           "pattern with slash does not match deeper",
+			testPath:       "/project/web/pkg/dist/app.js",
+			expectedIgnore: false,
+		},
+		{
+			name:           "leading slash is anchored to its directory",
+			testPath:       "/project/web/cache",
+			expectedIgnore: true,
+		},
+		{
+			name:           "leading slash does not match deeper",
+			testPath:       "/project/web/pkg/cache",
+			expectedIgnore: false,
+		},
+	}
+
+	stack := NewStack("/project")
+	stack.PushPatternsIn("/project/web", []string{"*.log", "dist/*.js", "/cache"}, "/project/web/.gitignore")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := stack.ShouldIgnore(tt.testPath); got != tt.expectedIgnore {
+				t.Errorf("Stack.ShouldIgnore() = %v, want %v", got, tt.expectedIgnore)
+			}
+		})
+	}
+}
+
+func TestStack_IgnoreCase(t *testing.T) {
+	stack := NewStack("/project")
+	stack.PushPatterns([]string{"*.LOG", "Build/"})
+	if stack.ShouldIgnore("/project/debug.log") {
+		t.Errorf("Stack.ShouldIgnore() matches case-insensitively without IgnoreCase")
+	}
+	stack.IgnoreCase = true
+	for _, path := range []string{"/project/debug.log", "/project/build/app.js"} {
+		if !stack.ShouldIgnore(path) {
+			t.Errorf("Stack.ShouldIgnore(%q) = false with IgnoreCase, want true", path)
+		}
+	}
+}
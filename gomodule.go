@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/mod/module"
+)
+
+// goProxy returns the first module proxy URL listed in GOPROXY, which
+// defaults to the public Go module proxy.
+func goProxy() (string, error) {
+	proxies := os.Getenv("GOPROXY")
+	if proxies == "" {
+		proxies = "https://proxy.golang.org"
+	}
+	for _, p := range strings.FieldsFunc(proxies, func(r rune) bool { return r == ',' || r == '|' }) {
+		if isRemoteArchive(p) {
+			return strings.TrimSuffix(p, "/"), nil
+		}
+	}
+	return "", fmt.Errorf("GOPROXY=%s names no module proxy URL", proxies)
+}
+
+// downloadModuleZip resolves a module@version spec (the version may be
+// "latest" or omitted) against the module proxy and downloads the module
+// zip into dir. It returns the zip path and the resolved module@version.
+func (run *runState) downloadModuleZip(spec, dir string) (zipPath, resolved string, err error) {
+	path, version, _ := strings.Cut(spec, "@")
+	if version == "" {
+		version = "latest"
+	}
+	if err := module.CheckPath(path); err != nil {
+		return "", "", err
+	}
+	proxy, err := goProxy()
+	if err != nil {
+		return "", "", err
+	}
+	escapedPath, err := module.EscapePath(path)
+	if err != nil {
+		return "", "", err
+	}
+
+	if version == "latest" {
+		var info struct{ Version string }
+		if err := getJSON(proxy+"/"+escapedPath+"/@latest", nil, &info); err != nil {
+			return "", "", fmt.Errorf("error resolving latest version: %w", err)
+		}
+		version = info.Version
+	}
+	escapedVersion, err := module.EscapeVersion(version)
+	if err != nil {
+		return "", "", err
+	}
+
+	resolved = path + "@" + version
+	fmt.Fprintf(run.out, "Downloading module %s\n", resolved)
+	zipPath, err = run.downloadFile(proxy+"/"+escapedPath+"/@v/"+escapedVersion+".zip", dir, "module.zip", nil)
+	return zipPath, resolved, err
+}
+
+// moduleZipComponents returns the number of leading directories of the
+// entries of a module zip, which are all below "path@version/".
+func moduleZipComponents(resolved string) int {
+	return strings.Count(resolved, "/") + 1
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"html/template"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2"
+	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+)
+
+const (
+	highlightStyle     = "github"
+	highlightDarkStyle = "github-dark"
+)
+
+// highlightedLine is one line of source rendered with token classes, along
+// with its plain text used to verify alignment with the diff lines.
+type highlightedLine struct {
+	Text string
+	HTML string
+}
+
+// highlightLines tokenizes content with the lexer matching name and renders
+// each line as HTML spans carrying chroma's short class names. It returns
+// nil when no lexer is registered for the file type.
+func highlightLines(name, content string) []highlightedLine {
+	lexer := lexers.Match(name)
+	if lexer == nil {
+		return nil
+	}
+	lexer = chroma.Coalesce(lexer)
+
+	iterator, err := lexer.Tokenise(nil, content)
+	if err != nil {
+		return nil
+	}
+
+	var lines []highlightedLine
+	for _, tokens := range chroma.SplitTokensIntoLines(iterator.Tokens()) {
+		var text, html strings.Builder
+		for _, token := range tokens {
+			value := strings.TrimSuffix(token.Value, "\n")
+			if value == "" {
+				continue
+			}
+			text.WriteString(value)
+			escaped := template.HTMLEscapeString(value)
+			if class := tokenClass(token.Type); class != "" {
+				html.WriteString(`<span class="` + class + `">` + escaped + `</span>`)
+			} else {
+				html.WriteString(escaped)
+			}
+		}
+		lines = append(lines, highlightedLine{Text: text.String(), HTML: html.String()})
+	}
+	return lines
+}
+
+// tokenClass returns the CSS class of a token type, falling back to its
+// sub-category and category for types without a class of their own.
+func tokenClass(t chroma.TokenType) string {
+	for _, candidate := range []chroma.TokenType{t, t.SubCategory(), t.Category()} {
+		if class, ok := chroma.StandardTypes[candidate]; ok {
+			return class
+		}
+	}
+	return ""
+}
+
+// highlightCSS returns the stylesheet for the token classes emitted by
+// highlightLines.
+func highlightCSS() template.CSS {
+	var css strings.Builder
+	formatter := chromahtml.New(chromahtml.WithClasses(true))
+	if err := formatter.WriteCSS(&css, styles.Get(highlightStyle)); err != nil {
+		return ""
+	}
+	return template.CSS(css.String())
+}
+
+// highlightDarkCSS returns the stylesheet for the token classes in the dark
+// theme, chosen explicitly or by prefers-color-scheme.
+func highlightDarkCSS() template.CSS {
+	var css strings.Builder
+	formatter := chromahtml.New(chromahtml.WithClasses(true))
+	if err := formatter.WriteCSS(&css, styles.Get(highlightDarkStyle)); err != nil {
+		return ""
+	}
+	// Scope the rules, all of which select .chroma or .bg, to the dark theme
+	scoped := func(selector string) string {
+		return strings.NewReplacer(".chroma", selector+" .chroma", ".bg ", selector+" .bg ").Replace(css.String())
+	}
+	return template.CSS(scoped(`:root[data-theme="dark"]`) +
+		"@media (prefers-color-scheme: dark) {\n" + scoped(`:root:not([data-theme="light"])`) + "}\n")
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// Inline directives that exclude known-divergent regions of a file from
+// the comparison. They are usually placed in comments.
+const (
+	directiveNextLine  = "gitparator:ignore-next-line"
+	directiveNextBlock = "gitparator:ignore-next-block" // up to the next blank line
+	directiveStart     = "gitparator:ignore-start"
+	directiveEnd       = "gitparator:ignore-end"
+)
+
+// stripIgnoredRegions removes the directive lines and the regions they
+// mark.
+func stripIgnoredRegions(content []byte) []byte {
+	lines := strings.SplitAfter(string(content), "\n")
+	var kept strings.Builder
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		switch {
+		case strings.Contains(line, directiveStart):
+			for i < len(lines) && !strings.Contains(lines[i], directiveEnd) {
+				i++
+			}
+		case strings.Contains(line, directiveNextLine):
+			i++
+		case strings.Contains(line, directiveNextBlock):
+			for i+1 < len(lines) && strings.TrimSpace(lines[i+1]) != "" {
+				i++
+			}
+		default:
+			kept.WriteString(line)
+		}
+	}
+	return []byte(kept.String())
+}
+
+// diffHunk is a run of consecutive changed lines. Its hash identifies the
+// change independently of where it occurs, so it can be suppressed with
+// ignore_hunks.
+type diffHunk struct {
+	Hash string
+}
+
+// diffHunks returns the hunks of the line diff of two contents.
+func diffHunks(content1, content2 []byte) []diffHunk {
+	dmp := diffmatchpatch.New()
+	chars1, chars2, linePatches := dmp.DiffLinesToChars(string(content1), string(content2))
+	lines := dmp.DiffCharsToLines(dmp.DiffMain(chars1, chars2, false), linePatches)
+
+	var hunks []diffHunk
+	var removed, inserted strings.Builder
+	flush := func() {
+		if removed.Len() == 0 && inserted.Len() == 0 {
+			return
+		}
+		hunks = append(hunks, diffHunk{Hash: hunkHash(removed.String(), inserted.String())})
+		removed.Reset()
+		inserted.Reset()
+	}
+	for _, diff := range lines {
+		switch diff.Type {
+		case diffmatchpatch.DiffDelete:
+			removed.WriteString(diff.Text)
+		case diffmatchpatch.DiffInsert:
+			inserted.WriteString(diff.Text)
+		case diffmatchpatch.DiffEqual:
+			flush()
+		}
+	}
+	flush()
+	return hunks
+}
+
+func hunkHash(removed, inserted string) string {
+	h := sha256.New()
+	h.Write([]byte(removed))
+	h.Write([]byte{0})
+	h.Write([]byte(inserted))
+	return hex.EncodeToString(h.Sum(nil))[:12]
+}
+
+// ignoredDifferencesOnly reports whether a pair differs only within regions
+// excluded by inline directives or in hunks listed in config.IgnoreHunks.
+func (run *runState) ignoredDifferencesOnly(pair filePair) bool {
+	config := run.config
+	ignoreHunks := config.IgnoreHunks
+	directive := []byte("gitparator:ignore-")
+	// Without hunks to match, only directives can make the pair equal, so
+	// the files are loaded only if a scan finds one
+	if len(ignoreHunks) == 0 {
+		inSource, err1 := fileContains(pair.SourceFile, directive)
+		inTarget, err2 := fileContains(pair.TargetFile, directive)
+		if err1 != nil || err2 != nil || (!inSource && !inTarget) {
+			return false
+		}
+	}
+	source, target, err := run.readPair(pair, config.NormalizeCmd)
+	if err != nil {
+		return false
+	}
+
+	if bytes.Contains(source, directive) || bytes.Contains(target, directive) {
+		source, target = stripIgnoredRegions(source), stripIgnoredRegions(target)
+		if bytes.Equal(source, target) {
+			return true
+		}
+	}
+	if len(ignoreHunks) == 0 {
+		return false
+	}
+
+	for _, hunk := range diffHunks(source, target) {
+		if !hunkIgnored(hunk.Hash, ignoreHunks) {
+			return false
+		}
+	}
+	return true
+}
+
+// hunkIgnored matches a hunk hash against the configured hashes, which may
+// be given as any prefix of at least 8 characters.
+func hunkIgnored(hash string, ignoreHunks []string) bool {
+	for _, h := range ignoreHunks {
+		h = strings.ToLower(strings.TrimSpace(h))
+		if len(h) >= 8 && strings.HasPrefix(hash, h) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,63 @@
+package main
+
+import "testing"
+
+func TestStripIgnoredRegions(t *testing.T) {
+	tests := []struct {
+		name, content, want string
+	}{
+		{"no directives", "a\nb\n", "a\nb\n"},
+		{"next line", "a\n// gitparator:ignore-next-line\nb\nc\n", "a\nc\n"},
+		{"next line at end of file", "a\n// gitparator:ignore-next-line\n", "a\n"},
+		{"next block", "a\n# gitparator:ignore-next-block\nb\nc\n\nd\n", "a\n\nd\n"},
+		{"next block at end of file", "a\n# gitparator:ignore-next-block\nb\nc", "a\n"},
+		{"next block as the last line", "a\n# gitparator:ignore-next-block", "a\n"},
+		{"start and end", "a\n/* gitparator:ignore-start */\nb\n/* gitparator:ignore-end */\nc\n", "a\nc\n"},
+		{"unterminated start", "a\n/* gitparator:ignore-start */\nb\nc\n", "a\n"},
+		{"end without start", "a\n/* gitparator:ignore-end */\nb\n", "a\n/* gitparator:ignore-end */\nb\n"},
+	}
+	for _, tt := range tests {
+		if got := string(stripIgnoredRegions([]byte(tt.content))); got != tt.want {
+			t.Errorf("%s: stripIgnoredRegions = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestHunkIgnored(t *testing.T) {
+	hash := "0123456789ab"
+	tests := []struct {
+		name   string
+		ignore []string
+		want   bool
+	}{
+		{"none", nil, false},
+		{"whole hash", []string{hash}, true},
+		{"eight characters", []string{"01234567"}, true},
+		{"prefix below eight characters", []string{"0123456"}, false},
+		{"empty", []string{""}, false},
+		{"case and spaces", []string{" 0123456789AB "}, true},
+		{"other hash", []string{"fedcba9876"}, false},
+		{"any of several", []string{"fedcba9876", "012345678"}, true},
+	}
+	for _, tt := range tests {
+		if got := hunkIgnored(hash, tt.ignore); got != tt.want {
+			t.Errorf("%s: hunkIgnored(%v) = %v, want %v", tt.name, tt.ignore, got, tt.want)
+		}
+	}
+}
+
+func TestDiffHunks(t *testing.T) {
+	// The same change hashes alike wherever it occurs
+	first := diffHunks([]byte("a\nold\nb\n"), []byte("a\nnew\nb\n"))
+	moved := diffHunks([]byte("x\ny\nold\n"), []byte("x\ny\nnew\n"))
+	if len(first) != 1 || len(moved) != 1 || first[0].Hash != moved[0].Hash {
+		t.Errorf("hunks = %v and %v, want one equal hash", first, moved)
+	}
+	if hunks := diffHunks([]byte("a\nb\n"), []byte("a\nb\n")); len(hunks) != 0 {
+		t.Errorf("hunks of equal contents = %v", hunks)
+	}
+	two := diffHunks([]byte("a\nb\nc\nd\n"), []byte("A\nb\nc\nD\n"))
+	if len(two) != 2 || two[0].Hash == two[1].Hash {
+		t.Errorf("hunks = %v, want two distinct", two)
+	}
+}
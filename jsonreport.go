@@ -0,0 +1,37 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// OutputFiles lists the report files of a run. Results of earlier versions
+// record a single file name, which still decodes.
+type OutputFiles []string
+
+func (o *OutputFiles) UnmarshalJSON(data []byte) error {
+	var file string
+	if err := json.Unmarshal(data, &file); err == nil {
+		*o = OutputFiles{file}
+		return nil
+	}
+	return json.Unmarshal(data, (*[]string)(o))
+}
+
+// generateJSONReport writes the comparison result, including its metadata,
+// as indented JSON.
+func generateJSONReport(result ComparisonResult, outputFile string) error {
+	data, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding result: %w", err)
+	}
+	w, err := createOutput(outputFile)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(append(data, '\n')); err != nil {
+		w.Close()
+		return fmt.Errorf("error writing output file: %w", err)
+	}
+	return w.Close()
+}
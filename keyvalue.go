@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"strings"
+	"unicode/utf8"
+)
+
+// keysOnlyValue stands in for every value with the ini-keys strategy, so
+// only the key sets are compared and no values end up in the report.
+const keysOnlyValue = "***"
+
+// parseKeyValues decodes an INI or .env file into a map from key to value.
+// Keys in a [section] are nested in a map under the section name. Comments,
+// blank lines, key order, an "export " prefix and quotes around values do
+// not matter; a repeated key keeps its last value.
+func parseKeyValues(content []byte, keysOnly bool) (map[string]any, error) {
+	if isBinary(content) || !utf8.Valid(content) {
+		return nil, errors.New("not a text file")
+	}
+	root := make(map[string]any)
+	current := root
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			name := strings.TrimSpace(line[1 : len(line)-1])
+			section, ok := root[name].(map[string]any)
+			if !ok {
+				section = make(map[string]any)
+				root[name] = section
+			}
+			current = section
+			continue
+		}
+
+		line = strings.TrimPrefix(line, "export ")
+		key, value := line, ""
+		if i := strings.IndexAny(line, "=:"); i >= 0 {
+			key, value = strings.TrimSpace(line[:i]), strings.TrimSpace(line[i+1:])
+		}
+		if keysOnly {
+			value = keysOnlyValue
+		}
+		current[key] = unquoteValue(value)
+	}
+	return root, scanner.Err()
+}
+
+// unquoteValue removes matching quotes around a value or, for an unquoted
+// value, a trailing comment.
+func unquoteValue(value string) string {
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		return value[1 : len(value)-1]
+	}
+	if i := strings.Index(value, " #"); i >= 0 {
+		value = value[:i]
+	}
+	if i := strings.Index(value, " ;"); i >= 0 {
+		value = value[:i]
+	}
+	return strings.TrimSpace(value)
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseKeyValues(t *testing.T) {
+	content := "; comment\nexport NAME=\"app\"\nPORT = 8080 # default\n\n[db]\nhost: 'localhost'\nhost = db\n[cache]\nsize=1\n"
+	want := map[string]any{
+		"NAME":  "app",
+		"PORT":  "8080",
+		"db":    map[string]any{"host": "db"},
+		"cache": map[string]any{"size": "1"},
+	}
+	got, err := parseKeyValues([]byte(content), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseKeyValues = %v, want %v", got, want)
+	}
+	if _, err := parseKeyValues([]byte("KEY=\x00\x01"), false); err == nil {
+		t.Error("parseKeyValues accepted binary content")
+	}
+}
+
+func TestKeyValueEqual(t *testing.T) {
+	tests := []struct {
+		name           string
+		source, target string
+		strategy       string
+		want           bool
+	}{
+		{"key order", "A=1\nB=2\n", "B=2\nA=1\n", strategyINISemantic, true},
+		{"section order", "[x]\na=1\n[y]\nb=2\n", "[y]\nb=2\n[x]\na=1\n", strategyINISemantic, true},
+		{"reopened section", "[x]\na=1\nb=2\n[y]\nc=3\n", "[x]\na=1\n[y]\nc=3\n[x]\nb=2\n", strategyINISemantic, true},
+		{"key moved to another section", "[x]\na=1\n[y]\n", "[x]\n[y]\na=1\n", strategyINISemantic, false},
+		{"key moved out of a section", "a=1\n[x]\n", "[x]\na=1\n", strategyINISemantic, false},
+		{"repeated key keeps its last value", "A=1\nA=2\n", "A=2\n", strategyINISemantic, true},
+		{"repeated key in the other order", "A=1\nA=2\n", "A=2\nA=1\n", strategyINISemantic, false},
+		{"quotes and export", "export A=\"x y\"\n", "A='x y'\n", strategyINISemantic, true},
+		{"changed value", "A=1\nB=2\n", "B=3\nA=1\n", strategyINISemantic, false},
+		{"changed value, keys only", "A=1\nB=2\n", "B=3\nA=1\n", strategyINIKeys, true},
+		{"added key, keys only", "A=1\n", "A=1\nB=2\n", strategyINIKeys, false},
+	}
+	for _, tt := range tests {
+		a, b, err := parseStructured([]byte(tt.source), []byte(tt.target), tt.strategy)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got := reflect.DeepEqual(a, b); got != tt.want {
+			t.Errorf("%s: equal = %v, want %v (%v and %v)", tt.name, got, tt.want, a, b)
+		}
+	}
+}
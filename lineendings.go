@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Line endings a line_endings rule may require.
+const (
+	eolLF   = "lf"
+	eolCRLF = "crlf"
+	eolAny  = "any"
+	eolMix  = "mixed" // found only
+)
+
+// LineEndingRule requires the line endings of the source files matching
+// glob Paths to be EOL: lf, crlf, or any to exempt them.
+type LineEndingRule struct {
+	Paths []string `mapstructure:"paths" json:"paths"`
+	EOL   string   `mapstructure:"eol" json:"eol"`
+}
+
+// LineEndingViolation is a source file whose line endings break the
+// line_endings policy, whatever the other tree has.
+type LineEndingViolation struct {
+	Path     string `json:"path"`
+	Expected string `json:"expected"` // lf or crlf
+	Found    string `json:"found"`    // lf, crlf or mixed
+	Line     int    `json:"line"`     // first line with an unexpected ending
+}
+
+func validateLineEndingRules(rules []LineEndingRule) error {
+	for i, rule := range rules {
+		switch rule.EOL {
+		case eolLF, eolCRLF, eolAny:
+		default:
+			return fmt.Errorf("line_endings %d: unknown eol %q (expected lf, crlf or any)", i+1, rule.EOL)
+		}
+		if len(rule.Paths) == 0 {
+			return fmt.Errorf("line_endings %d: no paths given", i+1)
+		}
+	}
+	return nil
+}
+
+// lineEndingFor returns the line ending required for relPath by the first
+// matching rule, or "" if no rule matches.
+func lineEndingFor(relPath string, rules []LineEndingRule) string {
+	relPath = toSlash(relPath)
+	for _, rule := range rules {
+		if shouldExclude(relPath, rule.Paths) {
+			return rule.EOL
+		}
+	}
+	return ""
+}
+
+// checkLineEndings checks a text file against the required line ending.
+// Binary files and files without line breaks pass.
+func checkLineEndings(relPath string, file sourceFile, expected string) *LineEndingViolation {
+	content, err := readFileContent(file)
+	if err != nil || isBinary(content) {
+		return nil
+	}
+
+	var lf, crlf, first int
+	line := 1
+	for i, c := range content {
+		if c != '\n' {
+			continue
+		}
+		ending := eolLF
+		if i > 0 && content[i-1] == '\r' {
+			ending = eolCRLF
+			crlf++
+		} else {
+			lf++
+		}
+		if ending != expected && first == 0 {
+			first = line
+		}
+		line++
+	}
+	if first == 0 {
+		return nil
+	}
+
+	found := eolLF
+	switch {
+	case lf > 0 && crlf > 0:
+		found = eolMix
+	case crlf > 0:
+		found = eolCRLF
+	}
+	return &LineEndingViolation{Path: relPath, Expected: expected, Found: found, Line: first}
+}
+
+// checkLineEndingPolicy checks the source files, given as relative path to
+// file, against the line_endings rules.
+func checkLineEndingPolicy(files map[string]sourceFile, rules []LineEndingRule) []LineEndingViolation {
+	var violations []LineEndingViolation
+	for relPath, file := range files {
+		expected := lineEndingFor(relPath, rules)
+		if expected == "" || expected == eolAny {
+			continue
+		}
+		if v := checkLineEndings(relPath, file, expected); v != nil {
+			violations = append(violations, *v)
+		}
+	}
+	sort.Slice(violations, func(i, j int) bool { return violations[i].Path < violations[j].Path })
+	return violations
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	gitconfig "github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+// defaultLockFile records the resolved target next to the default
+// configuration file.
+const defaultLockFile = defaultConfigFileBase + ".lock"
+
+// lockedRef is the local reference the locked commit is fetched into when
+// the clone does not contain it.
+const lockedRef = "refs/gitparator/locked"
+
+// TargetLock records the commit a cloned target resolved to, so that later
+// runs with --locked compare against exactly that commit.
+type TargetLock struct {
+	TargetURL  string    `json:"target_url"`
+	Branch     string    `json:"branch,omitempty"`
+	Tag        string    `json:"tag,omitempty"`
+	Commit     string    `json:"commit"`
+	ResolvedAt time.Time `json:"resolved_at"`
+}
+
+// readTargetLock reads the lock file and checks that it records the
+// target of config.
+func readTargetLock(file string, config *Config) (TargetLock, error) {
+	var lock TargetLock
+	data, err := os.ReadFile(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return lock, fmt.Errorf("--locked needs the lock file %s written by an earlier run", file)
+		}
+		return lock, fmt.Errorf("error reading lock file: %w", err)
+	}
+	if err := json.Unmarshal(data, &lock); err != nil {
+		return lock, fmt.Errorf("error parsing lock file %s: %w", file, err)
+	}
+	if !plumbing.IsHash(lock.Commit) {
+		return lock, fmt.Errorf("lock file %s records no valid commit", file)
+	}
+	if lock.TargetURL != config.TargetURL {
+		return lock, fmt.Errorf("lock file %s records target %s, not %s", file, lock.TargetURL, config.TargetURL)
+	}
+	return lock, nil
+}
+
+// writeTargetLock records the commit the target of config resolved to.
+func writeTargetLock(file string, config *Config, commit string) error {
+	lock := TargetLock{
+		TargetURL:  config.TargetURL,
+		Branch:     config.Branch,
+		Tag:        config.Tag,
+		Commit:     commit,
+		ResolvedAt: time.Now().UTC(),
+	}
+	data, err := json.MarshalIndent(lock, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(file, append(data, '\n'), 0644)
+}
+
+// checkoutLocked checks out the locked commit in the clone in targetDir.
+// A commit no longer at the tip of its branch may be missing from a
+// shallow clone; it is then fetched by its hash, which hosts such as
+// GitHub allow for reachable commits. Sideband output of the remote is
+// written to progress, which may be nil.
+func checkoutLocked(targetDir string, lock TargetLock, config *Config, progress io.Writer) error {
+	repo, err := git.PlainOpen(targetDir)
+	if err != nil {
+		return err
+	}
+	hash := plumbing.NewHash(lock.Commit)
+	if _, err := repo.CommitObject(hash); err != nil {
+		depth := config.CloneDepth
+		if config.FullHistory {
+			depth = 0
+		}
+		err = repo.Fetch(&git.FetchOptions{
+			RemoteName: "origin",
+			RefSpecs:   []gitconfig.RefSpec{gitconfig.RefSpec(fmt.Sprintf("+%s:%s", lock.Commit, lockedRef))},
+			Depth:      depth,
+			Progress:   progress,
+			Force:      true,
+		})
+		if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+			return fmt.Errorf("error fetching locked commit %s: %w", lock.Commit, err)
+		}
+	}
+
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return err
+	}
+	if err := worktree.Checkout(&git.CheckoutOptions{Hash: hash, Force: true}); err != nil {
+		return fmt.Errorf("error checking out locked commit %s: %w", lock.Commit, err)
+	}
+	return nil
+}
@@ -3,15 +3,20 @@ package main
 import (
 	"archive/zip"
 	"bufio"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
 	"io"
+	"io/fs"
 	"log"
 	"os"
 	"path/filepath"
+	"regexp"
 	"runtime/debug"
 	"sort"
 	"strings"
+	"time"
 
 	_ "embed"
 
@@ -28,27 +33,129 @@ import (
 var appVer string = ""
 
 type Config struct {
-	Version          string   `mapstructure:"version"`
-	TargetURL        string   `mapstructure:"target_url"`
-	TargetPath       string   `mapstructure:"target_path"`
-	TargetZip        string   `mapstructure:"target_zip"`
-	Branch           string   `mapstructure:"branch"`
-	Tag              string   `mapstructure:"tag"`
-	TempDir          string   `mapstructure:"temp_dir"`
-	OutputFile       string   `mapstructure:"output_file"`
-	ExcludePaths     []string `mapstructure:"exclude_paths"`
-	RespectGitignore bool     `mapstructure:"respect_gitignore"`
-	DetailedDiff     bool     `mapstructure:"detailed_diff"`
+	Version               string             `mapstructure:"version" json:"version"`
+	Profile               string             `mapstructure:"profile" json:"profile"`
+	SourceDir             string             `mapstructure:"source_dir" json:"source_dir"`
+	Target                string             `mapstructure:"target" json:"target"` // owner/repo[@ref] shorthand for a GitHub repository
+	TargetURL             string             `mapstructure:"target_url" json:"target_url"`
+	TargetPath            string             `mapstructure:"target_path" json:"target_path"`
+	TargetZip             string             `mapstructure:"target_zip" json:"target_zip"`
+	TargetZipSHA256       string             `mapstructure:"target_zip_sha256" json:"target_zip_sha256"`
+	VerifySidecars        bool               `mapstructure:"verify_sidecars" json:"verify_sidecars"`
+	SignatureKeyring      string             `mapstructure:"signature_keyring" json:"signature_keyring"`
+	TargetModule          string             `mapstructure:"target_module" json:"target_module"`
+	TargetManifest        string             `mapstructure:"target_manifest" json:"target_manifest"`
+	TargetRelease         string             `mapstructure:"target_release" json:"target_release"`
+	Repo                  string             `mapstructure:"repo" json:"repo"`
+	Forge                 string             `mapstructure:"forge" json:"forge"`
+	ReleaseAsset          string             `mapstructure:"asset" json:"asset"`
+	Branch                string             `mapstructure:"branch" json:"branch"`
+	Tag                   string             `mapstructure:"tag" json:"tag"`
+	TagPattern            string             `mapstructure:"tag_pattern" json:"tag_pattern"`
+	TempDir               string             `mapstructure:"temp_dir" json:"temp_dir"`
+	CloneDepth            int                `mapstructure:"clone_depth" json:"clone_depth"`
+	FullHistory           bool               `mapstructure:"full_history" json:"full_history"`
+	SingleBranch          bool               `mapstructure:"single_branch" json:"single_branch"`
+	ReuseClone            bool               `mapstructure:"reuse_clone" json:"reuse_clone"`
+	CacheFile             string             `mapstructure:"cache_file" json:"cache_file"`
+	LockFile              string             `mapstructure:"lock_file" json:"lock_file"`
+	Locked                bool               `mapstructure:"locked" json:"locked"`
+	MinFreeSpace          int64              `mapstructure:"min_free_space" json:"min_free_space"`
+	OutputFile            OutputFiles        `mapstructure:"output_file" json:"output_file"`
+	Format                string             `mapstructure:"format" json:"format"`
+	SourceZip             string             `mapstructure:"source_zip" json:"source_zip"`
+	SourceSubdir          string             `mapstructure:"source_subdir" json:"source_subdir"`
+	TargetSubdir          string             `mapstructure:"target_subdir" json:"target_subdir"`
+	ZipStripComponents    int                `mapstructure:"zip_strip_components" json:"zip_strip_components"`
+	PathMap               []PathMapping      `mapstructure:"path_map" json:"path_map"`
+	TemplateChain         []TemplateLevel    `mapstructure:"template_chain" json:"template_chain"`
+	CodeQualityFile       string             `mapstructure:"code_quality_file" json:"code_quality_file"`
+	Template              string             `mapstructure:"template" json:"template"`
+	Theme                 string             `mapstructure:"theme" json:"theme"`
+	ThemeVariables        ThemeVariables     `mapstructure:"theme_variables" json:"theme_variables"`
+	TemplateFunctions     []TemplateFunction `mapstructure:"template_functions" json:"template_functions"`
+	Base                  string             `mapstructure:"base" json:"base"`
+	Baseline              string             `mapstructure:"baseline" json:"baseline"`
+	ExcludePaths          []string           `mapstructure:"exclude_paths" json:"exclude_paths"`
+	CompareStrategies     []CompareStrategy  `mapstructure:"compare_strategies" json:"compare_strategies"`
+	CodeAware             bool               `mapstructure:"code_aware" json:"code_aware"`
+	VersionBumps          bool               `mapstructure:"version_bumps" json:"version_bumps"`
+	Recompressed          bool               `mapstructure:"recompressed" json:"recompressed"`
+	SemanticCompare       bool               `mapstructure:"semantic_compare" json:"semantic_compare"`
+	SubstituteTokens      []Substitution     `mapstructure:"substitute_tokens" json:"substitute_tokens"`
+	NormalizeCmd          []FormatterRule    `mapstructure:"normalize_cmd" json:"normalize_cmd"`
+	Normalize             []NormalizeRule    `mapstructure:"normalize" json:"normalize"`
+	LineEndings           []LineEndingRule   `mapstructure:"line_endings" json:"line_endings"`
+	Plugins               []Plugin           `mapstructure:"plugins" json:"plugins"`
+	RespectGitignore      bool               `mapstructure:"respect_gitignore" json:"respect_gitignore"`
+	IgnoreCase            bool               `mapstructure:"ignore_case" json:"ignore_case"`
+	OwnedBy               []string           `mapstructure:"owned_by" json:"owned_by"`
+	Workspaces            bool               `mapstructure:"workspaces" json:"workspaces"`
+	CodeownersFile        string             `mapstructure:"codeowners_file" json:"codeowners_file"`
+	DetailedDiff          bool               `mapstructure:"detailed_diff" json:"detailed_diff"`
+	SyntaxHighlight       bool               `mapstructure:"syntax_highlight" json:"syntax_highlight"`
+	DiffContext           int                `mapstructure:"diff_context" json:"diff_context"`
+	Difftool              string             `mapstructure:"difftool" json:"difftool"`
+	DifftoolPrompt        bool               `mapstructure:"difftool_prompt" json:"difftool_prompt"`
+	IntralineDiff         bool               `mapstructure:"intraline_diff" json:"intraline_diff"`
+	IgnoreHunks           []string           `mapstructure:"ignore_hunks" json:"ignore_hunks"`
+	RedactPatterns        []string           `mapstructure:"redact_patterns" json:"redact_patterns"`
+	EmbedMaxSize          int64              `mapstructure:"embed_max_size" json:"embed_max_size"`
+	RepoStats             bool               `mapstructure:"repo_stats" json:"repo_stats"`
+	ScanSecrets           bool               `mapstructure:"scan_secrets" json:"scan_secrets"`
+	SizeGrowthThreshold   float64            `mapstructure:"size_growth_threshold" json:"size_growth_threshold"`
+	DiffChunkLines        int                `mapstructure:"diff_chunk_lines" json:"diff_chunk_lines"`
+	LazyDiffs             bool               `mapstructure:"lazy_diffs" json:"lazy_diffs"`
+	MaxDiffLines          int                `mapstructure:"max_diff_lines" json:"max_diff_lines"`
+	Pairing               string             `mapstructure:"pairing" json:"pairing"`
+	SuggestMoves          bool               `mapstructure:"suggest_moves" json:"suggest_moves"`
+	Duplicates            bool               `mapstructure:"duplicates" json:"duplicates"`
+	MoveSimilarity        float64            `mapstructure:"move_similarity" json:"move_similarity"`
+	Progress              bool               `mapstructure:"progress" json:"progress"`
+	Verbose               bool               `mapstructure:"verbose" json:"verbose"`
+	NoColor               bool               `mapstructure:"no_color" json:"no_color"`
+	Preset                string             `mapstructure:"preset" json:"preset"`
+	IgnoreArchiveMetadata bool               `mapstructure:"ignore_archive_metadata" json:"ignore_archive_metadata"`
+	ExpectOwner           OwnerExpectation   `mapstructure:"expect_owner" json:"expect_owner"`
+	CommandLimits         CommandLimits      `mapstructure:"command_limits" json:"command_limits"`
+	ArchiveLimits         ArchiveLimits      `mapstructure:"archive_limits" json:"archive_limits"`
 }
 
 type ComparisonResult struct {
-	IdenticalFiles  []string
-	DifferentFiles  []string
-	SourceOnlyFiles []string
-	TargetOnlyFiles []string
-	SourceExcluded  []string
-	TargetExcluded  []string
-	Diffs           map[string]string
+	IdenticalFiles      []string                    `json:"identical_files"`
+	DifferentFiles      []string                    `json:"different_files"`
+	FormattingOnlyFiles []string                    `json:"formatting_only_files"`
+	VersionBumpFiles    []string                    `json:"version_bump_files"`
+	RecompressedFiles   []string                    `json:"recompressed_files"`
+	SourceOnlyFiles     []string                    `json:"source_only_files"`
+	TargetOnlyFiles     []string                    `json:"target_only_files"`
+	SourceExcluded      []string                    `json:"source_excluded"`
+	TargetExcluded      []string                    `json:"target_excluded"`
+	Diffs               map[string]string           `json:"-"`
+	differing           []filePair                  // for --difftool
+	unpaired            []filePair                  // source-only and target-only files, for patch output
+	equivalent          []filePair                  // formatting-only, version-bump and recompressed files, for patch output
+	Moved               map[string]string           `json:"moved"` // source path -> target path, for files paired across paths
+	Ambiguous           []AmbiguousPairing          `json:"ambiguous"`
+	PossibleMoves       []PossibleMove              `json:"possible_moves"`
+	StartedAt           time.Time                   `json:"started_at"`
+	Duration            time.Duration               `json:"duration"` // until the report was rendered
+	Timings             []PhaseTiming               `json:"timings"`
+	Metadata            RunMetadata                 `json:"metadata"`
+	Stats               RunStats                    `json:"stats"`
+	Drift               *BaselineDrift              `json:"drift,omitempty"`
+	Changes             map[string]string           `json:"changes,omitempty"` // side that changed each difference since --base: source, target or both
+	OwnershipIssues     []OwnershipIssue            `json:"ownership_issues"`
+	Anomalies           []Anomaly                   `json:"anomalies"`
+	LineEndings         []LineEndingViolation       `json:"line_ending_violations"`
+	Duplicates          []DuplicateGroup            `json:"duplicate_groups,omitempty"` // with --duplicates
+	Secrets             []SecretFinding             `json:"secrets"`
+	SizeDeltas          map[string]*SizeDelta       `json:"size_deltas"`         // for differing binary files
+	Encodings           map[string]FileEncoding     `json:"encodings,omitempty"` // for differing files in UTF-16 or Latin-1
+	Contents            map[string]EmbeddedContents `json:"contents,omitempty"`  // small differing files, with --embed-max-size
+	RepoStats           *RepoStats                  `json:"repo_stats,omitempty"`
+	Packages            []WorkspacePackage          `json:"packages,omitempty"` // with --workspaces
+	Origins             map[string]string           `json:"origins,omitempty"`  // template_chain level each difference originates from
 }
 
 const defaultConfigFileBase = ".gitparator" // no trailing .yaml or .yml here
@@ -56,8 +163,9 @@ const defaultConfigFileBase = ".gitparator" // no trailing .yaml or .yml here
 //go:embed templates/report.html
 var reportTemplate string
 
-func main() {
-	var config Config
+// newRootCommand creates the root command with all flags bound to viper.
+// config receives the settings when the command runs.
+func newRootCommand(config *Config) *cobra.Command {
 
 	rootCmd := &cobra.Command{
 		Use:     "gitparator",
@@ -93,13 +201,33 @@ func main() {
 				}
 			}
 
-			if configLoadedFromFile {
-				fmt.Println("Using config file:", viper.ConfigFileUsed())
+			// Overlay the selected profile onto the top-level settings
+			if profile := viper.GetString("profile"); profile != "" {
+				if err := selectProfile(profile); err != nil {
+					return err
+				}
+			}
+
+			// Unmarshal config (flags are bound, so this works without a config file too)
+			if err := viper.Unmarshal(config); err != nil {
+				return fmt.Errorf("failed to parse config file: %w", err)
+			}
 
-				// Unmarshal config
-				if err := viper.Unmarshal(&config); err != nil {
-					return fmt.Errorf("failed to parse config file: %w", err)
+			if err := applyPreset(cmd, config); err != nil {
+				return err
+			}
+
+			// Keep stdout for the report when it is written there
+			if writesToStdout(config) {
+				routeInfoToStderr()
+			}
+
+			if configLoadedFromFile {
+				info := io.Writer(os.Stdout)
+				if stdio, _ := cmd.Flags().GetBool("stdio"); stdio {
+					info = os.Stderr // stdout carries the responses
 				}
+				fmt.Fprintln(info, "Using config file:", viper.ConfigFileUsed())
 
 				err := checkConfigVersion(config.Version)
 				if err != nil {
@@ -109,41 +237,211 @@ func main() {
 			}
 			return nil
 		},
+		PreRunE: checkCombinations(config),
 		Run: func(cmd *cobra.Command, args []string) {
-			runMain(&config)
+			if stdio, _ := cmd.Flags().GetBool("stdio"); stdio {
+				if err := runStdio(*config); err != nil {
+					log.Fatalf("Error serving stdio requests: %v", err)
+				}
+				return
+			}
+			runMain(config)
 		},
 	}
+	rootCmd.SetFlagErrorFunc(func(cmd *cobra.Command, err error) error {
+		return usageError{err}
+	})
+	rootCmd.AddCommand(newPresetCommands(checkCombinations(config), func(cmd *cobra.Command, args []string) {
+		runMain(config)
+	})...)
+	rootCmd.AddCommand(newVerifyCommand(config))
+	rootCmd.AddCommand(newManifestCommand(config))
+	rootCmd.AddCommand(newRerunCommand(config))
+	rootCmd.AddCommand(newExplainCommand(config))
 
 	// Define flags and configuration settings
-	rootCmd.Flags().StringP("config", "c", "", fmt.Sprintf("config file (default is %s.yaml in current directory)", defaultConfigFileBase))
-	rootCmd.Flags().StringP("target-url", "u", "", "URL of the target repository")
-	rootCmd.Flags().StringP("target-path", "p", "", "Path to the target repository")
-	rootCmd.Flags().StringP("target-zip", "z", "", "Path to the zipped target repository")
-	rootCmd.Flags().StringP("branch", "b", "", "Branch to compare (ignored if --target-path or --target-zip is specified)")
-	rootCmd.Flags().StringP("tag", "t", "", "Tag to compare (ignored if --target-path or --target-zip is specified)")
-	rootCmd.Flags().StringP("temp-dir", "", ".gitparator_temp", "Temporary directory for cloning (ignored if --target-path or --target-zip is specified)")
-	rootCmd.Flags().StringP("output-file", "o", "report.html", "Output report file")
-	rootCmd.Flags().StringSliceP("exclude-paths", "e", []string{}, "Paths to exclude")
-	rootCmd.Flags().BoolP("respect-gitignore", "", true, "Respect .gitignore rules")
-	rootCmd.Flags().BoolP("detailed-diff", "d", false, "Generate detailed diffs for differing files")
+	rootCmd.PersistentFlags().StringP("config", "c", "", fmt.Sprintf("config file (default is %s.yaml in current directory)", defaultConfigFileBase))
+	rootCmd.Flags().BoolP("stdio", "", false, "Serve line-delimited JSON requests on stdin/stdout instead of running once")
+	rootCmd.PersistentFlags().StringP("profile", "P", "", "Named profile from the config file to run")
+	rootCmd.PersistentFlags().StringP("source-dir", "s", ".", "Local directory to compare, e.g. a build output directory")
+	rootCmd.PersistentFlags().StringP("target", "", "", "GitHub repository to compare with, as owner/repo or owner/repo@ref (a branch, tag, or tag pattern)")
+	rootCmd.PersistentFlags().StringP("target-url", "u", "", "URL of the target repository")
+	rootCmd.PersistentFlags().StringP("target-path", "p", "", "Path to the target repository")
+	rootCmd.PersistentFlags().StringP("target-zip", "z", "", "Path or http(s) URL of the zipped target repository (.zip, or a .tar, .tar.gz or .tgz tarball)")
+	rootCmd.PersistentFlags().StringP("target-zip-sha256", "", "", "Expected SHA-256 digest (hex) of --target-zip or the downloaded release archive")
+	rootCmd.PersistentFlags().BoolP("verify-sidecars", "", true, "Verify the target archive against the .sha256 and .asc files shipped next to it")
+	rootCmd.PersistentFlags().StringP("signature-keyring", "", "", "Armored public keys to check .asc signatures of the target archive with; a signature is then required")
+	rootCmd.PersistentFlags().StringP("target-module", "", "", "Go module to compare with, as path@version (or path@latest), downloaded from GOPROXY")
+	rootCmd.PersistentFlags().StringP("target-manifest", "", "", "Checksum manifest written by the manifest command to compare with, instead of a tree")
+	rootCmd.PersistentFlags().StringP("target-release", "", "", "Release of --repo to compare with: 'latest' or a tag name")
+	rootCmd.PersistentFlags().StringP("repo", "", "", "Forge repository (owner/name) used with --target-release")
+	rootCmd.PersistentFlags().StringP("forge", "", "github", "Forge hosting --repo: github or gitlab")
+	rootCmd.PersistentFlags().StringP("asset", "", "", "Release asset (name or glob) to compare with instead of the source archive")
+	rootCmd.PersistentFlags().StringP("source-zip", "", "", "Zip archive or tarball to compare instead of --source-dir (requires --target-zip or --target-release)")
+	rootCmd.PersistentFlags().StringP("source-subdir", "", "", "Compare only this subdirectory of the source directory")
+	rootCmd.PersistentFlags().StringP("target-subdir", "", "", "Compare only this subdirectory of the target")
+	rootCmd.PersistentFlags().IntP("zip-strip-components", "", -1, "Leading directories to strip from entries of --target-zip and --source-zip (-1 strips a single wrapping directory, as in GitHub archives, automatically)")
+	rootCmd.PersistentFlags().StringP("branch", "b", "", "Branch to compare (ignored if --target-path or --target-zip is specified)")
+	rootCmd.PersistentFlags().StringP("tag", "t", "", "Tag to compare (ignored if --target-path or --target-zip is specified)")
+	rootCmd.PersistentFlags().StringP("tag-pattern", "", "", "Compare against the highest semver tag matching this pattern, e.g. 'v1.*' (ignored if --target-path or --target-zip is specified)")
+	rootCmd.PersistentFlags().StringP("temp-dir", "", ".gitparator_temp", "Temporary directory for cloning (ignored if --target-path or --target-zip is specified)")
+	rootCmd.PersistentFlags().IntP("clone-depth", "", 1, "Number of commits to fetch when cloning (ignored if --target-path or --target-zip is specified)")
+	rootCmd.PersistentFlags().BoolP("full-history", "", false, "Clone the complete history instead of a shallow clone")
+	rootCmd.PersistentFlags().BoolP("single-branch", "", true, "Fetch only the requested branch or tag; disable to fetch all refs once for reuse")
+	rootCmd.PersistentFlags().BoolP("reuse-clone", "", false, "Keep the clone in --temp-dir and reuse it on later runs against the same URL")
+	rootCmd.PersistentFlags().StringP("cache-file", "", "", "File caching file hashes and comparison results between runs, so unchanged files are not re-read or re-diffed")
+	rootCmd.PersistentFlags().StringP("lock-file", "", defaultLockFile, "File recording the commit a cloned target resolved to; empty to not write one")
+	rootCmd.PersistentFlags().BoolP("locked", "", false, "Compare against the target commit recorded in the lock file instead of resolving the branch or tag again")
+	rootCmd.PersistentFlags().Int64P("min-free-space", "", 0, "Free space in MiB required in the temp directory before cloning (the forge-advertised size is used when larger)")
+	rootCmd.PersistentFlags().StringSliceP("output-file", "o", []string{"report.html"}, "Output report file, or - for stdout; repeat to write several formats, inferred from each extension (.json for the result, .patch or .diff for a patch, HTML otherwise)")
+	rootCmd.PersistentFlags().StringP("format", "", "", "Report format (html, json, patch) overriding the one inferred from the output file extension")
+	rootCmd.PersistentFlags().StringP("code-quality-file", "", "", "Also write a GitLab Code Quality report listing differing and missing files")
+	rootCmd.PersistentFlags().StringP("template", "", "", "HTML template used for the report instead of the built-in one")
+	rootCmd.PersistentFlags().StringP("theme", "", themeAuto, "Theme of the HTML report: auto (follows the reader's system setting), light or dark")
+	rootCmd.PersistentFlags().StringP("base", "", "", "Common ancestor revision (in the source or target repository); classify differences as changed in source, target, or both")
+	rootCmd.PersistentFlags().StringP("baseline", "", "", "JSON result of an earlier run; report new and resolved differences since then")
+	rootCmd.PersistentFlags().StringSliceP("exclude-paths", "e", []string{}, "Paths to exclude")
+	rootCmd.PersistentFlags().BoolP("respect-gitignore", "", true, "Respect .gitignore rules")
+	rootCmd.PersistentFlags().BoolP("workspaces", "", false, "Report drift per workspace package listed by go.work or pnpm-workspace.yaml in the source tree")
+	rootCmd.PersistentFlags().StringSliceP("owned-by", "", []string{}, "Compare only paths the source CODEOWNERS file assigns to these owners, e.g. @org/team")
+	rootCmd.PersistentFlags().StringP("codeowners-file", "", "", "CODEOWNERS file used with --owned-by instead of the one in the source repository")
+	rootCmd.PersistentFlags().BoolP("ignore-case", "", false, "Match .gitignore, tag and asset patterns case-insensitively, as git does on case-insensitive file systems")
+	rootCmd.PersistentFlags().BoolP("detailed-diff", "d", false, "Generate detailed diffs for differing files")
+	rootCmd.PersistentFlags().BoolP("syntax-highlight", "", true, "Colorize detailed diffs by language, detected from the file extension")
+	rootCmd.PersistentFlags().IntP("diff-context", "", -1, "Unchanged lines shown around each change in detailed diffs (-1 shows whole files)")
+	rootCmd.PersistentFlags().StringP("difftool", "", "", "External diff tool to open differing files with, e.g. \"code --diff {source} {target}\"")
+	rootCmd.PersistentFlags().BoolP("difftool-prompt", "", true, "Ask before opening each differing file in --difftool")
+	rootCmd.PersistentFlags().IntP("diff-chunk-lines", "", 1000, "Lines of a detailed diff rendered up front; the rest load as the report is scrolled (0 renders all)")
+	rootCmd.PersistentFlags().BoolP("lazy-diffs", "", true, "Embed detailed diffs in the HTML report as data rendered when a file is expanded, so large reports open quickly")
+	rootCmd.PersistentFlags().IntP("max-diff-lines", "", 0, "Maximum number of lines rendered per detailed diff (0 for no limit)")
+	rootCmd.PersistentFlags().BoolP("intraline-diff", "", true, "Highlight the changed words within modified lines in detailed diffs")
+	rootCmd.PersistentFlags().StringSliceP("ignore-hunks", "", []string{}, "Hashes (or 8+ character prefixes) of diff hunks that do not make files different")
+	rootCmd.PersistentFlags().StringSliceP("redact-patterns", "", []string{}, "Regular expressions whose matches are masked in detailed diffs")
+	rootCmd.PersistentFlags().Int64P("embed-max-size", "", 0, "Embed the contents of differing files up to this many bytes in JSON output (0 disables)")
+	rootCmd.PersistentFlags().BoolP("repo-stats", "", false, "Summarize both trees in the report: file counts, total size and lines of code per language")
+	rootCmd.PersistentFlags().BoolP("scan-secrets", "", false, "Flag changed and added lines that look like secrets (cloud keys, tokens, private keys)")
+	rootCmd.PersistentFlags().Float64P("size-growth-threshold", "", 0, "Flag binary files that grew by more than this percentage relative to the target (0 disables)")
+	rootCmd.PersistentFlags().BoolP("code-aware", "", false, "Report Go, JavaScript and Python files differing only in formatting as a separate category")
+	rootCmd.PersistentFlags().BoolP("version-bumps", "", false, "Report changelog, version and manifest files differing only by a release bump as a separate category")
+	rootCmd.PersistentFlags().BoolP("recompressed", "", false, "Report gzip, bzip2 and PNG files whose decompressed content is identical as a separate category")
+	rootCmd.PersistentFlags().BoolP("semantic-compare", "", false, "Compare .json, .yaml, .yml, .ini and .env files by their data, ignoring key order and formatting")
+	rootCmd.PersistentFlags().StringP("pairing", "", "path", "How files are paired across trees: path, basename, or content-hash")
+	rootCmd.PersistentFlags().BoolP("suggest-moves", "", false, "Suggest likely counterparts for unpaired files by path similarity")
+	rootCmd.PersistentFlags().Float64P("move-similarity", "", 0.4, "Minimum path similarity (0..1) for --suggest-moves")
+	rootCmd.PersistentFlags().BoolP("duplicates", "", false, "Report groups of files with the same content within and across the compared trees")
+	rootCmd.PersistentFlags().BoolP("ignore-archive-metadata", "", false, "Compare nested archives (zip, jar, tar, tar.gz, ...) by entry contents, ignoring timestamps, ownership and entry order")
+	rootCmd.PersistentFlags().IntP("expect-uid", "", -1, "Expected uid of all entries of a tarball target (-1 to skip the check)")
+	rootCmd.PersistentFlags().IntP("expect-gid", "", -1, "Expected gid of all entries of a tarball target (-1 to skip the check)")
+	rootCmd.PersistentFlags().StringP("expect-uname", "", "", "Expected owner name of all entries of a tarball target")
+	rootCmd.PersistentFlags().StringP("expect-gname", "", "", "Expected group name of all entries of a tarball target")
+	rootCmd.PersistentFlags().DurationP("command-timeout", "", 30*time.Second, "Time limit for each run of an external helper such as a normalize_cmd formatter (0 for no limit)")
+	rootCmd.PersistentFlags().Int64P("command-max-output", "", 64<<20, "Maximum bytes an external helper may write to stdout (0 for no limit)")
+	rootCmd.PersistentFlags().StringSliceP("command-env", "", []string{}, "Environment variables passed to external helpers besides PATH, HOME, temp and locale variables")
+	rootCmd.PersistentFlags().Int64P("archive-max-entry-size", "", 4<<30, "Maximum decompressed bytes of a single archive entry (0 for no limit)")
+	rootCmd.PersistentFlags().Int64P("archive-max-total-size", "", 16<<30, "Maximum decompressed bytes of all entries of an archive (0 for no limit)")
+	rootCmd.PersistentFlags().Float64P("archive-max-ratio", "", 1000, "Maximum ratio of decompressed to compressed size of archive entries over 1 MiB (0 for no limit)")
+	rootCmd.PersistentFlags().StringP("preset", "", "", "Apply a set of defaults tailored to a use case: drift, release, build-output, mirror or backup")
+	rootCmd.PersistentFlags().BoolP("verbose", "", false, "Print per-phase timings and throughput statistics at the end of the run")
+	rootCmd.PersistentFlags().BoolP("progress", "", true, "Report progress on stderr while cloning, scanning and comparing")
+	rootCmd.PersistentFlags().BoolP("no-color", "", false, "Print the diffstat summary without colors")
 
 	// Bind flags with viper
-	viper.BindPFlag("target_url", rootCmd.Flags().Lookup("target-url"))
-	viper.BindPFlag("target_path", rootCmd.Flags().Lookup("target-path"))
-	viper.BindPFlag("target_zip", rootCmd.Flags().Lookup("target-zip")) // New binding
-	viper.BindPFlag("branch", rootCmd.Flags().Lookup("branch"))
-	viper.BindPFlag("tag", rootCmd.Flags().Lookup("tag"))
-	viper.BindPFlag("temp_dir", rootCmd.Flags().Lookup("temp-dir"))
-	viper.BindPFlag("output_file", rootCmd.Flags().Lookup("output-file"))
-	viper.BindPFlag("exclude_paths", rootCmd.Flags().Lookup("exclude-paths"))
-	viper.BindPFlag("respect_gitignore", rootCmd.Flags().Lookup("respect-gitignore"))
-	viper.BindPFlag("detailed_diff", rootCmd.Flags().Lookup("detailed-diff"))
-
-	// Execute the command once
-	if err := rootCmd.Execute(); err != nil {
-		fmt.Println(err)
-		os.Exit(1)
-	}
+	bindFlag(rootCmd, "profile", "profile")
+	bindFlag(rootCmd, "source_dir", "source-dir")
+	bindFlag(rootCmd, "target", "target")
+	bindFlag(rootCmd, "target_url", "target-url")
+	bindFlag(rootCmd, "target_path", "target-path")
+	bindFlag(rootCmd, "target_zip", "target-zip") // New binding
+	bindFlag(rootCmd, "target_zip_sha256", "target-zip-sha256")
+	bindFlag(rootCmd, "verify_sidecars", "verify-sidecars")
+	bindFlag(rootCmd, "signature_keyring", "signature-keyring")
+	bindFlag(rootCmd, "target_module", "target-module")
+	bindFlag(rootCmd, "target_manifest", "target-manifest")
+	bindFlag(rootCmd, "target_release", "target-release")
+	bindFlag(rootCmd, "repo", "repo")
+	bindFlag(rootCmd, "forge", "forge")
+	bindFlag(rootCmd, "asset", "asset")
+	bindFlag(rootCmd, "source_zip", "source-zip")
+	bindFlag(rootCmd, "source_subdir", "source-subdir")
+	bindFlag(rootCmd, "target_subdir", "target-subdir")
+	bindFlag(rootCmd, "zip_strip_components", "zip-strip-components")
+	bindFlag(rootCmd, "branch", "branch")
+	bindFlag(rootCmd, "tag", "tag")
+	bindFlag(rootCmd, "tag_pattern", "tag-pattern")
+	bindFlag(rootCmd, "temp_dir", "temp-dir")
+	bindFlag(rootCmd, "clone_depth", "clone-depth")
+	bindFlag(rootCmd, "full_history", "full-history")
+	bindFlag(rootCmd, "single_branch", "single-branch")
+	bindFlag(rootCmd, "reuse_clone", "reuse-clone")
+	bindFlag(rootCmd, "cache_file", "cache-file")
+	bindFlag(rootCmd, "lock_file", "lock-file")
+	bindFlag(rootCmd, "locked", "locked")
+	bindFlag(rootCmd, "min_free_space", "min-free-space")
+	bindFlag(rootCmd, "output_file", "output-file")
+	bindFlag(rootCmd, "format", "format")
+	bindFlag(rootCmd, "code_quality_file", "code-quality-file")
+	bindFlag(rootCmd, "template", "template")
+	bindFlag(rootCmd, "theme", "theme")
+	bindFlag(rootCmd, "base", "base")
+	bindFlag(rootCmd, "baseline", "baseline")
+	bindFlag(rootCmd, "exclude_paths", "exclude-paths")
+	bindFlag(rootCmd, "respect_gitignore", "respect-gitignore")
+	bindFlag(rootCmd, "ignore_case", "ignore-case")
+	bindFlag(rootCmd, "owned_by", "owned-by")
+	bindFlag(rootCmd, "workspaces", "workspaces")
+	bindFlag(rootCmd, "codeowners_file", "codeowners-file")
+	bindFlag(rootCmd, "detailed_diff", "detailed-diff")
+	bindFlag(rootCmd, "syntax_highlight", "syntax-highlight")
+	bindFlag(rootCmd, "diff_context", "diff-context")
+	bindFlag(rootCmd, "difftool", "difftool")
+	bindFlag(rootCmd, "difftool_prompt", "difftool-prompt")
+	bindFlag(rootCmd, "diff_chunk_lines", "diff-chunk-lines")
+	bindFlag(rootCmd, "lazy_diffs", "lazy-diffs")
+	bindFlag(rootCmd, "max_diff_lines", "max-diff-lines")
+	bindFlag(rootCmd, "intraline_diff", "intraline-diff")
+	bindFlag(rootCmd, "ignore_hunks", "ignore-hunks")
+	bindFlag(rootCmd, "redact_patterns", "redact-patterns")
+	bindFlag(rootCmd, "embed_max_size", "embed-max-size")
+	bindFlag(rootCmd, "repo_stats", "repo-stats")
+	bindFlag(rootCmd, "scan_secrets", "scan-secrets")
+	bindFlag(rootCmd, "size_growth_threshold", "size-growth-threshold")
+	bindFlag(rootCmd, "code_aware", "code-aware")
+	bindFlag(rootCmd, "version_bumps", "version-bumps")
+	bindFlag(rootCmd, "recompressed", "recompressed")
+	bindFlag(rootCmd, "semantic_compare", "semantic-compare")
+	bindFlag(rootCmd, "pairing", "pairing")
+	bindFlag(rootCmd, "suggest_moves", "suggest-moves")
+	bindFlag(rootCmd, "duplicates", "duplicates")
+	bindFlag(rootCmd, "move_similarity", "move-similarity")
+	bindFlag(rootCmd, "ignore_archive_metadata", "ignore-archive-metadata")
+	bindFlag(rootCmd, "expect_owner.uid", "expect-uid")
+	bindFlag(rootCmd, "expect_owner.gid", "expect-gid")
+	bindFlag(rootCmd, "expect_owner.uname", "expect-uname")
+	bindFlag(rootCmd, "expect_owner.gname", "expect-gname")
+	bindFlag(rootCmd, "command_limits.timeout", "command-timeout")
+	bindFlag(rootCmd, "command_limits.max_output", "command-max-output")
+	bindFlag(rootCmd, "command_limits.env", "command-env")
+	bindFlag(rootCmd, "archive_limits.max_entry_size", "archive-max-entry-size")
+	bindFlag(rootCmd, "archive_limits.max_total_size", "archive-max-total-size")
+	bindFlag(rootCmd, "archive_limits.max_ratio", "archive-max-ratio")
+	bindFlag(rootCmd, "preset", "preset")
+	bindFlag(rootCmd, "verbose", "verbose")
+	bindFlag(rootCmd, "progress", "progress")
+	bindFlag(rootCmd, "no_color", "no-color")
+
+	markExclusiveFlags(rootCmd)
+	return rootCmd
+}
+
+// configKeyAnnotation annotates flags with the configuration key they are
+// bound to, so rerun can overlay them on recorded options.
+const configKeyAnnotation = "gitparator_config_key"
+
+// bindFlag binds the persistent flag name of cmd to a configuration key.
+func bindFlag(cmd *cobra.Command, key, name string) {
+	flags := cmd.PersistentFlags()
+	viper.BindPFlag(key, flags.Lookup(name))
+	flags.SetAnnotation(name, configKeyAnnotation, []string{key})
 }
 
 func checkConfigVersion(configVersion string) error {
@@ -178,88 +476,280 @@ func checkConfigVersion(configVersion string) error {
 	return nil
 }
 
-func runMain(config *Config) {
-	// Validate required configurations
-	if config.TargetZip != "" {
-		// TargetZip is specified, use the zip file as the target repository
-		if config.TargetURL != "" || config.TargetPath != "" {
-			fmt.Println("Error: Only one of --target-url, --target-path, or --target-zip should be specified.")
-			os.Exit(1)
+func runMain(config *Config) ComparisonResult {
+	run, err := startRun(config, os.Stdout)
+	if err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+	result, err := run.runComparison()
+	if err == nil {
+		err = run.report(result)
+	}
+	// Reports read the compared files, so the run ends after them
+	run.close()
+	if err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+	return result
+}
+
+// report writes the reports of a result to the configured output files and
+// prints a summary to stdout.
+func (run *runState) report(result ComparisonResult) error {
+	config := run.config
+
+	// Generate the reports in the formats implied by the output file names
+	stopRender := run.timings.Track("render")
+	var outputNames []string
+	for _, outputFile := range config.OutputFile {
+		outputFile = expandOutputFile(outputFile, config, result)
+		format, _ := reportFormat(outputFile, config.Format)
+		switch format {
+		case formatJSON:
+			if err := generateJSONReport(result, outputFile); err != nil {
+				return fmt.Errorf("error generating JSON report: %w", err)
+			}
+		case formatPatch:
+			if err := generatePatchReport(result, outputFile); err != nil {
+				return fmt.Errorf("error generating patch: %w", err)
+			}
+		default:
+			if err := generateHTMLReport(result, outputFile, config); err != nil {
+				return fmt.Errorf("error generating HTML report: %w", err)
+			}
+		}
+		outputNames = append(outputNames, outputName(outputFile))
+	}
+	if config.CodeQualityFile != "" {
+		if err := generateCodeQualityReport(result, config.CodeQualityFile); err != nil {
+			return fmt.Errorf("error generating code quality report: %w", err)
+		}
+	}
+	run.runReporters(result)
+	stopRender()
+
+	run.printDiffstat(os.Stdout, result.differing, useColor(config))
+	fmt.Printf("Comparison %s complete in %s. Report generated as %s\n",
+		result.Metadata.RunID, run.timings.Elapsed().Round(time.Millisecond), strings.Join(outputNames, ", "))
+	grown := 0
+	for _, d := range result.SizeDeltas {
+		if d.Exceeds {
+			grown++
+		}
+	}
+	if grown > 0 {
+		fmt.Printf("Warning: %d binary files grew beyond %g%%\n", grown, config.SizeGrowthThreshold)
+	}
+	if len(result.Secrets) > 0 {
+		fmt.Printf("Warning: %d possible secrets found in changed lines, see the report\n", len(result.Secrets))
+	}
+	if len(result.Anomalies) > 0 {
+		fmt.Printf("Warning: %d files with unsafe names were not compared, see the report\n", len(result.Anomalies))
+	}
+	if len(result.LineEndings) > 0 {
+		fmt.Printf("Warning: %d files violate the line ending policy, see the report\n", len(result.LineEndings))
+	}
+	if len(result.Duplicates) > 0 {
+		fmt.Printf("Found %d groups of files with the same content, see the report\n", len(result.Duplicates))
+	}
+	if result.Changes != nil {
+		counts := make(map[string]int)
+		for _, side := range result.Changes {
+			counts[side]++
+		}
+		fmt.Printf("Since base: %d changed only in source, %d only in target, %d diverged in both\n",
+			counts[changedInSource], counts[changedInTarget], counts[changedInBoth])
+	}
+	if result.Drift != nil {
+		fmt.Printf("Since baseline: %d new and %d resolved differences\n",
+			len(result.Drift.New), len(result.Drift.Resolved))
+	}
+	if result.Origins != nil {
+		counts := make(map[string]int)
+		for _, level := range result.Origins {
+			counts[level]++
 		}
-		if config.Branch != "" || config.Tag != "" {
-			fmt.Println("Warning: --branch and --tag options are ignored when --target-zip is specified.")
+		var levels []string
+		for level, n := range counts {
+			levels = append(levels, fmt.Sprintf("%s %d", level, n))
 		}
-		if _, err := os.Stat(config.TargetZip); os.IsNotExist(err) {
-			fmt.Printf("Error: target zip file '%s' does not exist.\n", config.TargetZip)
-			os.Exit(1)
+		sort.Strings(levels)
+		fmt.Printf("Differences by template level: %s\n", strings.Join(levels, ", "))
+	}
+	for _, p := range result.Packages {
+		status := "PASS"
+		if !p.Passed {
+			status = "FAIL"
 		}
+		fmt.Printf("%s %s (%s): %d different, %d source only, %d target only\n",
+			status, p.Name, p.Path, p.Different, p.SourceOnly, p.TargetOnly)
+	}
+	if config.Verbose {
+		printVerboseStats(os.Stdout, run.timings.Phases(), run.stats)
+	}
+	return nil
+}
+
+// runComparison resolves the target selected in the run's config and
+// compares the source directory with it. Temporary clones and downloads are
+// removed when the run is closed, unless clones are reused.
+func (run *runState) runComparison() (ComparisonResult, error) {
+	var result ComparisonResult
+	config := run.config
 
-		// Compare repositories
-		result := compareWithZip(".", config.TargetZip, config)
+	if config.SourceDir == "" {
+		config.SourceDir = "."
+	}
+	// Targets are resolved into config below; record the options as given
+	options := *config
 
-		// Generate HTML report
-		if err := generateHTMLReport(result, config.OutputFile); err != nil {
-			log.Fatalf("Error generating HTML report: %v", err)
+	var err error
+	if config.CacheFile != "" {
+		if run.cache, err = loadComparisonCache(config.CacheFile, config); err != nil {
+			return result, err
 		}
+	}
 
-		fmt.Printf("Comparison complete. Report generated as %s\n", config.OutputFile)
-		return
-	} else if config.TargetPath != "" {
-		// TargetPath is specified, use the local directory
-		if config.TargetURL != "" {
-			fmt.Println("Error: Only one of --target-url, --target-path, or --target-zip should be specified.")
-			os.Exit(1)
+	if config.SourceZip != "" {
+		if _, err := os.Stat(config.SourceZip); err != nil {
+			return result, fmt.Errorf("source zip file '%s' does not exist", config.SourceZip)
 		}
-		if config.Branch != "" || config.Tag != "" {
-			fmt.Println("Warning: --branch and --tag options are ignored when --target-path is specified.")
+	} else if err := checkSubdir(config.SourceDir, config.SourceSubdir, "source"); err != nil {
+		return result, err
+	}
+
+	if len(config.OwnedBy) > 0 {
+		if run.owned, err = loadCodeOwners(config); err != nil {
+			return result, err
 		}
-		if _, err := os.Stat(config.TargetPath); os.IsNotExist(err) {
-			fmt.Printf("Error: target path '%s' does not exist.\n", config.TargetPath)
-			os.Exit(1)
+		fmt.Fprintf(run.out, "Comparing paths owned by %s in %s\n", strings.Join(config.OwnedBy, ", "), run.owned.file)
+	}
+
+	// Read the workspace up front so a missing or bad file fails before any cloning
+	var packages []WorkspacePackage
+	if config.Workspaces {
+		if config.SourceZip != "" {
+			return result, errors.New("--workspaces needs a source directory")
 		}
+		if packages, err = findWorkspacePackages(filepath.Join(config.SourceDir, config.SourceSubdir)); err != nil {
+			return result, err
+		}
+	}
 
-		// Compare repositories
-		result := compareRepos(".", config.TargetPath, config)
+	// Load the baseline up front so a bad file fails before any cloning
+	var baseline ComparisonResult
+	if config.Baseline != "" {
+		if baseline, err = loadBaseline(config.Baseline); err != nil {
+			return result, err
+		}
+	}
 
-		// Generate HTML report
-		if err := generateHTMLReport(result, config.OutputFile); err != nil {
-			log.Fatalf("Error generating HTML report: %v", err)
+	// Make the target available through the resolver of its kind
+	target := &run.target
+	if err := run.resolveTarget(target); err != nil {
+		return result, err
+	}
+	switch {
+	case target.Manifest != "":
+		if result, err = run.compareWithManifest(config.SourceDir, target.Manifest); err != nil {
+			return result, err
+		}
+	case target.Archive != "" && config.SourceZip != "":
+		if result, err = run.compareZips(config.SourceZip, target.Archive); err != nil {
+			return result, err
 		}
+	case target.Archive != "":
+		if result, err = run.compareWithZip(config.SourceDir, target.Archive); err != nil {
+			return result, err
+		}
+	default:
+		if result, err = run.compareRepos(config.SourceDir, target.Dir); err != nil {
+			return result, err
+		}
+	}
+	targetLocation, targetRepoDir := target.Location, target.Dir
 
-		fmt.Printf("Comparison complete. Report generated as %s\n", config.OutputFile)
-		return
-	} else if config.TargetURL != "" {
-		// TargetURL is specified, clone the repository
-		if config.TempDir == "" {
-			config.TempDir = "gitparator_temp"
+	// Attribute each difference to a side, while clones still exist
+	if config.Base != "" {
+		var sourceTree, targetTree string
+		if config.SourceZip == "" {
+			sourceTree = filepath.Join(config.SourceDir, config.SourceSubdir)
 		}
-		targetDir := config.TempDir
-		if err := cloneRepo(config, targetDir); err != nil {
-			log.Fatalf("Error cloning target repository: %v", err)
+		if targetRepoDir != "" {
+			targetTree = filepath.Join(targetRepoDir, config.TargetSubdir)
+		}
+		base, err := openBaseTree(config.Base, sourceTree, targetTree)
+		if err != nil {
+			return result, err
 		}
-		defer os.RemoveAll(targetDir)
+		classifyChanges(&result, base)
+	}
 
-		// Compare repositories
-		result := compareRepos(".", targetDir, config)
+	// Attribute each difference to a level of the template chain
+	if len(config.TemplateChain) > 0 {
+		if targetRepoDir == "" {
+			return result, errors.New("template_chain needs a target directory (--target-url or --target-path)")
+		}
+		chain, err := openTemplateChain(config, targetRepoDir, run.progress.Writer())
+		if err != nil {
+			return result, err
+		}
+		defer chain.close()
+		attributeOrigins(&result, chain, config)
+	}
 
-		// Generate HTML report
-		if err := generateHTMLReport(result, config.OutputFile); err != nil {
-			log.Fatalf("Error generating HTML report: %v", err)
+	if config.Workspaces {
+		result.Packages = summarizePackages(packages, &result)
+	}
+
+	result.Metadata = collectMetadata(config, targetLocation, targetRepoDir)
+	result.Metadata.Config = options
+	result.Metadata.TargetVerification = target.Verification
+	result.Metadata.RunID = runID(&result, config)
+	// Record the resolved commit for later runs with --locked
+	if config.TargetURL != "" && !config.Locked && config.LockFile != "" && result.Metadata.Target.Commit != "" {
+		if err := writeTargetLock(config.LockFile, config, result.Metadata.Target.Commit); err != nil {
+			fmt.Fprintf(run.out, "Warning: cannot write lock file: %v\n", err)
 		}
+	}
+	result.StartedAt = run.timings.Started()
+	result.Duration = run.timings.Elapsed()
+	result.Timings = run.timings.Phases()
+	run.stats.finalize(result.Timings, result.Duration)
+	result.Stats = run.stats
+	if config.Baseline != "" {
+		result.Drift = computeDrift(config.Baseline, baseline, result)
+	}
+	if err := run.cache.save(); err != nil {
+		fmt.Fprintf(run.out, "Warning: cannot write cache file: %v\n", err)
+	}
 
-		fmt.Printf("Comparison complete. Report generated as %s\n", config.OutputFile)
-		return
-	} else {
-		fmt.Println("Error: one of --target-url, --target-path, or --target-zip must be specified.")
-		os.Exit(1)
+	// Open the diff tool while clones and downloads still exist
+	if config.Difftool != "" {
+		if err := runDifftool(result.differing, config, os.Stdin); err != nil {
+			return result, err
+		}
 	}
+	return result, nil
 }
 
-func cloneRepo(config *Config, targetDir string) error {
+// cloneRepo clones the target of config into targetDir. Sideband output of
+// the remote is written to progress, which may be nil.
+func cloneRepo(config *Config, targetDir string, progress io.Writer) error {
+	depth := config.CloneDepth
+	if config.FullHistory {
+		depth = 0 // go-git fetches the complete history for a zero depth
+	} else if depth < 1 {
+		return fmt.Errorf("invalid clone depth %d (use --full-history to fetch all commits)", depth)
+	}
+
 	cloneOptions := &git.CloneOptions{
 		URL:          config.TargetURL,
-		Depth:        1, // Shallow clone
-		SingleBranch: true,
+		Depth:        depth,
+		SingleBranch: config.SingleBranch,
+		Progress:     progress,
 	}
 
 	if config.Branch != "" {
@@ -272,15 +762,27 @@ func cloneRepo(config *Config, targetDir string) error {
 	return err
 }
 
-func compareRepos(sourceDir, targetDir string, config *Config) ComparisonResult {
+func (run *runState) compareRepos(sourceDir, targetDir string) (ComparisonResult, error) {
+	config := run.config
 	result := ComparisonResult{
-		Diffs: make(map[string]string),
+		Diffs:      make(map[string]string),
+		Moved:      make(map[string]string),
+		SizeDeltas: make(map[string]*SizeDelta),
+		Contents:   make(map[string]EmbeddedContents),
 	}
 
-	sourceFiles, sourceExcluded := getAllFilesFromDir(sourceDir, config.ExcludePaths, config.RespectGitignore)
-	targetFiles, targetExcluded := getAllFilesFromDir(targetDir, config.ExcludePaths, config.RespectGitignore)
+	sourceDir = filepath.Join(sourceDir, config.SourceSubdir)
+	targetDir = filepath.Join(targetDir, config.TargetSubdir)
+
+	stopScan := run.timings.Track("scan")
+	sourceFiles, sourceExcluded := getAllFilesFromDir(sourceDir, config, run.progress)
+	targetFiles, targetExcluded := getAllFilesFromDir(targetDir, config, run.progress)
+	stopScan()
+	run.stats.FilesScanned += len(sourceFiles) + len(targetFiles)
 
-	compareFileLists(sourceFiles, targetFiles, sourceDir, targetDir, config, &result)
+	if err := run.compareFileLists(sourceFiles, targetFiles, "", "", &result); err != nil {
+		return result, err
+	}
 
 	// Add excluded files to the result
 	result.SourceExcluded = sourceExcluded
@@ -288,90 +790,405 @@ func compareRepos(sourceDir, targetDir string, config *Config) ComparisonResult
 	sort.Strings(result.SourceExcluded)
 	sort.Strings(result.TargetExcluded)
 
-	return result
+	return result, nil
 }
 
-func compareWithZip(sourceDir, zipPath string, config *Config) ComparisonResult {
+func (run *runState) compareWithZip(sourceDir, zipPath string) (ComparisonResult, error) {
+	config := run.config
 	result := ComparisonResult{
-		Diffs: make(map[string]string),
+		Diffs:      make(map[string]string),
+		Moved:      make(map[string]string),
+		SizeDeltas: make(map[string]*SizeDelta),
+		Contents:   make(map[string]EmbeddedContents),
 	}
 
-	sourceFiles, sourceExcluded := getAllFilesFromDir(sourceDir, config.ExcludePaths, config.RespectGitignore)
-	targetFiles, targetExcluded := getAllFilesFromZip(zipPath, config.ExcludePaths, config.RespectGitignore)
+	sourceDir = filepath.Join(sourceDir, config.SourceSubdir)
 
-	compareFileLists(sourceFiles, targetFiles, sourceDir, zipPath, config, &result)
+	stopScan := run.timings.Track("scan")
+	sourceFiles, sourceExcluded := getAllFilesFromDir(sourceDir, config, run.progress)
+	targetFiles, targetExcluded, prefix, err := run.scanArchive(zipPath, config.TargetSubdir, sourceDir)
+	stopScan()
+	if err != nil {
+		return result, err
+	}
+	run.stats.FilesScanned += len(sourceFiles) + len(targetFiles)
+
+	if err := run.compareFileLists(sourceFiles, targetFiles, "", prefix, &result); err != nil {
+		return result, err
+	}
+
+	if isTarball(zipPath) && config.ExpectOwner.enabled() {
+		issues, err := run.checkTarballOwnership(zipPath, config.ExpectOwner, config.ExcludePaths)
+		if err != nil {
+			return result, fmt.Errorf("error checking tarball ownership: %w", err)
+		}
+		result.OwnershipIssues = issues
+	}
 
 	// Add excluded files to the result
 	result.SourceExcluded = sourceExcluded
-	result.TargetExcluded = targetExcluded
+	result.TargetExcluded = scopeArchiveNames(targetExcluded, prefix)
 	sort.Strings(result.SourceExcluded)
 	sort.Strings(result.TargetExcluded)
 
-	return result
+	return result, nil
 }
 
-func compareFileLists(sourceFiles, targetFiles []string, sourceDir, targetDir string, config *Config, result *ComparisonResult) {
-	sourceMap := make(map[string]string)
-	targetMap := make(map[string]string)
+// compareZips compares the entries of two archives, such as two release
+// artifacts, without extracting them.
+func (run *runState) compareZips(sourceZip, targetZip string) (ComparisonResult, error) {
+	config := run.config
+	result := ComparisonResult{
+		Diffs:      make(map[string]string),
+		Moved:      make(map[string]string),
+		SizeDeltas: make(map[string]*SizeDelta),
+		Contents:   make(map[string]EmbeddedContents),
+	}
 
-	for _, file := range sourceFiles {
-		relativePath, err := filepath.Rel(sourceDir, file)
+	stopScan := run.timings.Track("scan")
+	sourceFiles, sourceExcluded, sourcePrefix, err := run.scanArchive(sourceZip, config.SourceSubdir, "")
+	if err != nil {
+		stopScan()
+		return result, err
+	}
+	targetFiles, targetExcluded, targetPrefix, err := run.scanArchive(targetZip, config.TargetSubdir, "")
+	stopScan()
+	if err != nil {
+		return result, err
+	}
+	run.stats.FilesScanned += len(sourceFiles) + len(targetFiles)
+
+	if err := run.compareFileLists(sourceFiles, targetFiles, sourcePrefix, targetPrefix, &result); err != nil {
+		return result, err
+	}
+
+	if isTarball(targetZip) && config.ExpectOwner.enabled() {
+		issues, err := run.checkTarballOwnership(targetZip, config.ExpectOwner, config.ExcludePaths)
 		if err != nil {
-			log.Printf("Error getting relative path for %s: %v", file, err)
+			return result, fmt.Errorf("error checking tarball ownership: %w", err)
+		}
+		result.OwnershipIssues = issues
+	}
+
+	result.SourceExcluded = scopeArchiveNames(sourceExcluded, sourcePrefix)
+	result.TargetExcluded = scopeArchiveNames(targetExcluded, targetPrefix)
+	sort.Strings(result.SourceExcluded)
+	sort.Strings(result.TargetExcluded)
+
+	return result, nil
+}
+
+// scanArchive lists the entries of a zip or tarball and returns them with
+// the excluded entry names and the prefix of the compared entries: the
+// stripped wrapping directories followed by subdir. A wrapping directory
+// is not stripped automatically if sourceDir has a directory of that name.
+func (run *runState) scanArchive(archivePath, subdir, sourceDir string) (files []sourceFile, excluded []string, prefix string, err error) {
+	config := run.config
+	if isTarball(archivePath) {
+		files, excluded, err = run.getAllFilesFromTarball(archivePath, config.ExcludePaths, config.RespectGitignore)
+	} else {
+		files, excluded, err = run.getAllFilesFromZip(archivePath, config.ExcludePaths, config.RespectGitignore)
+	}
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	// Strip wrapping directories, such as repo-branch/ in GitHub archives
+	names := append([]string{}, excluded...)
+	for _, file := range files {
+		names = append(names, file.name)
+	}
+	root, err := archiveRoot(names, config.ZipStripComponents, sourceDir)
+	if err != nil {
+		return nil, nil, "", fmt.Errorf("error stripping leading directories of %s: %w", archivePath, err)
+	}
+	if config.ZipStripComponents < 0 && strings.HasPrefix(archivePrefix(subdir), root) {
+		root = "" // the subdirectory already names the wrapping directory
+	}
+	if root != "" {
+		if config.ZipStripComponents < 0 {
+			fmt.Fprintf(run.out, "Stripping top-level directory '%s' of %s\n", strings.TrimSuffix(root, "/"), archivePath)
+		}
+		// Exclusions apply to the stripped names, as they would in a clone
+		included := files[:0]
+		for _, file := range files {
+			if shouldExclude(strings.TrimPrefix(file.name, root), config.ExcludePaths) {
+				excluded = append(excluded, file.name)
+			} else {
+				included = append(included, file)
+			}
+		}
+		files = included
+	}
+	return files, excluded, root + archivePrefix(subdir), nil
+}
+
+// relativeName returns the name of file relative to prefix, the compared
+// directory of its tree. ok is false for files outside prefix.
+func relativeName(file sourceFile, prefix string) (name string, ok bool) {
+	if !strings.HasPrefix(file.name, prefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(file.name, prefix), true
+}
+
+// compareFileLists compares the listed files of both trees. Files are
+// paired by their names below sourcePrefix and targetPrefix.
+func (run *runState) compareFileLists(sourceFiles, targetFiles []sourceFile, sourcePrefix, targetPrefix string, result *ComparisonResult) error {
+	config := run.config
+	sourceMap := make(map[string]sourceFile)
+	targetMap := make(map[string]sourceFile)
+
+	// Source paths are paired under their mapped names and restored after
+	sourceNames := make(map[string]string)
+	for _, file := range sourceFiles {
+		relativePath, ok := relativeName(file, sourcePrefix)
+		if !ok || !checkPath(result, relativePath, "source") || (run.owned != nil && !run.owned.owns(relativePath)) {
 			continue
 		}
-		sourceMap[relativePath] = file
+		mapped := relativePath
+		if len(config.PathMap) > 0 {
+			mapped = mapPath(relativePath, config.PathMap)
+		}
+		sourceMap[mapped] = file
+		sourceNames[mapped] = relativePath
 	}
 
 	for _, file := range targetFiles {
-		relativePath, err := filepath.Rel(targetDir, file)
-		if err != nil {
-			log.Printf("Error getting relative path for %s: %v", file, err)
-			continue
+		if relativePath, ok := relativeName(file, targetPrefix); ok && checkPath(result, relativePath, "target") && (run.owned == nil || run.owned.owns(relativePath)) {
+			targetMap[run.substitutePath(relativePath)] = file
+		}
+	}
+
+	if config.RepoStats {
+		stopStats := run.timings.Track("stats")
+		result.RepoStats = collectRepoStats(sourceMap, targetMap)
+		stopStats()
+	}
+
+	pairs, sourceOnly, targetOnly, ambiguous, err := pairFiles(sourceMap, targetMap, config.Pairing)
+	if err != nil {
+		return fmt.Errorf("error pairing files: %w", err)
+	}
+	if len(config.PathMap) > 0 {
+		for i := range pairs {
+			pairs[i].SourcePath = sourceNames[pairs[i].SourcePath]
+		}
+		for i, p := range sourceOnly {
+			sourceOnly[i] = sourceNames[p]
+		}
+		for _, a := range ambiguous {
+			for i, p := range a.Source {
+				a.Source[i] = sourceNames[p]
+			}
+		}
+		sort.Strings(sourceOnly)
+		restored := make(map[string]sourceFile, len(sourceMap))
+		for mapped, file := range sourceMap {
+			restored[sourceNames[mapped]] = file
+		}
+		sourceMap = restored
+	}
+	result.SourceOnlyFiles = append(result.SourceOnlyFiles, sourceOnly...)
+	result.TargetOnlyFiles = append(result.TargetOnlyFiles, targetOnly...)
+	for _, p := range sourceOnly {
+		result.unpaired = append(result.unpaired, filePair{SourcePath: p, SourceFile: sourceMap[p]})
+	}
+	for _, p := range targetOnly {
+		result.unpaired = append(result.unpaired, filePair{TargetPath: p, TargetFile: targetMap[p]})
+	}
+	result.Ambiguous = ambiguous
+	if config.SuggestMoves {
+		result.PossibleMoves = suggestMoves(sourceOnly, targetOnly, config.MoveSimilarity)
+	}
+	if config.EmbedMaxSize > 0 {
+		for _, p := range sourceOnly {
+			if e := run.embedOneSided(sourceMap[p], config.EmbedMaxSize); e != nil {
+				result.Contents[p] = EmbeddedContents{Source: e}
+			}
+		}
+		for _, p := range targetOnly {
+			if e := run.embedOneSided(targetMap[p], config.EmbedMaxSize); e != nil {
+				result.Contents[p] = EmbeddedContents{Target: e}
+			}
 		}
-		targetMap[relativePath] = file
+	}
+	if config.ScanSecrets {
+		stopSecrets := run.timings.Track("secrets")
+		for _, p := range sourceOnly {
+			result.Secrets = append(result.Secrets, scanSecretsInFile(p, "source", sourceMap[p])...)
+		}
+		for _, p := range targetOnly {
+			result.Secrets = append(result.Secrets, scanSecretsInFile(p, "target", targetMap[p])...)
+		}
+		stopSecrets()
+	}
+
+	if len(config.LineEndings) > 0 {
+		stopPolicy := run.timings.Track("line endings")
+		result.LineEndings = checkLineEndingPolicy(sourceMap, config.LineEndings)
+		stopPolicy()
+	}
+	if config.Duplicates {
+		stopDuplicates := run.timings.Track("duplicates")
+		result.Duplicates = findDuplicates(sourceMap, targetMap)
+		stopDuplicates()
 	}
 
-	for path, sourceFile := range sourceMap {
-		if targetFile, exists := targetMap[path]; exists {
-			if filesAreEqual(sourceFile, targetFile) {
-				result.IdenticalFiles = append(result.IdenticalFiles, path)
+	run.progress.Start("Comparing", len(pairs))
+	for _, pair := range pairs {
+		run.progress.Add(1)
+		path := pair.SourcePath
+		if pair.TargetPath != pair.SourcePath {
+			result.Moved[path] = pair.TargetPath
+		}
+		stopCompare := run.timings.Track("compare")
+		strategy := comparisonStrategy(pair.SourcePath, config)
+		cacheKey, cached, hit := run.cache.lookup(pair)
+		if hit {
+			run.stats.CacheHits++
+		} else {
+			cached.Outcome = outcomeDifferent
+			if run.filesAreEqual(pair.SourceFile, pair.TargetFile) {
+				cached.Outcome = outcomeIdentical
+			} else if equal, diff, ok := run.pluginCompare(pair); ok {
+				if equal {
+					cached.Outcome = outcomeIdentical
+				}
+				cached.Diff = diff
+			} else if strategyEqual(pair, strategy) ||
+				run.preparedEqual(pair, config.NormalizeCmd) ||
+				run.ignoredDifferencesOnly(pair) ||
+				(len(run.normalizers) > 0 && run.normalizedEqual(pair)) ||
+				(config.IgnoreArchiveMetadata && archiveContentsEqual(pair.SourceFile, pair.TargetFile, config.ArchiveLimits)) {
+				cached.Outcome = outcomeIdentical
+			} else if config.Recompressed && recompressedOnly(pair, config.ArchiveLimits) {
+				cached.Outcome = outcomeRecompressed
+			} else if config.CodeAware && formattingOnlyDifference(pair) {
+				cached.Outcome = outcomeFormatting
+			} else if config.VersionBumps && run.versionBumpOnly(pair) {
+				cached.Outcome = outcomeVersionBump
+			}
+		}
+		stopCompare()
+		if cached.Outcome == outcomeIdentical {
+			result.IdenticalFiles = append(result.IdenticalFiles, path)
+		} else if cached.Outcome == outcomeRecompressed {
+			result.RecompressedFiles = append(result.RecompressedFiles, path)
+			result.equivalent = append(result.equivalent, pair)
+		} else if cached.Outcome == outcomeFormatting || cached.Outcome == outcomeVersionBump {
+			result.equivalent = append(result.equivalent, pair)
+			if cached.Outcome == outcomeFormatting {
+				result.FormattingOnlyFiles = append(result.FormattingOnlyFiles, path)
 			} else {
-				result.DifferentFiles = append(result.DifferentFiles, path)
-				if config.DetailedDiff {
-					diff := getFileDiff(sourceFile, targetFile)
-					result.Diffs[path] = diff
+				result.VersionBumpFiles = append(result.VersionBumpFiles, path)
+			}
+			if config.DetailedDiff {
+				stopDiff := run.timings.Track("diff")
+				if !hit {
+					cached.Diff = run.getFileDiff(pair)
 				}
+				result.Diffs[path] = cached.Diff
+				stopDiff()
 			}
-			delete(targetMap, path)
 		} else {
-			result.SourceOnlyFiles = append(result.SourceOnlyFiles, path)
+			result.DifferentFiles = append(result.DifferentFiles, path)
+			result.differing = append(result.differing, pair)
+			// Sizes and encodings come from the start of each file; the
+			// whole files are read once, and only for the checks needing them
+			source, err1 := sniffFile(pair.SourceFile)
+			target, err2 := sniffFile(pair.TargetFile)
+			sniffed := err1 == nil && err2 == nil
+			embed := config.EmbedMaxSize > 0 && sniffed &&
+				source.size <= config.EmbedMaxSize && target.size <= config.EmbedMaxSize
+			if embed || config.ScanSecrets {
+				sourceContent, err1 := readFileContent(pair.SourceFile)
+				targetContent, err2 := readFileContent(pair.TargetFile)
+				readable := err1 == nil && err2 == nil
+				if embed && readable {
+					run.embedContents(result, path, sourceContent, targetContent, config.EmbedMaxSize)
+				}
+				if config.ScanSecrets && readable {
+					stopSecrets := run.timings.Track("secrets")
+					result.Secrets = append(result.Secrets, scanSecretsInChanges(path, sourceContent, targetContent)...)
+					stopSecrets()
+				}
+			}
+			var delta *SizeDelta
+			var encoding *FileEncoding
+			if sniffed {
+				delta = binarySizeDelta(source, target, config.SizeGrowthThreshold)
+				encoding = pairEncoding(source, target)
+			}
+			binary := delta != nil
+			if binary {
+				result.SizeDeltas[path] = delta
+			}
+			if encoding != nil {
+				if result.Encodings == nil {
+					result.Encodings = make(map[string]FileEncoding)
+				}
+				result.Encodings[path] = *encoding
+			}
+			if config.DetailedDiff && (!binary || cached.Diff != "") && strategy != strategyBinaryHash {
+				stopDiff := run.timings.Track("diff")
+				diff, ok := cached.Diff, hit || cached.Diff != ""
+				if !ok && isStructuredStrategy(strategy) {
+					diff, ok = run.getStructuralDiff(pair.SourceFile, pair.TargetFile, strategy)
+				}
+				if !ok {
+					diff = run.getFileDiff(pair)
+				}
+				stopDiff()
+				result.Diffs[path] = diff
+				cached.Diff = diff
+			}
+		}
+		if !hit {
+			run.cache.store(cacheKey, cached)
 		}
 	}
 
-	for path := range targetMap {
-		result.TargetOnlyFiles = append(result.TargetOnlyFiles, path)
-	}
+	run.progress.Finish()
 
 	// Sort all slices for consistent output
 	sort.Strings(result.IdenticalFiles)
 	sort.Strings(result.DifferentFiles)
+	sort.Strings(result.FormattingOnlyFiles)
+	sort.Strings(result.VersionBumpFiles)
+	sort.Strings(result.RecompressedFiles)
 	sort.Strings(result.SourceOnlyFiles)
 	sort.Strings(result.TargetOnlyFiles)
+	sortSecretFindings(result.Secrets)
+	sortAnomalies(result.Anomalies)
+	return nil
 }
 
-func getAllFilesFromDir(dir string, excludePaths []string, respectGitignore bool) ([]string, []string) {
-	var files []string
+// getAllFilesFromDir lists the files of dir a comparison with config
+// includes, and the excluded files and directories.
+func getAllFilesFromDir(dir string, config *Config, progress *progressReporter) ([]sourceFile, []string) {
+	var files []sourceFile
 	var excludedFiles []string
+	excludePaths, respectGitignore := config.ExcludePaths, config.RespectGitignore
 	dir = filepath.Clean(dir)
+	source := newDirSource(dir)
 	gitignoreStack := gitignore.NewStack(dir)
+	gitignoreStack.IgnoreCase = config.IgnoreCase
+	if respectGitignore {
+		// Lower levels of the stack, so .gitignore files take precedence
+		for _, file := range repoExcludeFiles(dir) {
+			if patterns, err := parseGitignore(file); err == nil && len(patterns) > 0 {
+				gitignoreStack.PushPatternsFrom(patterns, file)
+			}
+		}
+	}
 
 	var scanDir func(path string) error
 	scanDir = func(path string) error {
 		if respectGitignore {
 			gitignorePath := filepath.Join(path, ".gitignore")
 			if patterns, err := parseGitignore(gitignorePath); err == nil {
-				gitignoreStack.PushPatterns(patterns)
+				gitignoreStack.PushPatternsIn(path, patterns, gitignorePath)
 				defer gitignoreStack.PopPatterns()
 			}
 		}
@@ -401,7 +1218,8 @@ func getAllFilesFromDir(dir string, excludePaths []string, respectGitignore bool
 					continue
 				}
 
-				if respectGitignore && gitignoreStack.ShouldIgnore(fullPath) {
+				// Ignored directories are not descended into
+				if respectGitignore && gitignoreStack.ShouldIgnoreDir(fullPath) {
 					excludedFiles = append(excludedFiles, relativePath)
 					continue
 				}
@@ -424,14 +1242,17 @@ func getAllFilesFromDir(dir string, excludePaths []string, respectGitignore bool
 					continue
 				}
 
-				files = append(files, toSlash(fullPath))
+				files = append(files, sourceFile{source, relativePath})
+				progress.Add(1)
 			}
 		}
 
 		return nil
 	}
 
+	progress.Start("Scanning "+toSlash(dir), 0)
 	err := scanDir(dir)
+	progress.Finish()
 	if err != nil {
 		log.Printf("Error walking through files: %v", err)
 	}
@@ -464,14 +1285,17 @@ func parseGitignore(path string) ([]string, error) {
 	return patterns, scanner.Err()
 }
 
-func getAllFilesFromZip(zipPath string, excludePaths []string, respectGitignore bool) ([]string, []string) {
-	var files []string
+func (run *runState) getAllFilesFromZip(zipPath string, excludePaths []string, respectGitignore bool) ([]sourceFile, []string, error) {
+	var files []sourceFile
 	var excludedFiles []string
-	r, err := zip.OpenReader(zipPath)
+	r, closer, err := run.openZip(zipPath)
 	if err != nil {
-		log.Fatalf("Error opening zip file: %v", err)
+		return nil, nil, fmt.Errorf("error opening zip file %s: %w", zipPath, err)
+	}
+	defer closer.Close()
+	if err := checkZipLimits(zipPath, r, run.config.ArchiveLimits); err != nil {
+		return nil, nil, err
 	}
-	defer r.Close()
 
 	gitignorePatterns := make(map[string][]string)
 	if respectGitignore {
@@ -485,7 +1309,44 @@ func getAllFilesFromZip(zipPath string, excludePaths []string, respectGitignore
 		}
 	}
 
-	shouldIgnoreInZip := func(path string) bool {
+	shouldIgnoreInZip := archiveIgnoreFunc(gitignorePatterns, respectGitignore)
+
+	// Process all files
+	run.progress.Start("Scanning "+toSlash(zipPath), len(r.File))
+	defer run.progress.Finish()
+	source := &zipSource{archive: zipPath, memory: run.archives[zipPath]}
+	for _, f := range r.File {
+		run.progress.Add(1)
+		// Some tools write "./name", others "name"
+		name := strings.TrimPrefix(toSlash(f.Name), "./")
+		if f.FileInfo().IsDir() {
+			continue
+		}
+
+		if filepath.Base(name) == ".gitignore" {
+			continue
+		}
+
+		if shouldExclude(name, excludePaths) {
+			excludedFiles = append(excludedFiles, name)
+			continue
+		}
+
+		if shouldIgnoreInZip(name) {
+			excludedFiles = append(excludedFiles, name)
+			continue
+		}
+
+		files = append(files, sourceFile{source, name})
+	}
+
+	return files, excludedFiles, nil
+}
+
+// archiveIgnoreFunc returns a matcher for archive entry paths against the
+// .gitignore patterns found in the archive, keyed by their directory.
+func archiveIgnoreFunc(gitignorePatterns map[string][]string, respectGitignore bool) func(path string) bool {
+	return func(path string) bool {
 		if !respectGitignore {
 			return false
 		}
@@ -515,44 +1376,21 @@ func getAllFilesFromZip(zipPath string, excludePaths []string, respectGitignore
 
 		return false
 	}
-
-	// Process all files
-	for _, f := range r.File {
-		name := toSlash(f.Name)
-		if f.FileInfo().IsDir() {
-			continue
-		}
-
-		if filepath.Base(name) == ".gitignore" {
-			continue
-		}
-
-		if shouldExclude(name, excludePaths) {
-			excludedFiles = append(excludedFiles, name)
-			continue
-		}
-
-		if shouldIgnoreInZip(name) {
-			excludedFiles = append(excludedFiles, name)
-			continue
-		}
-
-		files = append(files, name)
-	}
-
-	return files, excludedFiles
 }
 
 func parseGitignoreFromZipFile(f *zip.File) ([]string, error) {
-	var patterns []string
-
 	rc, err := f.Open()
 	if err != nil {
-		return patterns, err
+		return nil, err
 	}
 	defer rc.Close()
 
-	scanner := bufio.NewScanner(rc)
+	return parseGitignorePatterns(rc)
+}
+
+func parseGitignorePatterns(r io.Reader) ([]string, error) {
+	var patterns []string
+	scanner := bufio.NewScanner(r)
 	for scanner.Scan() {
 		line := scanner.Text()
 		line = strings.TrimSpace(line)
@@ -575,84 +1413,56 @@ func shouldExclude(path string, patterns []string) bool {
 	return false
 }
 
-func filesAreEqual(file1, file2 string) bool {
-	var content1, content2 []byte
-	var err1, err2 error
-
-	if strings.HasSuffix(file1, ".zip") {
-		// Read from zip file
-		content1, err1 = readFileFromZip(file1)
-	} else {
-		content1, err1 = os.ReadFile(file1)
-	}
-
-	if strings.HasSuffix(file2, ".zip") {
-		// Read from zip file
-		content2, err2 = readFileFromZip(file2)
-	} else {
-		content2, err2 = os.ReadFile(file2)
-	}
-
-	if err1 != nil || err2 != nil {
+// filesAreEqual compares two files byte by byte. Files are streamed, so
+// large files are never held in memory as a whole.
+func (run *runState) filesAreEqual(file1, file2 sourceFile) bool {
+	r1, size1, err := openFileContent(file1)
+	if err != nil {
 		return false
 	}
-
-	return string(content1) == string(content2)
-}
-
-func readFileFromZip(zipFilePath string) ([]byte, error) {
-	// Extract the zip path and the file inside the zip
-	zipPath, filePath := splitZipPath(zipFilePath)
-
-	r, err := zip.OpenReader(zipPath)
+	defer r1.Close()
+	r2, size2, err := openFileContent(file2)
 	if err != nil {
-		return nil, err
+		return false
 	}
-	defer r.Close()
-
-	for _, f := range r.File {
-		if f.Name == filePath {
-			rc, err := f.Open()
-			if err != nil {
-				return nil, err
-			}
-			defer rc.Close()
-
-			return io.ReadAll(rc)
-		}
+	defer r2.Close()
+	run.stats.FilesCompared++
+	if size1 != size2 {
+		return false
 	}
 
-	return nil, fmt.Errorf("file %s not found in zip archive", filePath)
+	equal, read, err := streamsEqual(r1, r2)
+	run.stats.BytesCompared += read
+	return err == nil && equal
 }
 
-func splitZipPath(zipFilePath string) (zipPath, filePath string) {
-	// For simplicity, assume that zipFilePath is in the format "zipfile.zip::filepath"
-	parts := strings.SplitN(zipFilePath, "::", 2)
-	if len(parts) != 2 {
-		return "", ""
-	}
-	return parts[0], parts[1]
+// readFileContent reads a file of a tree source as a whole.
+func readFileContent(file sourceFile) ([]byte, error) {
+	return fs.ReadFile(file.tree, file.name)
 }
 
-func getFileDiff(file1, file2 string) string {
-	var content1, content2 []byte
-	var err1, err2 error
-
-	if strings.HasSuffix(file1, ".zip") {
-		content1, err1 = readFileFromZip(file1)
-	} else {
-		content1, err1 = os.ReadFile(file1)
-	}
-
-	if strings.HasSuffix(file2, ".zip") {
-		content2, err2 = readFileFromZip(file2)
-	} else {
-		content2, err2 = os.ReadFile(file2)
-	}
+// diffRow is one rendered line of a diff.
+type diffRow struct {
+	Type diffmatchpatch.Operation
+	Num  int
+	Text string
+	HTML string
+}
 
-	if err1 != nil || err2 != nil {
+// getFileDiff renders the line diff of a pair as HTML. Unchanged lines
+// further than config.DiffContext lines from a change are collapsed (a
+// negative value shows whole files), and at most config.MaxDiffLines lines
+// are rendered (zero means no limit).
+func (run *runState) getFileDiff(pair filePair) string {
+	config := run.config
+	file1, file2 := pair.SourceFile, pair.TargetFile
+	content1, content2, err := run.readPair(pair, config.NormalizeCmd)
+	if err != nil {
 		return "Error reading files for diff"
 	}
+	content1, content2 = toUTF8(content1), toUTF8(content2)
+	hunks := diffHunks(content1, content2)
+	content1, content2 = run.redactContent(content1), run.redactContent(content2)
 
 	dmp := diffmatchpatch.New()
 
@@ -661,10 +1471,21 @@ func getFileDiff(file1, file2 string) string {
 	lineDiffs := dmp.DiffMain(chars1, chars2, false)
 	lines := dmp.DiffCharsToLines(lineDiffs, linePatches)
 
-	// Generate HTML output
-	var html strings.Builder
-	html.WriteString("<div class=\"diff-content\">")
+	// Highlight both sides as a whole so multi-line constructs are tokenized correctly
+	var highlighted1, highlighted2 []highlightedLine
+	if config.SyntaxHighlight {
+		highlighted1 = highlightLines(file1.name, string(content1))
+		highlighted2 = highlightLines(file2.name, string(content2))
+	}
+	lineHTML := func(highlighted []highlightedLine, lineNum int, line string) string {
+		if lineNum <= len(highlighted) && highlighted[lineNum-1].Text == line {
+			return highlighted[lineNum-1].HTML
+		}
+		return template.HTMLEscapeString(line)
+	}
 
+	var rows []diffRow
+	additions, deletions := 0, 0
 	lineNum1 := 1
 	lineNum2 := 1
 
@@ -675,56 +1496,256 @@ func getFileDiff(file1, file2 string) string {
 				continue // Skip empty line at the end
 			}
 
-			escapedLine := template.HTMLEscapeString(line)
 			switch diff.Type {
 			case diffmatchpatch.DiffDelete:
-				html.WriteString(fmt.Sprintf("<div class=\"diff-line diff-deleted\"><span class=\"line-num\">%d</span><span class=\"diff-marker\">-</span>%s</div>",
-					lineNum1, escapedLine))
+				rows = append(rows, diffRow{diff.Type, lineNum1, line, lineHTML(highlighted1, lineNum1, line)})
 				lineNum1++
+				deletions++
 			case diffmatchpatch.DiffInsert:
-				html.WriteString(fmt.Sprintf("<div class=\"diff-line diff-inserted\"><span class=\"line-num\">%d</span><span class=\"diff-marker\">+</span>%s</div>",
-					lineNum2, escapedLine))
+				rows = append(rows, diffRow{diff.Type, lineNum2, line, lineHTML(highlighted2, lineNum2, line)})
 				lineNum2++
+				additions++
 			case diffmatchpatch.DiffEqual:
-				html.WriteString(fmt.Sprintf("<div class=\"diff-line diff-equal\"><span class=\"line-num\">%d</span><span class=\"diff-marker\"> </span>%s</div>",
-					lineNum1, escapedLine))
+				rows = append(rows, diffRow{diff.Type, lineNum1, line, lineHTML(highlighted1, lineNum1, line)})
 				lineNum1++
 				lineNum2++
 			}
 		}
 	}
 
+	if config.IntralineDiff {
+		highlightIntraline(dmp, rows)
+	}
+
+	// Generate HTML output
+	var html strings.Builder
+	fmt.Fprintf(&html, "<div class=\"diff-content chroma\" data-additions=\"%d\" data-deletions=\"%d\">", additions, deletions)
+
+	// Hunk headers carry the hashes used by ignore_hunks. They are only
+	// shown when redaction did not change how the lines group into hunks.
+	isHunkStart := func(i int) bool {
+		return rows[i].Type != diffmatchpatch.DiffEqual && (i == 0 || rows[i-1].Type == diffmatchpatch.DiffEqual)
+	}
+	hunkCount := 0
+	for i := range rows {
+		if isHunkStart(i) {
+			hunkCount++
+		}
+	}
+	showHunks := hunkCount == len(hunks)
+	hunkIndex := 0
+
+	// Lines past the first chunk go into inert <template> elements, which
+	// the report renders on scroll, so huge diffs don't freeze the browser
+	visible := visibleDiffRows(rows, config.DiffContext)
+	rendered := 0
+	chunked := false
+	for i := 0; i < len(rows); i++ {
+		if config.DiffChunkLines > 0 && rendered > 0 && rendered%config.DiffChunkLines == 0 && visible[i] {
+			if chunked {
+				html.WriteString("</template>")
+			}
+			html.WriteString("<template class=\"diff-chunk\">")
+			chunked = true
+		}
+		if !visible[i] {
+			skipped := 0
+			for i < len(rows) && !visible[i] {
+				skipped++
+				i++
+			}
+			fmt.Fprintf(&html, "<div class=\"diff-line diff-skipped\"><span class=\"line-num\">…</span><span class=\"diff-marker\"> </span>%d unchanged lines</div>", skipped)
+			i--
+			continue
+		}
+		if config.MaxDiffLines > 0 && rendered == config.MaxDiffLines {
+			remaining := 0
+			for _, v := range visible[i:] {
+				if v {
+					remaining++
+				}
+			}
+			fmt.Fprintf(&html, "<div class=\"diff-truncated\">Diff truncated: %d more lines not shown (limit is %d lines)</div>", remaining, config.MaxDiffLines)
+			break
+		}
+
+		if isHunkStart(i) {
+			if showHunks {
+				fmt.Fprintf(&html, "<div class=\"diff-line diff-hunk\"><span class=\"line-num\">@@</span><span class=\"diff-marker\"> </span>hunk %s</div>", hunks[hunkIndex].Hash)
+			}
+			hunkIndex++
+		}
+
+		row := rows[i]
+		switch row.Type {
+		case diffmatchpatch.DiffDelete:
+			fmt.Fprintf(&html, "<div class=\"diff-line diff-deleted\"><span class=\"line-num\">%d</span><span class=\"diff-marker\">-</span>%s</div>", row.Num, row.HTML)
+		case diffmatchpatch.DiffInsert:
+			fmt.Fprintf(&html, "<div class=\"diff-line diff-inserted\"><span class=\"line-num\">%d</span><span class=\"diff-marker\">+</span>%s</div>", row.Num, row.HTML)
+		case diffmatchpatch.DiffEqual:
+			fmt.Fprintf(&html, "<div class=\"diff-line diff-equal\"><span class=\"line-num\">%d</span><span class=\"diff-marker\"> </span>%s</div>", row.Num, row.HTML)
+		}
+		rendered++
+	}
+	if chunked {
+		html.WriteString("</template><div class=\"diff-more\" onclick=\"loadDiffChunk(this)\">Load more lines</div>")
+	}
+
 	html.WriteString("</div>")
 	return html.String()
 }
 
-func generateHTMLReport(result ComparisonResult, outputFile string) error {
+// highlightIntraline pairs each block of deleted lines with the inserted
+// lines that follow it and marks the changed words within similar line
+// pairs. Marked lines lose their syntax highlighting.
+func highlightIntraline(dmp *diffmatchpatch.DiffMatchPatch, rows []diffRow) {
+	for i := 0; i < len(rows); {
+		if rows[i].Type != diffmatchpatch.DiffDelete {
+			i++
+			continue
+		}
+		delStart := i
+		for i < len(rows) && rows[i].Type == diffmatchpatch.DiffDelete {
+			i++
+		}
+		insStart := i
+		for i < len(rows) && rows[i].Type == diffmatchpatch.DiffInsert {
+			i++
+		}
+		for k := 0; delStart+k < insStart && insStart+k < i; k++ {
+			deleted, inserted := &rows[delStart+k], &rows[insStart+k]
+			diffs := dmp.DiffCleanupSemantic(dmp.DiffMain(deleted.Text, inserted.Text, false))
+			if !similarLines(diffs, deleted.Text, inserted.Text) {
+				continue
+			}
+			deleted.HTML = intralineHTML(diffs, diffmatchpatch.DiffDelete, "diff-word-deleted")
+			inserted.HTML = intralineHTML(diffs, diffmatchpatch.DiffInsert, "diff-word-inserted")
+		}
+	}
+}
+
+// similarLines reports whether at least half of the longer line is shared,
+// below which marking individual words only adds noise.
+func similarLines(diffs []diffmatchpatch.Diff, a, b string) bool {
+	common := 0
+	for _, d := range diffs {
+		if d.Type == diffmatchpatch.DiffEqual {
+			common += len(d.Text)
+		}
+	}
+	return common > 0 && common*2 >= max(len(a), len(b))
+}
+
+// intralineHTML renders one side of a character diff, wrapping the spans
+// that only exist on that side in class.
+func intralineHTML(diffs []diffmatchpatch.Diff, side diffmatchpatch.Operation, class string) string {
+	var html strings.Builder
+	for _, d := range diffs {
+		switch d.Type {
+		case diffmatchpatch.DiffEqual:
+			html.WriteString(template.HTMLEscapeString(d.Text))
+		case side:
+			html.WriteString(`<span class="` + class + `">` + template.HTMLEscapeString(d.Text) + `</span>`)
+		}
+	}
+	return html.String()
+}
+
+// visibleDiffRows marks the rows within context lines of a change. All rows
+// are visible when context is negative.
+func visibleDiffRows(rows []diffRow, context int) []bool {
+	visible := make([]bool, len(rows))
+	for i, row := range rows {
+		if context < 0 {
+			visible[i] = true
+			continue
+		}
+		if row.Type == diffmatchpatch.DiffEqual {
+			continue
+		}
+		for j := max(0, i-context); j <= min(len(rows)-1, i+context); j++ {
+			visible[j] = true
+		}
+	}
+	return visible
+}
+
+var diffTotals = regexp.MustCompile(`data-additions="(\d+)" data-deletions="(\d+)"`)
+
+func generateHTMLReport(result ComparisonResult, outputFile string, config *Config) error {
+	// Create output file
+	f, err := createOutput(outputFile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return renderHTMLReport(f, result, config)
+}
+
+// renderHTMLReport executes the report template for result.
+func renderHTMLReport(w io.Writer, result ComparisonResult, config *Config) error {
 	// Create template functions
 	funcMap := template.FuncMap{
-		"add":      func(a, b int) int { return a + b },
-		"safeHTML": func(s string) template.HTML { return template.HTML(s) },
+		"add":              func(a, b int) int { return a + b },
+		"safeHTML":         func(s string) template.HTML { return template.HTML(s) },
+		"percent":          func(f float64) string { return fmt.Sprintf("%.0f%%", f*100) },
+		"highlightCSS":     highlightCSS,
+		"highlightDarkCSS": highlightDarkCSS,
+		"theme":            func() string { return themeAttribute(config.Theme) },
+		"themeCSS":         func() template.CSS { return themeCSS(config.ThemeVariables) },
+		"lazyDiffs":        func() bool { return config.LazyDiffs },
+		"toJSON": func(v any) (string, error) {
+			data, err := json.MarshalIndent(v, "", "  ")
+			return string(data), err
+		},
+		"shortSHA":     shortSHA,
+		"isoTime":      func(t time.Time) string { return t.Format(time.RFC3339) },
+		"microseconds": func(d time.Duration) int64 { return d.Microseconds() },
+		"formatDuration": func(d time.Duration) string {
+			if d < time.Millisecond {
+				return d.Round(time.Microsecond).String()
+			}
+			return d.Round(time.Millisecond).String()
+		},
+		"formatBytes": func(n int64) string {
+			if n < 0 {
+				return "-" + formatBytes(uint64(-n))
+			}
+			return formatBytes(uint64(n))
+		},
 		"countDiffStats": func(diff string) string {
+			// Totals are recorded on the diff, which may be collapsed or truncated
+			if m := diffTotals.FindStringSubmatch(diff); m != nil {
+				return fmt.Sprintf("+%s -%s", m[1], m[2])
+			}
 			additions := strings.Count(diff, "diff-inserted")
 			deletions := strings.Count(diff, "diff-deleted")
 			return fmt.Sprintf("+%d -%d", additions, deletions)
 		},
 	}
 
-	// Create and parse template
-	t, err := template.New("report").Funcs(funcMap).Parse(reportTemplate)
-	if err != nil {
-		return fmt.Errorf("error parsing template: %w", err)
+	if err := addConfigTemplateFuncs(funcMap, config.TemplateFunctions); err != nil {
+		return err
 	}
 
-	// Create output file
-	f, err := os.Create(outputFile)
+	// Use a custom template if one is configured
+	templateText := reportTemplate
+	if config.Template != "" {
+		content, err := os.ReadFile(config.Template)
+		if err != nil {
+			return fmt.Errorf("error reading template: %w", err)
+		}
+		templateText = string(content)
+	}
+
+	// Create and parse template
+	t, err := template.New("report").Funcs(funcMap).Parse(templateText)
 	if err != nil {
-		return fmt.Errorf("error creating output file: %w", err)
+		return fmt.Errorf("error parsing template: %w", err)
 	}
-	defer f.Close()
 
 	// Execute template
-	if err := t.Execute(f, result); err != nil {
+	if err := t.Execute(w, result); err != nil {
 		return fmt.Errorf("error executing template: %w", err)
 	}
 
@@ -744,6 +1765,14 @@ func appVersion() string {
 	}
 }
 
+// shortSHA abbreviates a commit hash for display.
+func shortSHA(sha string) string {
+	if len(sha) > 12 {
+		return sha[:12]
+	}
+	return sha
+}
+
 func toSlash(path string) string {
 	return filepath.ToSlash(path)
 }
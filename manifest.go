@@ -0,0 +1,208 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// Manifest is a checksum inventory of a tree. Entries are sorted by path,
+// so the same tree always produces the same manifest.
+type Manifest struct {
+	Files []ManifestEntry `json:"files"`
+}
+
+type ManifestEntry struct {
+	Path   string `json:"path"`
+	SHA256 string `json:"sha256"`
+	Size   int64  `json:"size"`
+}
+
+// newManifestCommand creates the manifest subcommand, which writes the
+// checksum inventory of the source tree.
+func newManifestCommand(config *Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "manifest",
+		Short: "Write a checksum manifest of the source tree",
+		Long: `Manifest walks the source directory (--source-dir and --source-subdir),
+honoring exclude_paths and .gitignore rules like a comparison does, and
+writes the relative path, SHA-256 and size of every file as JSON. The
+manifest is deterministic, so it can serve as a lightweight baseline or
+for external auditing.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			output, _ := cmd.Flags().GetString("manifest-file")
+			dir := filepath.Join(config.SourceDir, config.SourceSubdir)
+			manifest, err := buildManifest(dir, config)
+			if err == nil {
+				err = writeManifest(manifest, output)
+			}
+			if err != nil {
+				fmt.Println("Error:", err)
+				os.Exit(1)
+			}
+			fmt.Printf("Manifest of %d files written to %s\n", len(manifest.Files), output)
+		},
+	}
+	cmd.Flags().StringP("manifest-file", "", "manifest.json", "Path of the manifest to write")
+	return cmd
+}
+
+// buildManifest hashes the files of dir that a comparison would include.
+func buildManifest(dir string, config *Config) (Manifest, error) {
+	files, _ := getAllFilesFromDir(dir, config, newProgressReporter(false))
+	manifest := Manifest{Files: make([]ManifestEntry, 0, len(files))}
+	for _, file := range files {
+		entry, err := manifestEntry(file)
+		if err != nil {
+			return manifest, err
+		}
+		manifest.Files = append(manifest.Files, entry)
+	}
+	sort.Slice(manifest.Files, func(i, j int) bool {
+		return manifest.Files[i].Path < manifest.Files[j].Path
+	})
+	return manifest, nil
+}
+
+func manifestEntry(file sourceFile) (ManifestEntry, error) {
+	f, _, err := openFileContent(file)
+	if err != nil {
+		return ManifestEntry{}, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return ManifestEntry{}, err
+	}
+	return ManifestEntry{Path: file.name, SHA256: hex.EncodeToString(h.Sum(nil)), Size: size}, nil
+}
+
+func writeManifest(manifest Manifest, outputFile string) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding manifest: %w", err)
+	}
+	if err := os.WriteFile(outputFile, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("error creating manifest file: %w", err)
+	}
+	return nil
+}
+
+// loadManifest reads a manifest written by the manifest command.
+func loadManifest(path string) (Manifest, error) {
+	var manifest Manifest
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return manifest, fmt.Errorf("error reading manifest: %w", err)
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return manifest, fmt.Errorf("error parsing manifest %s: %w", path, err)
+	}
+	for i, e := range manifest.Files {
+		if e.Path == "" || e.SHA256 == "" {
+			return manifest, fmt.Errorf("manifest %s: entry %d lacks a path or sha256", path, i+1)
+		}
+	}
+	return manifest, nil
+}
+
+// compareWithManifest compares the source directory with the checksums of
+// a manifest, for a target that is only known by its manifest. Files are
+// compared by SHA-256 and size, so no detailed diffs are available.
+func (run *runState) compareWithManifest(sourceDir, manifestPath string) (ComparisonResult, error) {
+	config := run.config
+	result := ComparisonResult{
+		Diffs:      make(map[string]string),
+		Moved:      make(map[string]string),
+		SizeDeltas: make(map[string]*SizeDelta),
+		Contents:   make(map[string]EmbeddedContents),
+	}
+	manifest, err := loadManifest(manifestPath)
+	if err != nil {
+		return result, err
+	}
+
+	// The manifest was filtered when it was written; only exclude_paths and
+	// the target subdirectory apply to it here
+	prefix := toSlash(filepath.Clean(config.TargetSubdir)) + "/"
+	targets := make(map[string]ManifestEntry)
+	for _, e := range manifest.Files {
+		name := e.Path
+		if !checkPath(&result, name, "target") {
+			continue
+		}
+		if config.TargetSubdir != "" {
+			if !strings.HasPrefix(name, prefix) {
+				continue
+			}
+			name = strings.TrimPrefix(name, prefix)
+		}
+		if shouldExclude(name, config.ExcludePaths) {
+			result.TargetExcluded = append(result.TargetExcluded, name)
+			continue
+		}
+		if run.owned != nil && !run.owned.owns(name) {
+			continue
+		}
+		targets[name] = e
+	}
+
+	sourceDir = filepath.Join(sourceDir, config.SourceSubdir)
+	stopScan := run.timings.Track("scan")
+	sourceFiles, sourceExcluded := getAllFilesFromDir(sourceDir, config, run.progress)
+	stopScan()
+	run.stats.FilesScanned += len(sourceFiles) + len(targets)
+	result.SourceExcluded = sourceExcluded
+
+	run.progress.Start("Comparing", len(sourceFiles))
+	for _, file := range sourceFiles {
+		run.progress.Add(1)
+		name := file.name
+		if !checkPath(&result, name, "source") || (run.owned != nil && !run.owned.owns(name)) {
+			continue
+		}
+		target, ok := targets[name]
+		if !ok {
+			result.SourceOnlyFiles = append(result.SourceOnlyFiles, name)
+			continue
+		}
+		delete(targets, name)
+
+		stopCompare := run.timings.Track("compare")
+		source, err := manifestEntry(file)
+		stopCompare()
+		if err != nil {
+			return result, err
+		}
+		run.stats.FilesCompared++
+		run.stats.BytesCompared += source.Size
+		if source.Size == target.Size && strings.EqualFold(source.SHA256, target.SHA256) {
+			result.IdenticalFiles = append(result.IdenticalFiles, name)
+		} else {
+			result.DifferentFiles = append(result.DifferentFiles, name)
+		}
+	}
+	run.progress.Finish()
+	for name := range targets {
+		result.TargetOnlyFiles = append(result.TargetOnlyFiles, name)
+	}
+
+	sort.Strings(result.IdenticalFiles)
+	sort.Strings(result.DifferentFiles)
+	sort.Strings(result.SourceOnlyFiles)
+	sort.Strings(result.TargetOnlyFiles)
+	sort.Strings(result.SourceExcluded)
+	sort.Strings(result.TargetExcluded)
+	sortAnomalies(result.Anomalies)
+	return result, nil
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/adnsv/gitparator/testsupport"
+)
+
+func TestCompareManifest(t *testing.T) {
+	config := defaultConfig(t)
+	manifest, err := buildManifest(testsupport.Dir(t, targetFiles), &config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(t.TempDir(), "manifest.json")
+	if err := writeManifest(manifest, file); err != nil {
+		t.Fatal(err)
+	}
+
+	result := compareFixtures(t, func(config *Config) {
+		config.SourceDir = testsupport.Dir(t, sourceFiles)
+		config.TargetManifest = file
+	})
+	if got := classified(result); !reflect.DeepEqual(got, wantClassified) {
+		t.Errorf("classified = %v, want %v", got, wantClassified)
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Names under which in-memory archives are compared.
+const (
+	memorySourceZip = "source.zip"
+	memoryTargetZip = "target.zip"
+)
+
+type nopCloser struct{}
+
+func (nopCloser) Close() error { return nil }
+
+// openZip opens a zip archive held by the run in memory or on disk. The
+// closer must be closed when done. Archives held in memory let the
+// comparison run without a filesystem, as in the browser.
+func (run *runState) openZip(zipPath string) (*zip.Reader, io.Closer, error) {
+	if r, ok := run.archives[zipPath]; ok {
+		return r, nopCloser{}, nil
+	}
+	return openZip(zipPath)
+}
+
+// openZip opens a zip archive on disk. The closer must be closed when done.
+func openZip(zipPath string) (*zip.Reader, io.Closer, error) {
+	rc, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &rc.Reader, rc, nil
+}
+
+// compareArchiveBytes compares two zip archives held in memory. Nothing is
+// read from or written to disk, unless config names external helpers such
+// as formatters or plugins.
+func compareArchiveBytes(source, target []byte, config *Config) (ComparisonResult, error) {
+	var result ComparisonResult
+	sourceZip, err := zip.NewReader(bytes.NewReader(source), int64(len(source)))
+	if err != nil {
+		return result, fmt.Errorf("error reading source archive: %w", err)
+	}
+	targetZip, err := zip.NewReader(bytes.NewReader(target), int64(len(target)))
+	if err != nil {
+		return result, fmt.Errorf("error reading target archive: %w", err)
+	}
+	run, err := startRun(config, os.Stdout)
+	if err != nil {
+		return result, err
+	}
+	defer run.close()
+	run.archives[memorySourceZip] = sourceZip
+	run.archives[memoryTargetZip] = targetZip
+
+	config.SourceZip, config.TargetZip = memorySourceZip, memoryTargetZip
+	if result, err = run.compareZips(memorySourceZip, memoryTargetZip); err != nil {
+		return result, err
+	}
+	result.Metadata = RunMetadata{
+		GitparatorVersion: appVersion(),
+		Source:            RepoInfo{Location: memorySourceZip},
+		Target:            RepoInfo{Location: memoryTargetZip},
+		Config:            *config,
+	}
+	result.Metadata.RunID = runID(&result, config)
+	result.StartedAt = run.timings.Started()
+	result.Duration = run.timings.Elapsed()
+	result.Timings = run.timings.Phases()
+	run.stats.finalize(result.Timings, result.Duration)
+	result.Stats = run.stats
+	return result, nil
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"path/filepath"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+)
+
+// RunMetadata records what was compared and how, for auditability.
+type RunMetadata struct {
+	RunID              string               `json:"run_id"`
+	GitparatorVersion  string               `json:"gitparator_version"`
+	Source             RepoInfo             `json:"source"`
+	Target             RepoInfo             `json:"target"`
+	Config             Config               `json:"config"`                        // effective configuration after merging flags and config file
+	TargetVerification *ArchiveVerification `json:"target_verification,omitempty"` // checks of a target archive against its sidecar files
+}
+
+// RepoInfo identifies one side of the comparison. Commit, Branch and Tag are
+// empty when the side is not a git working tree (e.g. a zip archive).
+type RepoInfo struct {
+	Location string `json:"location"`
+	Commit   string `json:"commit,omitempty"`
+	Branch   string `json:"branch,omitempty"`
+	Tag      string `json:"tag,omitempty"`
+}
+
+// collectMetadata gathers the run metadata. targetRepoDir is the local
+// working tree of the target, or empty when the target is not a git tree.
+func collectMetadata(config *Config, targetLocation, targetRepoDir string) RunMetadata {
+	meta := RunMetadata{
+		GitparatorVersion: appVersion(),
+		Source:            gitRepoInfo(config.SourceDir),
+		Target:            RepoInfo{Location: targetLocation},
+		Config:            *config,
+	}
+	if config.SourceZip != "" {
+		meta.Source = RepoInfo{Location: config.SourceZip}
+	}
+	if targetRepoDir != "" {
+		info := gitRepoInfo(targetRepoDir)
+		if targetLocation == targetRepoDir {
+			meta.Target.Location = info.Location
+		}
+		meta.Target.Commit = info.Commit
+		meta.Target.Branch = info.Branch
+		meta.Target.Tag = info.Tag
+	}
+	// A shallow clone of a tag has a detached HEAD, so take the ref from the request
+	if meta.Target.Branch == "" && config.Branch != "" && config.TargetURL != "" {
+		meta.Target.Branch = config.Branch
+	}
+	if meta.Target.Tag == "" && config.Tag != "" && config.TargetURL != "" {
+		meta.Target.Tag = config.Tag
+	}
+	return meta
+}
+
+// runID derives an ID from what was compared and how: the gitparator
+// version, the commits and refs of both sides, the settings affecting the
+// outcome, and the outcome of every file. Local paths, timings and other
+// details of the run are left out, so repeating an identical comparison,
+// on any machine, yields the same ID.
+func runID(result *ComparisonResult, config *Config) string {
+	side := func(info RepoInfo) RepoInfo {
+		info.Location = ""
+		return info
+	}
+	data, _ := json.Marshal(struct {
+		Version        string
+		Source, Target RepoInfo
+		Settings       string
+		Subdirs        [2]string
+		Files          [9][]string
+		Diffs, Moved   map[string]string
+		Changes        map[string]string
+	}{
+		result.Metadata.GitparatorVersion,
+		side(result.Metadata.Source),
+		side(result.Metadata.Target),
+		cacheSettings(config),
+		[2]string{config.SourceSubdir, config.TargetSubdir},
+		[9][]string{
+			result.IdenticalFiles, result.DifferentFiles,
+			result.FormattingOnlyFiles, result.VersionBumpFiles, result.RecompressedFiles,
+			result.SourceOnlyFiles, result.TargetOnlyFiles,
+			result.SourceExcluded, result.TargetExcluded,
+		},
+		result.Diffs,
+		result.Moved,
+		result.Changes,
+	})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8])
+}
+
+// gitRepoInfo describes the working tree containing dir: the HEAD commit,
+// the checked-out branch and a tag pointing at HEAD, when available.
+func gitRepoInfo(dir string) RepoInfo {
+	info := RepoInfo{Location: dir}
+	if abs, err := filepath.Abs(dir); err == nil {
+		info.Location = abs
+	}
+
+	repo, err := git.PlainOpenWithOptions(dir, &git.PlainOpenOptions{DetectDotGit: true})
+	if err != nil {
+		return info
+	}
+	head, err := repo.Head()
+	if err != nil {
+		return info
+	}
+	info.Commit = head.Hash().String()
+	if head.Name().IsBranch() {
+		info.Branch = head.Name().Short()
+	}
+	info.Tag = tagAtCommit(repo, head.Hash())
+	return info
+}
+
+// tagAtCommit returns the name of a tag (lightweight or annotated) that
+// points at hash, or an empty string.
+func tagAtCommit(repo *git.Repository, hash plumbing.Hash) string {
+	tags, err := repo.Tags()
+	if err != nil {
+		return ""
+	}
+	defer tags.Close()
+
+	found := ""
+	tags.ForEach(func(ref *plumbing.Reference) error {
+		target := ref.Hash()
+		if tag, err := repo.TagObject(target); err == nil {
+			if commit, err := tag.Commit(); err == nil {
+				target = commit.Hash
+			}
+		}
+		if target == hash {
+			found = ref.Name().Short()
+			return storer.ErrStop
+		}
+		return nil
+	})
+	return found
+}
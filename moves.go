@@ -0,0 +1,86 @@
+package main
+
+import (
+	"log"
+	"sort"
+)
+
+// PossibleMove suggests that an unpaired source file and an unpaired target
+// file may be the same file at different locations.
+type PossibleMove struct {
+	Source     string  `json:"source"`
+	Target     string  `json:"target"`
+	Similarity float64 `json:"similarity"` // 0..1, where 1 means identical paths
+}
+
+// maxMoveCandidates caps the number of source/target combinations scored,
+// since each comparison is quadratic in the path length.
+const maxMoveCandidates = 250000
+
+// suggestMoves pairs source-only and target-only paths whose similarity
+// reaches minSimilarity. Each path takes part in at most one suggestion;
+// the most similar combinations are chosen first.
+func suggestMoves(sourceOnly, targetOnly []string, minSimilarity float64) []PossibleMove {
+	if len(sourceOnly)*len(targetOnly) > maxMoveCandidates {
+		log.Printf("Skipping move suggestions: %d source-only and %d target-only files are too many to score",
+			len(sourceOnly), len(targetOnly))
+		return nil
+	}
+
+	var candidates []PossibleMove
+	for _, s := range sourceOnly {
+		for _, t := range targetOnly {
+			if sim := pathSimilarity(s, t); sim >= minSimilarity {
+				candidates = append(candidates, PossibleMove{s, t, sim})
+			}
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Similarity > candidates[j].Similarity
+	})
+
+	usedSource := make(map[string]bool)
+	usedTarget := make(map[string]bool)
+	var moves []PossibleMove
+	for _, c := range candidates {
+		if usedSource[c.Source] || usedTarget[c.Target] {
+			continue
+		}
+		usedSource[c.Source] = true
+		usedTarget[c.Target] = true
+		moves = append(moves, c)
+	}
+	sort.Slice(moves, func(i, j int) bool { return moves[i].Source < moves[j].Source })
+	return moves
+}
+
+// pathSimilarity converts the Levenshtein distance of two paths into a score
+// between 0 and 1 relative to the longer path.
+func pathSimilarity(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	longest := max(len(ra), len(rb))
+	if longest == 0 {
+		return 1
+	}
+	return 1 - float64(levenshtein(ra, rb))/float64(longest)
+}
+
+func levenshtein(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
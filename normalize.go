@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// NormalizeRule rewrites volatile content, such as version numbers or
+// copyright years, before files are compared.
+type NormalizeRule struct {
+	Paths   []string `mapstructure:"paths" json:"paths"` // globs; empty matches all files
+	Pattern string   `mapstructure:"pattern" json:"pattern"`
+	Replace string   `mapstructure:"replace" json:"replace"`
+}
+
+type normalizer struct {
+	paths   []string
+	pattern *regexp.Regexp
+	replace []byte
+}
+
+func compileNormalizeRules(rules []NormalizeRule) ([]normalizer, error) {
+	var compiled []normalizer
+	for i, rule := range rules {
+		re, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("normalize rule %d: invalid pattern: %w", i+1, err)
+		}
+		for _, p := range rule.Paths {
+			if !doublestar.ValidatePattern(p) {
+				return nil, fmt.Errorf("normalize rule %d: invalid path glob %q", i+1, p)
+			}
+		}
+		compiled = append(compiled, normalizer{rule.Paths, re, []byte(rule.Replace)})
+	}
+	return compiled, nil
+}
+
+// normalizeContent applies the rules of the run whose globs match relPath,
+// in order.
+func (run *runState) normalizeContent(relPath string, content []byte) []byte {
+	relPath = toSlash(relPath)
+	for _, n := range run.normalizers {
+		if len(n.paths) == 0 || shouldExclude(relPath, n.paths) {
+			content = n.pattern.ReplaceAll(content, n.replace)
+		}
+	}
+	return content
+}
+
+// normalizedEqual reports whether a pair is equal once both files are
+// normalized.
+func (run *runState) normalizedEqual(pair filePair) bool {
+	source, err1 := readFileContent(pair.SourceFile)
+	target, err2 := readFileContent(pair.TargetFile)
+	if err1 != nil || err2 != nil {
+		return false
+	}
+	return bytes.Equal(run.normalizeContent(pair.SourcePath, source), run.normalizeContent(pair.TargetPath, target))
+}
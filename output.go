@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// stdoutFile is the output file name that writes a report to stdout.
+const stdoutFile = "-"
+
+// Report formats selected with --format or inferred from output file
+// extensions.
+const (
+	formatHTML  = "html"
+	formatJSON  = "json"
+	formatPatch = "patch"
+)
+
+// reportStdout is the process stdout, kept when informational output is
+// routed to stderr so that reports can still be written to it.
+var reportStdout io.Writer = os.Stdout
+
+// writesToStdout reports whether one of the output files is stdout.
+func writesToStdout(config *Config) bool {
+	for _, outputFile := range config.OutputFile {
+		if outputFile == stdoutFile {
+			return true
+		}
+	}
+	return false
+}
+
+// routeInfoToStderr sends everything printed to stdout to stderr instead,
+// leaving stdout to the report, as when piping a patch to git apply.
+func routeInfoToStderr() {
+	reportStdout = os.Stdout
+	os.Stdout = os.Stderr
+}
+
+// reportFormat returns the format written to outputFile: the format given,
+// or else the one implied by the extension (.json for the result, .patch
+// and .diff for a patch, HTML otherwise).
+func reportFormat(outputFile, format string) (string, error) {
+	switch format {
+	case formatHTML, formatJSON, formatPatch:
+		return format, nil
+	case "":
+	default:
+		return "", fmt.Errorf("invalid format %q: expected html, json or patch", format)
+	}
+	switch strings.ToLower(filepath.Ext(outputFile)) {
+	case ".json":
+		return formatJSON, nil
+	case ".patch", ".diff":
+		return formatPatch, nil
+	}
+	return formatHTML, nil
+}
+
+// outputVariable matches the variables expanded in output file names.
+var outputVariable = regexp.MustCompile(`\{(\w+)\}`)
+
+// validateOutputFiles checks that output file names use only known
+// variables, so a typo fails before the comparison rather than after.
+func validateOutputFiles(outputFiles []string) error {
+	for _, outputFile := range outputFiles {
+		for _, m := range outputVariable.FindAllStringSubmatch(outputFile, -1) {
+			switch m[1] {
+			case "profile", "date", "ref", "sha":
+			default:
+				return fmt.Errorf("unknown variable %s in output file %q: expected {profile}, {date}, {ref} or {sha}", m[0], outputFile)
+			}
+		}
+	}
+	return nil
+}
+
+// expandOutputFile replaces the variables in an output file name: {profile}
+// with the selected profile ("default" without one), {date} with the day
+// the run started, and {ref} and {sha} with the branch or tag and the short
+// commit of the target. Both are empty when the target is not a git
+// repository; slashes in branch names become dashes.
+func expandOutputFile(outputFile string, config *Config, result ComparisonResult) string {
+	return outputVariable.ReplaceAllStringFunc(outputFile, func(v string) string {
+		target := result.Metadata.Target
+		switch v {
+		case "{profile}":
+			if config.Profile == "" {
+				return "default"
+			}
+			return config.Profile
+		case "{date}":
+			return result.StartedAt.Format("2006-01-02")
+		case "{ref}":
+			ref := target.Branch
+			if ref == "" {
+				ref = target.Tag
+			}
+			return strings.ReplaceAll(ref, "/", "-")
+		case "{sha}":
+			return shortSHA(target.Commit)
+		}
+		return v
+	})
+}
+
+// createOutput creates an output file and its directory, or returns stdout
+// for "-".
+func createOutput(outputFile string) (io.WriteCloser, error) {
+	if outputFile == stdoutFile {
+		return stdoutOutput{reportStdout}, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(outputFile), 0755); err != nil {
+		return nil, fmt.Errorf("error creating output directory: %w", err)
+	}
+	f, err := os.Create(outputFile)
+	if err != nil {
+		return nil, fmt.Errorf("error creating output file: %w", err)
+	}
+	return f, nil
+}
+
+// stdoutOutput leaves stdout open when a report is done.
+type stdoutOutput struct{ io.Writer }
+
+func (stdoutOutput) Close() error { return nil }
+
+// generatePatchReport writes the differences as a patch applying to the
+// source tree.
+func generatePatchReport(result ComparisonResult, outputFile string) error {
+	w, err := createOutput(outputFile)
+	if err != nil {
+		return err
+	}
+	if err := writePatch(w, result); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+// outputName names an output file in messages.
+func outputName(outputFile string) string {
+	if outputFile == stdoutFile {
+		return "stdout"
+	}
+	return outputFile
+}
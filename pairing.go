@@ -0,0 +1,115 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path"
+	"sort"
+)
+
+// filePair is a source file matched with its target counterpart. The paths
+// differ only when files were paired by something other than their path.
+type filePair struct {
+	SourcePath string
+	TargetPath string
+	SourceFile sourceFile
+	TargetFile sourceFile
+}
+
+// AmbiguousPairing describes a pairing key shared by several candidates on
+// either side, which therefore could not be paired automatically.
+type AmbiguousPairing struct {
+	Key    string   `json:"key"`
+	Source []string `json:"source"`
+	Target []string `json:"target"`
+}
+
+// pairFiles matches source and target files, given as relative path to file
+// maps. Files at identical relative paths are always paired; the
+// remaining files are paired by the secondary key selected by mode
+// ("basename" or "content-hash"). Unpaired paths are returned sorted.
+func pairFiles(sourceMap, targetMap map[string]sourceFile, mode string) (pairs []filePair, sourceOnly, targetOnly []string, ambiguous []AmbiguousPairing, err error) {
+	var keyFunc func(relPath string, file sourceFile) string
+	switch mode {
+	case "", "path":
+	case "basename":
+		keyFunc = func(relPath string, file sourceFile) string {
+			return path.Base(toSlash(relPath))
+		}
+	case "content-hash":
+		keyFunc = func(relPath string, file sourceFile) string {
+			content, err := readFileContent(file)
+			if err != nil {
+				return ""
+			}
+			sum := sha256.Sum256(content)
+			return hex.EncodeToString(sum[:])
+		}
+	default:
+		return nil, nil, nil, nil, fmt.Errorf("unknown pairing mode %q (expected path, basename, or content-hash)", mode)
+	}
+
+	remainingSource := make(map[string]sourceFile)
+	remainingTarget := make(map[string]sourceFile)
+	for p, f := range targetMap {
+		remainingTarget[p] = f
+	}
+	for p, sourceFile := range sourceMap {
+		if targetFile, exists := targetMap[p]; exists {
+			pairs = append(pairs, filePair{p, p, sourceFile, targetFile})
+			delete(remainingTarget, p)
+		} else {
+			remainingSource[p] = sourceFile
+		}
+	}
+
+	if keyFunc != nil {
+		sourceByKey := groupByKey(remainingSource, keyFunc)
+		targetByKey := groupByKey(remainingTarget, keyFunc)
+		for key, sources := range sourceByKey {
+			targets, ok := targetByKey[key]
+			if !ok || key == "" {
+				continue
+			}
+			if len(sources) > 1 || len(targets) > 1 {
+				label := key
+				if mode == "content-hash" {
+					label = "sha256:" + key[:12]
+				}
+				ambiguous = append(ambiguous, AmbiguousPairing{Key: label, Source: sources, Target: targets})
+				continue
+			}
+			s, t := sources[0], targets[0]
+			pairs = append(pairs, filePair{s, t, remainingSource[s], remainingTarget[t]})
+			delete(remainingSource, s)
+			delete(remainingTarget, t)
+		}
+	}
+
+	for p := range remainingSource {
+		sourceOnly = append(sourceOnly, p)
+	}
+	for p := range remainingTarget {
+		targetOnly = append(targetOnly, p)
+	}
+	sort.Strings(sourceOnly)
+	sort.Strings(targetOnly)
+	sort.Slice(pairs, func(i, j int) bool { return pairs[i].SourcePath < pairs[j].SourcePath })
+	sort.Slice(ambiguous, func(i, j int) bool { return ambiguous[i].Key < ambiguous[j].Key })
+	return pairs, sourceOnly, targetOnly, ambiguous, nil
+}
+
+// groupByKey groups relative paths by the key computed for each file. The
+// candidate lists are sorted for stable output.
+func groupByKey(files map[string]sourceFile, keyFunc func(relPath string, file sourceFile) string) map[string][]string {
+	groups := make(map[string][]string)
+	for p, f := range files {
+		key := keyFunc(p, f)
+		groups[key] = append(groups[key], p)
+	}
+	for _, g := range groups {
+		sort.Strings(g)
+	}
+	return groups
+}
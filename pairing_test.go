@@ -0,0 +1,107 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/adnsv/gitparator/testsupport"
+)
+
+// treeFiles writes files into a directory and returns them as pairFiles
+// takes them.
+func treeFiles(t *testing.T, files testsupport.Files) map[string]sourceFile {
+	t.Helper()
+	tree := newDirSource(testsupport.Dir(t, files))
+	m := make(map[string]sourceFile)
+	for p := range files {
+		m[p] = sourceFile{tree, p}
+	}
+	return m
+}
+
+func TestPairFiles(t *testing.T) {
+	tests := []struct {
+		name                   string
+		mode                   string
+		source, target         testsupport.Files
+		pairs                  [][2]string
+		sourceOnly, targetOnly []string
+		ambiguous              []AmbiguousPairing
+	}{
+		{
+			name:       "path",
+			source:     testsupport.Files{"a.txt": "a", "src/b.txt": "b"},
+			target:     testsupport.Files{"a.txt": "a", "lib/b.txt": "b"},
+			pairs:      [][2]string{{"a.txt", "a.txt"}},
+			sourceOnly: []string{"src/b.txt"},
+			targetOnly: []string{"lib/b.txt"},
+		},
+		{
+			name:   "basename",
+			mode:   "basename",
+			source: testsupport.Files{"a.txt": "a", "src/b.txt": "b"},
+			target: testsupport.Files{"a.txt": "a", "lib/b.txt": "changed"},
+			pairs:  [][2]string{{"a.txt", "a.txt"}, {"src/b.txt", "lib/b.txt"}},
+		},
+		{
+			name:   "same path before basename",
+			mode:   "basename",
+			source: testsupport.Files{"x/b.txt": "1", "y/b.txt": "2"},
+			target: testsupport.Files{"x/b.txt": "1", "z/b.txt": "2"},
+			pairs:  [][2]string{{"x/b.txt", "x/b.txt"}, {"y/b.txt", "z/b.txt"}},
+		},
+		{
+			name:       "ambiguous basename",
+			mode:       "basename",
+			source:     testsupport.Files{"x/b.txt": "1", "y/b.txt": "2"},
+			target:     testsupport.Files{"z/b.txt": "1"},
+			sourceOnly: []string{"x/b.txt", "y/b.txt"},
+			targetOnly: []string{"z/b.txt"},
+			ambiguous:  []AmbiguousPairing{{Key: "b.txt", Source: []string{"x/b.txt", "y/b.txt"}, Target: []string{"z/b.txt"}}},
+		},
+		{
+			name:       "content hash",
+			mode:       "content-hash",
+			source:     testsupport.Files{"old/name.txt": "same", "other.txt": "1"},
+			target:     testsupport.Files{"new/renamed.txt": "same", "another.txt": "2"},
+			pairs:      [][2]string{{"old/name.txt", "new/renamed.txt"}},
+			sourceOnly: []string{"other.txt"},
+			targetOnly: []string{"another.txt"},
+		},
+		{
+			name:       "ambiguous content hash",
+			mode:       "content-hash",
+			source:     testsupport.Files{"a.txt": "same"},
+			target:     testsupport.Files{"b.txt": "same", "c.txt": "same"},
+			sourceOnly: []string{"a.txt"},
+			targetOnly: []string{"b.txt", "c.txt"},
+			// sha256 of "same"
+			ambiguous: []AmbiguousPairing{{Key: "sha256:0967115f2813", Source: []string{"a.txt"}, Target: []string{"b.txt", "c.txt"}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pairs, sourceOnly, targetOnly, ambiguous, err := pairFiles(treeFiles(t, tt.source), treeFiles(t, tt.target), tt.mode)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var paired [][2]string
+			for _, p := range pairs {
+				paired = append(paired, [2]string{p.SourcePath, p.TargetPath})
+			}
+			if !reflect.DeepEqual(paired, tt.pairs) {
+				t.Errorf("pairs = %v, want %v", paired, tt.pairs)
+			}
+			if !reflect.DeepEqual(sourceOnly, tt.sourceOnly) || !reflect.DeepEqual(targetOnly, tt.targetOnly) {
+				t.Errorf("unpaired = %v and %v, want %v and %v", sourceOnly, targetOnly, tt.sourceOnly, tt.targetOnly)
+			}
+			if !reflect.DeepEqual(ambiguous, tt.ambiguous) {
+				t.Errorf("ambiguous = %+v, want %+v", ambiguous, tt.ambiguous)
+			}
+		})
+	}
+
+	if _, _, _, _, err := pairFiles(nil, nil, "size"); err == nil {
+		t.Error("pairFiles accepted an unknown mode")
+	}
+}
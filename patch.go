@@ -0,0 +1,252 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// patchContext is the number of unchanged lines around each hunk, as in
+// git diff.
+const patchContext = 3
+
+// patchLine is one line of a line diff, prefixed as in a unified diff:
+// ' ' for unchanged, '-' for deleted and '+' for inserted lines.
+type patchLine struct {
+	op   byte
+	text string
+}
+
+// writePatch writes the differences as a unified diff in git's format
+// that turns the source tree into the target, so it can be applied with
+// git apply in the source directory. Files only in the source are deleted,
+// files only in the target are added, and binary files are replaced as
+// GIT binary patch literals. Formatting-only, version-bump and recompressed
+// files are included, as their bytes differ; files found identical only
+// after normalization or ignoring hunks are not.
+func writePatch(w io.Writer, result ComparisonResult) error {
+	files := append(append(append([]filePair{}, result.differing...), result.equivalent...), result.unpaired...)
+	sort.Slice(files, func(i, j int) bool {
+		return patchPath(files[i]) < patchPath(files[j])
+	})
+
+	bw := bufio.NewWriter(w)
+	for _, pair := range files {
+		if err := writeFilePatch(bw, pair); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+func patchPath(pair filePair) string {
+	if pair.SourcePath != "" {
+		return toSlash(pair.SourcePath)
+	}
+	return toSlash(pair.TargetPath)
+}
+
+// writeFilePatch writes the patch of one file. A pair without a source or
+// target file adds or deletes the file.
+func writeFilePatch(w *bufio.Writer, pair filePair) error {
+	var source, target []byte
+	var err error
+	if pair.SourceFile.exists() {
+		if source, err = readFileContent(pair.SourceFile); err != nil {
+			return fmt.Errorf("error reading %s: %w", pair.SourceFile, err)
+		}
+	}
+	if pair.TargetFile.exists() {
+		if target, err = readFileContent(pair.TargetFile); err != nil {
+			return fmt.Errorf("error reading %s: %w", pair.TargetFile, err)
+		}
+	}
+
+	oldName, newName := "a/"+toSlash(pair.SourcePath), "b/"+toSlash(pair.TargetPath)
+	oldID, newID := blobID(source), blobID(target)
+	mode := " 100644"
+	switch {
+	case !pair.SourceFile.exists():
+		fmt.Fprintf(w, "diff --git a/%s %s\nnew file mode 100644\n", toSlash(pair.TargetPath), newName)
+		oldName, oldID, mode = "/dev/null", nullBlobID, ""
+	case !pair.TargetFile.exists():
+		fmt.Fprintf(w, "diff --git %s b/%s\ndeleted file mode 100644\n", oldName, toSlash(pair.SourcePath))
+		newName, newID, mode = "/dev/null", nullBlobID, ""
+	default:
+		fmt.Fprintf(w, "diff --git %s %s\n", oldName, newName)
+		if pair.SourcePath != pair.TargetPath {
+			fmt.Fprintf(w, "rename from %s\nrename to %s\n", toSlash(pair.SourcePath), toSlash(pair.TargetPath))
+		}
+	}
+
+	if isBinary(source) || isBinary(target) {
+		// git apply needs the full blob IDs to check binary preimages
+		fmt.Fprintf(w, "index %s..%s%s\nGIT binary patch\n", oldID, newID, mode)
+		if err := writeBinaryLiteral(w, target); err != nil {
+			return err
+		}
+		return writeBinaryLiteral(w, source)
+	}
+	if len(source) == 0 && len(target) == 0 {
+		return nil
+	}
+	fmt.Fprintf(w, "--- %s\n+++ %s\n", oldName, newName)
+	writeHunks(w, diffLines(source, target))
+	return nil
+}
+
+// diffLines returns the line diff of two contents.
+func diffLines(source, target []byte) []patchLine {
+	dmp := diffmatchpatch.New()
+	chars1, chars2, linePatches := dmp.DiffLinesToChars(string(source), string(target))
+	var lines []patchLine
+	for _, diff := range dmp.DiffCharsToLines(dmp.DiffMain(chars1, chars2, false), linePatches) {
+		op := byte(' ')
+		switch diff.Type {
+		case diffmatchpatch.DiffDelete:
+			op = '-'
+		case diffmatchpatch.DiffInsert:
+			op = '+'
+		}
+		for _, text := range strings.SplitAfter(diff.Text, "\n") {
+			if text != "" {
+				lines = append(lines, patchLine{op, text})
+			}
+		}
+	}
+	return lines
+}
+
+// writeHunks writes the changed lines with patchContext lines of context,
+// merging changes whose contexts overlap into one hunk.
+func writeHunks(w *bufio.Writer, lines []patchLine) {
+	var oldLine, newLine int // lines before index i
+	for i := 0; i < len(lines); {
+		if lines[i].op == ' ' {
+			oldLine++
+			newLine++
+			i++
+			continue
+		}
+
+		// Extend the hunk until the unchanged run after a change is longer
+		// than twice the context
+		start := max(i-patchContext, 0)
+		end := i
+		for end < len(lines) {
+			if lines[end].op != ' ' {
+				end++
+				continue
+			}
+			run := end
+			for run < len(lines) && lines[run].op == ' ' {
+				run++
+			}
+			if run == len(lines) || run-end > 2*patchContext {
+				end = min(end+patchContext, len(lines))
+				break
+			}
+			end = run
+		}
+
+		oldStart, newStart := oldLine-(i-start), newLine-(i-start)
+		var oldCount, newCount int
+		for _, line := range lines[start:end] {
+			if line.op != '+' {
+				oldCount++
+			}
+			if line.op != '-' {
+				newCount++
+			}
+		}
+		fmt.Fprintf(w, "@@ -%s +%s @@\n", hunkRange(oldStart, oldCount), hunkRange(newStart, newCount))
+		for _, line := range lines[start:end] {
+			w.WriteByte(line.op)
+			w.WriteString(line.text)
+			if !strings.HasSuffix(line.text, "\n") {
+				w.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+
+		for _, line := range lines[i:end] {
+			if line.op != '+' {
+				oldLine++
+			}
+			if line.op != '-' {
+				newLine++
+			}
+		}
+		i = end
+	}
+}
+
+// hunkRange formats the start line and line count of one side of a hunk.
+// Empty ranges name the line before them, as in git.
+func hunkRange(start, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
+
+// nullBlobID names the missing side of an added or deleted file.
+const nullBlobID = "0000000000000000000000000000000000000000"
+
+// blobID returns the git object ID of content as a blob.
+func blobID(content []byte) string {
+	h := sha1.New()
+	fmt.Fprintf(h, "blob %d\x00", len(content))
+	h.Write(content)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// base85Alphabet is the alphabet of git's base85 encoding, which differs
+// from Ascii85.
+const base85Alphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz!#$%&()*+-;<=>?@^_`{|}~"
+
+// writeBinaryLiteral writes one hunk of a GIT binary patch holding the
+// whole content: its size, then the deflated content in lines of up to 52
+// bytes, each encoded in base85 after a letter giving its length.
+func writeBinaryLiteral(w *bufio.Writer, content []byte) error {
+	var deflated bytes.Buffer
+	zw := zlib.NewWriter(&deflated)
+	zw.Write(content)
+	if err := zw.Close(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "literal %d\n", len(content))
+	data := deflated.Bytes()
+	for len(data) > 0 {
+		n := min(len(data), 52)
+		if n <= 26 {
+			w.WriteByte(byte('A' + n - 1))
+		} else {
+			w.WriteByte(byte('a' + n - 27))
+		}
+		for line := data[:n]; len(line) > 0; {
+			var group [4]byte
+			line = line[copy(group[:], line):]
+			value := binary.BigEndian.Uint32(group[:])
+			var encoded [5]byte
+			for i := 4; i >= 0; i-- {
+				encoded[i] = base85Alphabet[value%85]
+				value /= 85
+			}
+			w.Write(encoded[:])
+		}
+		w.WriteByte('\n')
+		data = data[n:]
+	}
+	w.WriteByte('\n')
+	return nil
+}
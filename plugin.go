@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"log"
+	"strings"
+)
+
+// Kinds of plugins.
+const (
+	pluginComparer = "comparer"
+	pluginReporter = "reporter"
+)
+
+// Plugin is an external executable extending gitparator. A comparer
+// decides whether the files matching Paths are equal; a reporter receives
+// the result of every run. Both exchange JSON on stdin and stdout and run
+// with the command limits of other external helpers.
+type Plugin struct {
+	Name    string   `mapstructure:"name" json:"name"`
+	Kind    string   `mapstructure:"kind" json:"kind"`
+	Paths   []string `mapstructure:"paths" json:"paths"`     // files handled by a comparer
+	Command string   `mapstructure:"command" json:"command"` // program and arguments
+}
+
+// comparerRequest is written to a comparer's stdin. Contents are base64
+// encoded.
+type comparerRequest struct {
+	Path   string `json:"path"`
+	Source []byte `json:"source"`
+	Target []byte `json:"target"`
+}
+
+// comparerResponse is read from a comparer's stdout. Diff lines starting
+// with "+" or "-" are rendered as inserted or deleted, other lines as
+// unchanged.
+type comparerResponse struct {
+	Equal bool   `json:"equal"`
+	Diff  string `json:"diff,omitempty"`
+}
+
+func validatePlugins(plugins []Plugin) error {
+	for i, p := range plugins {
+		name := p.Name
+		if name == "" {
+			name = fmt.Sprint(i + 1)
+		}
+		if len(strings.Fields(p.Command)) == 0 {
+			return fmt.Errorf("plugin %s: no command given", name)
+		}
+		switch p.Kind {
+		case pluginComparer:
+			if len(p.Paths) == 0 {
+				return fmt.Errorf("plugin %s: no paths given", name)
+			}
+		case pluginReporter:
+		default:
+			return fmt.Errorf("plugin %s: unknown kind %q (expected comparer or reporter)", name, p.Kind)
+		}
+	}
+	return nil
+}
+
+// comparerFor returns the first comparer plugin handling relPath, or nil.
+func comparerFor(relPath string, plugins []Plugin) *Plugin {
+	relPath = toSlash(relPath)
+	for i, p := range plugins {
+		if p.Kind == pluginComparer && shouldExclude(relPath, p.Paths) {
+			return &plugins[i]
+		}
+	}
+	return nil
+}
+
+// pluginCompare compares a pair with the comparer plugin of the run
+// handling it and returns the rendered diff of a difference. ok is false
+// when no plugin handles the pair or the plugin fails, so the built-in
+// comparison is used.
+func (run *runState) pluginCompare(pair filePair) (equal bool, diff string, ok bool) {
+	plugin := comparerFor(pair.SourcePath, run.config.Plugins)
+	if plugin == nil {
+		return false, "", false
+	}
+	source, target, err := run.readPair(pair, nil)
+	if err != nil {
+		return false, "", false
+	}
+	request, err := json.Marshal(comparerRequest{Path: toSlash(pair.SourcePath), Source: source, Target: target})
+	if err != nil {
+		return false, "", false
+	}
+
+	out, err := runLimited(plugin.Command, request, run.config.CommandLimits)
+	var response comparerResponse
+	if err == nil {
+		err = json.Unmarshal(out, &response)
+	}
+	if err != nil {
+		log.Printf("Warning: plugin %q failed on %s, comparing it as usual: %v", plugin.Name, pair.SourcePath, err)
+		return false, "", false
+	}
+	if response.Equal || response.Diff == "" {
+		return response.Equal, "", true
+	}
+	return false, run.renderPluginDiff(response.Diff), true
+}
+
+// renderPluginDiff renders the diff of a comparer plugin with the markup of
+// line diffs.
+func (run *runState) renderPluginDiff(diff string) string {
+	additions, deletions := 0, 0
+	var rows strings.Builder
+	for _, line := range strings.Split(strings.TrimSuffix(diff, "\n"), "\n") {
+		class, marker := "diff-equal", " "
+		switch {
+		case strings.HasPrefix(line, "+"):
+			class, marker = "diff-inserted", "+"
+			additions++
+		case strings.HasPrefix(line, "-"):
+			class, marker = "diff-deleted", "-"
+			deletions++
+		}
+		text := strings.TrimPrefix(line, marker)
+		fmt.Fprintf(&rows, "<div class=\"diff-line %s\"><span class=\"line-num\"></span><span class=\"diff-marker\">%s</span>%s</div>",
+			class, marker, template.HTMLEscapeString(string(run.redactContent([]byte(text)))))
+	}
+	return fmt.Sprintf("<div class=\"diff-content plugin\" data-additions=\"%d\" data-deletions=\"%d\">%s</div>",
+		additions, deletions, rows.String())
+}
+
+// runReporters passes the result to every reporter plugin of the run as
+// JSON and prints what they write.
+func (run *runState) runReporters(result ComparisonResult) {
+	var input []byte
+	for _, p := range run.config.Plugins {
+		if p.Kind != pluginReporter {
+			continue
+		}
+		if input == nil {
+			var err error
+			if input, err = json.Marshal(result); err != nil {
+				log.Printf("Warning: cannot encode the result for reporters: %v", err)
+				return
+			}
+		}
+		out, err := runLimited(p.Command, input, run.config.CommandLimits)
+		if err != nil {
+			log.Printf("Warning: reporter plugin %q failed: %v", p.Name, err)
+			continue
+		}
+		run.out.Write(out)
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// osMetadataExcludes lists files operating systems drop into directories
+// they display, which copies pick up or lose independently of the data.
+var osMetadataExcludes = []string{
+	"**/.DS_Store",
+	"**/Thumbs.db",
+	"**/desktop.ini",
+}
+
+// buildOutputExcludes lists files that build tools and operating systems
+// write with non-deterministic content, so they never match between two
+// otherwise identical builds.
+var buildOutputExcludes = append([]string{
+	"**/__pycache__/**",
+	"**/*.pyc",
+	"**/.buildinfo",
+	"**/*.tsbuildinfo",
+}, osMetadataExcludes...)
+
+// presetCommand is a subcommand that runs a comparison with the defaults
+// of a preset.
+type presetCommand struct {
+	Use    string
+	Preset string
+	Short  string
+}
+
+var presetCommands = []presetCommand{
+	{"drift", "drift", "Show how a project has drifted from the template it was created from"},
+	{"verify-release", "release", "Verify that a directory matches a published release archive"},
+	{"verify-build", "build-output", "Verify that a build output matches a reference build"},
+	{"mirror-check", "mirror", "Verify that a mirror matches its upstream repository exactly"},
+	{"backup-check", "backup", "Verify that a backup copy matches the original directory"},
+}
+
+// newPresetCommands creates the preset subcommands, which accept all flags
+// of the root command and share its checks and comparison engine.
+func newPresetCommands(preRun func(cmd *cobra.Command, args []string) error, run func(cmd *cobra.Command, args []string)) []*cobra.Command {
+	var cmds []*cobra.Command
+	for _, pc := range presetCommands {
+		cmds = append(cmds, &cobra.Command{
+			Use:         pc.Use,
+			Short:       pc.Short,
+			Args:        cobra.NoArgs,
+			Annotations: map[string]string{"preset": pc.Preset},
+			PreRunE:     preRun,
+			Run:         run,
+		})
+	}
+	return cmds
+}
+
+// applyPreset fills in the defaults of the selected preset. Settings given
+// explicitly on the command line or in the config file take precedence.
+func applyPreset(cmd *cobra.Command, config *Config) error {
+	if preset := cmd.Annotations["preset"]; preset != "" {
+		if config.Preset != "" && config.Preset != preset {
+			return fmt.Errorf("preset %q cannot be used with the %s command", config.Preset, cmd.Name())
+		}
+		config.Preset = preset
+	}
+
+	switch config.Preset {
+	case "":
+		return nil
+	case "drift":
+		// Drift reviews read the changes themselves, in context
+		if !isExplicit(cmd, "detailed_diff", "detailed-diff") {
+			config.DetailedDiff = true
+		}
+		if !isExplicit(cmd, "diff_context", "diff-context") {
+			config.DiffContext = 3
+		}
+		if !isExplicit(cmd, "suggest_moves", "suggest-moves") {
+			config.SuggestMoves = true
+		}
+		return nil
+	case "release":
+		if config.TargetRelease == "" && config.TargetZip == "" {
+			return fmt.Errorf("the release preset requires --target-release or --target-zip")
+		}
+		if !isExplicit(cmd, "ignore_archive_metadata", "ignore-archive-metadata") {
+			config.IgnoreArchiveMetadata = true
+		}
+		if !isExplicit(cmd, "detailed_diff", "detailed-diff") {
+			config.DetailedDiff = true
+		}
+		if !isExplicit(cmd, "diff_context", "diff-context") {
+			config.DiffContext = 3
+		}
+		return nil
+	case "mirror":
+		// A mirror must match byte for byte, ignored files included
+		if !isExplicit(cmd, "respect_gitignore", "respect-gitignore") {
+			config.RespectGitignore = false
+		}
+		return nil
+	case "backup":
+		if !isExplicit(cmd, "respect_gitignore", "respect-gitignore") {
+			config.RespectGitignore = false
+		}
+		if !isExplicit(cmd, "suggest_moves", "suggest-moves") {
+			config.SuggestMoves = true
+		}
+		config.ExcludePaths = append(config.ExcludePaths, osMetadataExcludes...)
+		return nil
+	case "build-output":
+		// Build outputs are usually gitignored, so ignore rules would hide them
+		if !isExplicit(cmd, "respect_gitignore", "respect-gitignore") {
+			config.RespectGitignore = false
+		}
+		if !isExplicit(cmd, "ignore_archive_metadata", "ignore-archive-metadata") {
+			config.IgnoreArchiveMetadata = true
+		}
+		config.ExcludePaths = append(config.ExcludePaths, buildOutputExcludes...)
+		return nil
+	default:
+		return fmt.Errorf("unknown preset %q (expected drift, release, build-output, mirror or backup)", config.Preset)
+	}
+}
+
+// isExplicit reports whether a setting was given in the config file or on
+// the command line rather than taken from the flag default.
+func isExplicit(cmd *cobra.Command, key, flag string) bool {
+	return viper.InConfig(key) || cmd.Flags().Changed(flag)
+}
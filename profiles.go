@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/viper"
+)
+
+// targetKeys are the mutually exclusive settings that select the target.
+var targetKeys = []string{"target", "target_url", "target_path", "target_zip", "target_module", "target_release", "target_manifest"}
+
+// selectProfile merges the settings of profiles.<name> over the top-level
+// config file settings. Flags given on the command line still win, since
+// viper ranks them above config values.
+func selectProfile(name string) error {
+	key := "profiles." + name
+	if !viper.IsSet(key) {
+		return fmt.Errorf("profile %q is not defined in the config file", name)
+	}
+	settings := viper.GetStringMap(key)
+
+	// A profile that names its own target replaces the top-level target
+	// instead of conflicting with it
+	for _, k := range targetKeys {
+		if _, ok := settings[k]; ok {
+			for _, other := range targetKeys {
+				if _, ok := settings[other]; !ok {
+					settings[other] = ""
+				}
+			}
+			break
+		}
+	}
+
+	return viper.MergeConfigMap(settings)
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// progressReporter prints periodic status lines for long-running phases.
+// On a terminal the line is redrawn in place; otherwise a plain line is
+// emitted at a slower rate so CI logs stay readable.
+type progressReporter struct {
+	enabled  bool
+	tty      bool
+	phase    string
+	done     int
+	total    int
+	bytes    bool // count bytes rather than files
+	last     time.Time
+	interval time.Duration
+	out      io.Writer
+}
+
+func newProgressReporter(enabled bool) *progressReporter {
+	p := &progressReporter{
+		enabled:  enabled,
+		out:      os.Stderr,
+		interval: 5 * time.Second,
+	}
+	if fi, err := os.Stderr.Stat(); err == nil && fi.Mode()&os.ModeCharDevice != 0 {
+		p.tty = true
+		p.interval = 200 * time.Millisecond
+	}
+	return p
+}
+
+// Start begins a new phase. A total of 0 means the amount of work is not
+// known in advance.
+func (p *progressReporter) Start(phase string, total int) {
+	p.phase = phase
+	p.done = 0
+	p.total = total
+	p.bytes = false
+	p.last = time.Now()
+}
+
+// StartBytes begins a new phase that counts bytes, such as a download.
+func (p *progressReporter) StartBytes(phase string, total int) {
+	p.Start(phase, total)
+	p.bytes = true
+}
+
+// Add records n completed items and prints a status line if the reporting
+// interval has elapsed.
+func (p *progressReporter) Add(n int) {
+	p.done += n
+	if !p.enabled || time.Since(p.last) < p.interval {
+		return
+	}
+	p.last = time.Now()
+	p.print()
+}
+
+// Finish prints the final state of the current phase.
+func (p *progressReporter) Finish() {
+	if !p.enabled {
+		return
+	}
+	p.print()
+	if p.tty {
+		fmt.Fprintln(p.out)
+	}
+}
+
+// Writer returns the destination for sideband output of external operations
+// such as cloning, or nil when progress reporting is disabled.
+func (p *progressReporter) Writer() io.Writer {
+	if !p.enabled {
+		return nil
+	}
+	return p.out
+}
+
+// Write counts the bytes passing through it, for use with io.TeeReader.
+func (p *progressReporter) Write(b []byte) (int, error) {
+	p.Add(len(b))
+	return len(b), nil
+}
+
+func (p *progressReporter) print() {
+	if p.bytes {
+		line := fmt.Sprintf("%s: %s", p.phase, formatBytes(uint64(p.done)))
+		if p.total > 0 {
+			line = fmt.Sprintf("%s: %s of %s (%d%%)",
+				p.phase, formatBytes(uint64(p.done)), formatBytes(uint64(p.total)), p.done*100/p.total)
+		}
+		p.printLine(line)
+		return
+	}
+	line := fmt.Sprintf("%s: %d files", p.phase, p.done)
+	if p.total > 0 {
+		line = fmt.Sprintf("%s: %d/%d files (%d%%), %d remaining",
+			p.phase, p.done, p.total, p.done*100/p.total, p.total-p.done)
+	}
+	p.printLine(line)
+}
+
+func (p *progressReporter) printLine(line string) {
+	if p.tty {
+		fmt.Fprintf(p.out, "\r\033[K%s", line)
+	} else {
+		fmt.Fprintln(p.out, line)
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"image"
+	"image/png"
+	"io"
+)
+
+// Formats whose content is compared decompressed with --recompressed.
+const (
+	notCompressed = iota
+	compressedGzip
+	compressedBzip2
+	compressedPNG
+)
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// compressionFormat classifies content by its magic bytes, so compressed
+// files are recognized whatever their extension (.gz, .tgz, .svgz, ...).
+func compressionFormat(content []byte) int {
+	switch {
+	case bytes.HasPrefix(content, []byte{0x1f, 0x8b}):
+		return compressedGzip
+	case len(content) > 3 && bytes.HasPrefix(content, []byte("BZh")) && content[3] >= '1' && content[3] <= '9':
+		return compressedBzip2
+	case bytes.HasPrefix(content, pngSignature):
+		return compressedPNG
+	}
+	return notCompressed
+}
+
+// recompressedOnly reports whether two compressed files differ only in how
+// their content was compressed: gzip or bzip2 streams decompressing to the
+// same bytes, or PNG images with the same pixels, as after recompressing
+// with another level or running an optimizer. Gzip headers, with their
+// timestamps and file names, are ignored too. Content is decompressed
+// within limits.
+func recompressedOnly(pair filePair, limits ArchiveLimits) bool {
+	source, err1 := readFileContent(pair.SourceFile)
+	target, err2 := readFileContent(pair.TargetFile)
+	if err1 != nil || err2 != nil {
+		return false
+	}
+	format := compressionFormat(source)
+	if format == notCompressed || compressionFormat(target) != format {
+		return false
+	}
+
+	name := pair.SourceFile.String()
+	if format == compressedPNG {
+		return pngPixelsEqual(name, source, target, limits)
+	}
+	content1, err1 := decompressContent(name, source, format, limits)
+	content2, err2 := decompressContent(name, target, format, limits)
+	return err1 == nil && err2 == nil && bytes.Equal(content1, content2)
+}
+
+// decompressContent decompresses a gzip or bzip2 stream within limits.
+func decompressContent(name string, content []byte, format int, limits ArchiveLimits) ([]byte, error) {
+	var r io.Reader
+	if format == compressedGzip {
+		zr, err := gzip.NewReader(bytes.NewReader(content))
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		r = zr
+	} else {
+		r = bzip2.NewReader(bytes.NewReader(content))
+	}
+	return io.ReadAll(&guardedReader{r: r, budget: newArchiveBudget(name, limits), compressed: int64(len(content))})
+}
+
+// pngPixelsEqual reports whether two PNG images have the same size and
+// pixels. Images decoding to more pixel data than limits allow are not
+// decoded.
+func pngPixelsEqual(name string, content1, content2 []byte, limits ArchiveLimits) bool {
+	img1, err1 := decodePNG(name, content1, limits)
+	img2, err2 := decodePNG(name, content2, limits)
+	if err1 != nil || err2 != nil || img1.Bounds() != img2.Bounds() {
+		return false
+	}
+	b := img1.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			r1, g1, b1, a1 := img1.At(x, y).RGBA()
+			r2, g2, b2, a2 := img2.At(x, y).RGBA()
+			if r1 != r2 || g1 != g2 || b1 != b2 || a1 != a2 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func decodePNG(name string, content []byte, limits ArchiveLimits) (image.Image, error) {
+	cfg, err := png.DecodeConfig(bytes.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+	// At most 8 bytes per pixel, for 16-bit RGBA
+	size := int64(cfg.Width) * int64(cfg.Height) * 8
+	if err := newArchiveBudget(name, limits).add("pixels", size, int64(len(content))); err != nil {
+		return nil, err
+	}
+	return png.Decode(bytes.NewReader(content))
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+)
+
+const redactedText = "[REDACTED]"
+
+func compileRedactPatterns(patterns []string) ([]*regexp.Regexp, error) {
+	var compiled []*regexp.Regexp
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redact pattern %q: %w", p, err)
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled, nil
+}
+
+// redactContent masks all matches of the redact patterns of the run, so
+// that reports can be shared without the sensitive strings found in
+// compared files.
+func (run *runState) redactContent(content []byte) []byte {
+	for _, re := range run.redactors {
+		content = re.ReplaceAll(content, []byte(redactedText))
+	}
+	return content
+}
@@ -0,0 +1,335 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/adnsv/gitparator/wildpath"
+)
+
+// releaseInfo is the forge-independent subset of release metadata needed to
+// pick a downloadable archive.
+type releaseInfo struct {
+	TagName    string
+	ArchiveURL string
+	Assets     []releaseAsset
+}
+
+type releaseAsset struct {
+	Name string
+	URL  string
+}
+
+// API endpoints of the forges, replaced by tests.
+var (
+	githubAPI = "https://api.github.com"
+	gitlabAPI = "https://gitlab.com/api/v4"
+)
+
+// fetchRelease resolves a release of repo ("owner/name") on the given forge.
+// The release argument is either "latest" or a tag name.
+func fetchRelease(forge, repo, release string) (*releaseInfo, error) {
+	switch forge {
+	case "", "github":
+		return fetchGitHubRelease(repo, release)
+	case "gitlab":
+		return fetchGitLabRelease(repo, release)
+	default:
+		return nil, fmt.Errorf("unsupported forge %q (expected github or gitlab)", forge)
+	}
+}
+
+func fetchGitHubRelease(repo, release string) (*releaseInfo, error) {
+	endpoint := githubAPI + "/repos/" + repo + "/releases/latest"
+	if release != "latest" {
+		endpoint = githubAPI + "/repos/" + repo + "/releases/tags/" + url.PathEscape(release)
+	}
+
+	var payload struct {
+		TagName    string `json:"tag_name"`
+		ZipballURL string `json:"zipball_url"`
+		Assets     []struct {
+			Name string `json:"name"`
+			URL  string `json:"browser_download_url"`
+		} `json:"assets"`
+	}
+	if err := getJSON(endpoint, forgeAPIHeaders("github", endpoint), &payload); err != nil {
+		return nil, err
+	}
+
+	info := &releaseInfo{TagName: payload.TagName, ArchiveURL: payload.ZipballURL}
+	for _, a := range payload.Assets {
+		info.Assets = append(info.Assets, releaseAsset{Name: a.Name, URL: a.URL})
+	}
+	return info, nil
+}
+
+func fetchGitLabRelease(repo, release string) (*releaseInfo, error) {
+	endpoint := gitlabAPI + "/projects/" + url.PathEscape(repo) + "/releases/permalink/latest"
+	if release != "latest" {
+		endpoint = gitlabAPI + "/projects/" + url.PathEscape(repo) + "/releases/" + url.PathEscape(release)
+	}
+
+	var payload struct {
+		TagName string `json:"tag_name"`
+		Assets  struct {
+			Sources []struct {
+				Format string `json:"format"`
+				URL    string `json:"url"`
+			} `json:"sources"`
+			Links []struct {
+				Name string `json:"name"`
+				URL  string `json:"url"`
+			} `json:"links"`
+		} `json:"assets"`
+	}
+	if err := getJSON(endpoint, forgeAPIHeaders("gitlab", endpoint), &payload); err != nil {
+		return nil, err
+	}
+
+	info := &releaseInfo{TagName: payload.TagName}
+	for _, s := range payload.Assets.Sources {
+		if s.Format == "zip" {
+			info.ArchiveURL = s.URL
+		}
+	}
+	for _, l := range payload.Assets.Links {
+		info.Assets = append(info.Assets, releaseAsset{Name: l.Name, URL: l.URL})
+	}
+	return info, nil
+}
+
+// forgeHeaders returns authentication headers taken from the environment,
+// which lifts API rate limits and grants access to private repositories.
+// The token of a forge is only sent to its own hosts, never to a download
+// URL elsewhere that a release happens to link.
+func forgeHeaders(forge, endpoint string) map[string]string {
+	headers := map[string]string{}
+	if !isForgeHost(forge, endpoint) {
+		return headers
+	}
+	switch forge {
+	case "", "github":
+		if token := os.Getenv("GITHUB_TOKEN"); token != "" {
+			headers["Authorization"] = "Bearer " + token
+		}
+	case "gitlab":
+		if token := os.Getenv("GITLAB_TOKEN"); token != "" {
+			headers["PRIVATE-TOKEN"] = token
+		}
+	}
+	return headers
+}
+
+// isForgeHost reports whether endpoint is served by the API of forge or,
+// for GitHub, by github.com, where release assets are downloaded from.
+func isForgeHost(forge, endpoint string) bool {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return false
+	}
+	var hosts []string
+	switch forge {
+	case "", "github":
+		hosts = []string{apiHost(githubAPI), "github.com"}
+	case "gitlab":
+		hosts = []string{apiHost(gitlabAPI)}
+	}
+	for _, host := range hosts {
+		if strings.EqualFold(u.Host, host) {
+			return true
+		}
+	}
+	return false
+}
+
+func apiHost(api string) string {
+	u, err := url.Parse(api)
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
+
+// forgeAPIHeaders returns the headers of forge API calls: forgeHeaders and
+// the media type of the API, which asset downloads must not ask for.
+func forgeAPIHeaders(forge, endpoint string) map[string]string {
+	headers := forgeHeaders(forge, endpoint)
+	if forge == "" || forge == "github" {
+		headers["Accept"] = "application/vnd.github+json"
+	}
+	return headers
+}
+
+func getJSON(endpoint string, headers map[string]string, v any) error {
+	resp, err := httpGet(endpoint, headers)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("error decoding response from %s: %w", endpoint, err)
+	}
+	return nil
+}
+
+// httpClient makes the HTTP requests of gitparator. A server that stops
+// responding fails the request instead of stalling the run; the overall
+// timeout leaves room for downloading large archives.
+var httpClient = &http.Client{
+	Timeout:   30 * time.Minute,
+	Transport: newHTTPTransport(),
+}
+
+func newHTTPTransport() http.RoundTripper {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.ResponseHeaderTimeout = time.Minute
+	return transport
+}
+
+func httpGet(endpoint string, headers map[string]string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("GET %s: %s", endpoint, resp.Status)
+	}
+	return resp, nil
+}
+
+// downloadFile saves the resource at endpoint into dir and returns the path
+// of the created file. A partial download is removed.
+func (run *runState) downloadFile(endpoint, dir, name string, headers map[string]string) (string, error) {
+	resp, err := httpGet(endpoint, headers)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	path, err := safeJoin(dir, name)
+	if err != nil {
+		return "", err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+
+	run.progress.StartBytes("Downloading "+name, int(max(resp.ContentLength, 0)))
+	defer run.progress.Finish()
+	_, err = io.Copy(f, io.TeeReader(resp.Body, run.progress))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return "", fmt.Errorf("error downloading %s: %w", endpoint, err)
+	}
+	return path, nil
+}
+
+// selectAsset picks the single release asset whose name matches pattern,
+// which may be an exact name or a glob such as "*-linux-amd64.zip". Case
+// is ignored if ignoreCase is set.
+func selectAsset(info *releaseInfo, pattern string, ignoreCase bool) (releaseAsset, error) {
+	match := wildpath.Match
+	if ignoreCase {
+		match = wildpath.MatchFold
+	}
+	var matches []releaseAsset
+	for _, a := range info.Assets {
+		if match(pattern, a.Name) {
+			matches = append(matches, a)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return releaseAsset{}, fmt.Errorf("release %s has no asset matching %q", info.TagName, pattern)
+	case 1:
+		return matches[0], nil
+	default:
+		names := make([]string, len(matches))
+		for i, a := range matches {
+			names[i] = a.Name
+		}
+		return releaseAsset{}, fmt.Errorf("asset pattern %q is ambiguous in release %s: %s",
+			pattern, info.TagName, strings.Join(names, ", "))
+	}
+}
+
+// downloadRelease resolves the release configured for the run and
+// downloads either its source archive or the named zip or tarball asset,
+// returning the local archive path and the paths of its downloaded
+// sidecars. Nothing is left behind on errors.
+func (run *runState) downloadRelease() (path string, sidecars []string, err error) {
+	config := run.config
+	if config.Repo == "" {
+		return "", nil, fmt.Errorf("--target-release requires --repo")
+	}
+
+	info, err := fetchRelease(config.Forge, config.Repo, config.TargetRelease)
+	if err != nil {
+		return "", nil, fmt.Errorf("error resolving release: %w", err)
+	}
+
+	archiveURL := info.ArchiveURL
+	archiveName := "release.zip"
+	if config.ReleaseAsset != "" {
+		asset, err := selectAsset(info, config.ReleaseAsset, config.IgnoreCase)
+		if err != nil {
+			return "", nil, err
+		}
+		archiveURL = asset.URL
+		archiveName = asset.Name
+	}
+	if archiveURL == "" {
+		return "", nil, fmt.Errorf("release %s has no zip source archive", info.TagName)
+	}
+	if detectArchiveFormat(archiveName) == notArchive {
+		return "", nil, fmt.Errorf("asset %q is not a zip archive or tarball", archiveName)
+	}
+
+	fmt.Fprintf(run.out, "Downloading %s of %s release %s\n", archiveName, config.Repo, info.TagName)
+	path, err = run.downloadFile(archiveURL, config.TempDir, archiveName, forgeHeaders(config.Forge, archiveURL))
+	if err != nil || !config.VerifySidecars {
+		return path, nil, err
+	}
+	// Checksum and signature files are published as assets of their own
+	for _, ext := range []string{checksumSidecar, signatureSidecar} {
+		for _, a := range info.Assets {
+			if a.Name != archiveName+ext {
+				continue
+			}
+			sidecar, err := run.downloadFile(a.URL, config.TempDir, a.Name, forgeHeaders(config.Forge, a.URL))
+			if err != nil {
+				for _, p := range append(sidecars, path) {
+					os.Remove(p)
+				}
+				return "", nil, err
+			}
+			sidecars = append(sidecars, sidecar)
+		}
+	}
+	return path, sidecars, nil
+}
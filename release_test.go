@@ -0,0 +1,190 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/adnsv/gitparator/testsupport"
+)
+
+// brokenAsset is the content of assets whose download breaks off.
+const brokenAsset = "\x00broken"
+
+// releaseServer serves the GitHub release v1 of o/r with its assets.
+func releaseServer(t *testing.T, assets map[string]string) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	mux.HandleFunc("/repos/o/r/releases/tags/v1", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept") != "application/vnd.github+json" {
+			t.Errorf("API call asked for %q", r.Header.Get("Accept"))
+		}
+		type asset struct {
+			Name string `json:"name"`
+			URL  string `json:"browser_download_url"`
+		}
+		release := struct {
+			TagName string  `json:"tag_name"`
+			Assets  []asset `json:"assets"`
+		}{TagName: "v1"}
+		for name := range assets {
+			release.Assets = append(release.Assets, asset{name, server.URL + "/download/" + name})
+		}
+		json.NewEncoder(w).Encode(release)
+	})
+	mux.HandleFunc("/download/{name}", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept") != "" {
+			t.Errorf("asset download asked for %s", r.Header.Get("Accept"))
+		}
+		content := assets[r.PathValue("name")]
+		if content == brokenAsset {
+			// Announce more than is sent, so the download fails midway
+			w.Header().Set("Content-Length", "1000")
+			w.Write([]byte("partial"))
+			return
+		}
+		w.Write([]byte(content))
+	})
+
+	saved := githubAPI
+	githubAPI = server.URL
+	t.Cleanup(func() { githubAPI = saved })
+	return server
+}
+
+func releaseConfig(t *testing.T, asset string) Config {
+	t.Helper()
+	config := defaultConfig(t)
+	config.TargetRelease, config.Repo, config.ReleaseAsset = "v1", "o/r", asset
+	config.TempDir = testsupport.Dir(t, testsupport.Files{"keep.txt": "user file\n"})
+	return config
+}
+
+func TestReleaseTargetCleanup(t *testing.T) {
+	archive := testsupport.Zip(t, "", targetFiles)
+	content, err := os.ReadFile(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(content)
+	releaseServer(t, map[string]string{
+		"project.zip":        string(content),
+		"project.zip.sha256": hex.EncodeToString(sum[:]) + "  project.zip\n",
+	})
+
+	config := releaseConfig(t, "project.zip")
+	config.VerifySidecars = true
+	run := startTestRun(t, &config)
+	if err := (releaseTargetResolver{}).Resolve(run, &run.target); err != nil {
+		t.Fatal(err)
+	}
+	if v := run.target.Verification; v == nil || v.Checksum != "verified" {
+		t.Errorf("verification = %+v, want a verified checksum", v)
+	}
+	run.close()
+
+	want := testsupport.Files{"keep.txt": "user file\n"}
+	if got := testsupport.ReadFiles(t, config.TempDir); !reflect.DeepEqual(got, want) {
+		t.Errorf("temp dir after the run = %v, want %v", got, want)
+	}
+}
+
+func TestReleaseTargetPartialDownload(t *testing.T) {
+	releaseServer(t, map[string]string{"project.zip": brokenAsset})
+	config := releaseConfig(t, "project.zip")
+
+	if _, _, err := startTestRun(t, &config).downloadRelease(); err == nil {
+		t.Fatal("downloadRelease succeeded with a broken download")
+	}
+	if _, err := os.Stat(filepath.Join(config.TempDir, "project.zip")); !os.IsNotExist(err) {
+		t.Errorf("partial download left behind: %v", err)
+	}
+}
+
+func TestReleaseTargetTarball(t *testing.T) {
+	content, err := os.ReadFile(testsupport.Tarball(t, "project-1.0/", targetFiles, true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	releaseServer(t, map[string]string{"project-1.0.tar.gz": string(content), "notes.txt": "notes\n"})
+
+	result := compareFixtures(t, func(config *Config) {
+		config.SourceDir = testsupport.Dir(t, sourceFiles)
+		config.TargetRelease, config.Repo, config.ReleaseAsset = "v1", "o/r", "*.tar.gz"
+		config.TempDir = t.TempDir()
+	})
+	if got := classified(result); !reflect.DeepEqual(got, wantClassified) {
+		t.Errorf("classified = %v, want %v", got, wantClassified)
+	}
+
+	config := releaseConfig(t, "notes.txt")
+	if _, _, err := startTestRun(t, &config).downloadRelease(); err == nil || !strings.Contains(err.Error(), "not a zip archive or tarball") {
+		t.Errorf("downloading a text asset: %v", err)
+	}
+}
+
+func TestForgeHeaders(t *testing.T) {
+	t.Setenv("GITHUB_TOKEN", "gh-secret")
+	t.Setenv("GITLAB_TOKEN", "gl-secret")
+	tests := []struct {
+		forge, endpoint string
+		want            map[string]string
+	}{
+		{"github", "https://api.github.com/repos/o/r/releases/latest", map[string]string{"Authorization": "Bearer gh-secret"}},
+		{"", "https://github.com/o/r/releases/download/v1/project.zip", map[string]string{"Authorization": "Bearer gh-secret"}},
+		{"github", "https://objects.githubusercontent.com/project.zip", map[string]string{}},
+		{"github", "https://downloads.example.com/project.zip", map[string]string{}},
+		{"github", "https://gitlab.com/api/v4/projects", map[string]string{}},
+		{"gitlab", "https://gitlab.com/api/v4/projects/o%2Fr/releases/v1", map[string]string{"PRIVATE-TOKEN": "gl-secret"}},
+		{"gitlab", "https://GITLAB.com/o/r/-/archive/v1/r-v1.zip", map[string]string{"PRIVATE-TOKEN": "gl-secret"}},
+		{"gitlab", "https://github.com/o/r/releases/download/v1/project.zip", map[string]string{}},
+		{"gitlab", "https://downloads.example.com/project.zip", map[string]string{}},
+	}
+	for _, tt := range tests {
+		if got := forgeHeaders(tt.forge, tt.endpoint); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("forgeHeaders(%q, %q) = %v, want %v", tt.forge, tt.endpoint, got, tt.want)
+		}
+	}
+}
+
+func TestReleaseAssetElsewhere(t *testing.T) {
+	t.Setenv("GITHUB_TOKEN", "gh-secret")
+	content, err := os.ReadFile(testsupport.Zip(t, "", targetFiles))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The release links an asset hosted outside the forge
+	downloads := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if auth := r.Header.Get("Authorization"); auth != "" {
+			t.Errorf("asset download on another host sent %q", auth)
+		}
+		w.Write(content)
+	}))
+	t.Cleanup(downloads.Close)
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if auth := r.Header.Get("Authorization"); auth != "Bearer gh-secret" {
+			t.Errorf("API call sent %q", auth)
+		}
+		io.WriteString(w, `{"tag_name": "v1", "assets": [{"name": "project.zip", "browser_download_url": "`+downloads.URL+`/project.zip"}]}`)
+	}))
+	t.Cleanup(api.Close)
+	saved := githubAPI
+	githubAPI = api.URL
+	t.Cleanup(func() { githubAPI = saved })
+
+	config := releaseConfig(t, "project.zip")
+	if _, _, err := startTestRun(t, &config).downloadRelease(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+)
+
+// isRemoteArchive reports whether a target zip is given as an http(s) URL.
+func isRemoteArchive(target string) bool {
+	target = strings.ToLower(target)
+	return strings.HasPrefix(target, "http://") || strings.HasPrefix(target, "https://")
+}
+
+// downloadTargetZip downloads the archive at endpoint into dir and returns
+// the local path. The archive keeps its name, so its format is detected as
+// for local files; names without a known extension are read as zip.
+func (run *runState) downloadTargetZip(endpoint, dir string) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", fmt.Errorf("invalid target zip URL: %w", err)
+	}
+	name := path.Base(u.Path)
+	if detectArchiveFormat(name) == notArchive {
+		name = "target.zip"
+	}
+	fmt.Fprintf(run.out, "Downloading %s\n", endpoint)
+	return run.downloadFile(endpoint, dir, name, nil)
+}
+
+// verifySHA256 checks a file against an expected hex-encoded SHA-256
+// digest.
+func verifySHA256(file, expected string) error {
+	expected = strings.ToLower(strings.TrimSpace(expected))
+	if _, err := hex.DecodeString(expected); err != nil || len(expected) != 2*sha256.Size {
+		return fmt.Errorf("invalid SHA-256 digest '%s'", expected)
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return err
+	}
+	if actual := hex.EncodeToString(h.Sum(nil)); actual != expected {
+		return fmt.Errorf("SHA-256 of %s is %s, expected %s", file, actual, expected)
+	}
+	return nil
+}
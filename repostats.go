@@ -0,0 +1,120 @@
+package main
+
+import (
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2/lexers"
+)
+
+// RepoStats summarizes both compared trees, to give the file-by-file
+// results some context.
+type RepoStats struct {
+	Source    TreeStats       `json:"source"`
+	Target    TreeStats       `json:"target"`
+	Languages []LanguageStats `json:"languages"` // by lines across both trees, descending
+}
+
+// TreeStats counts the compared files of one tree. Lines are counted in
+// text files only.
+type TreeStats struct {
+	Files int   `json:"files"`
+	Bytes int64 `json:"bytes"`
+	Lines int   `json:"lines"`
+}
+
+type LanguageStats struct {
+	Language    string `json:"language"`
+	SourceFiles int    `json:"source_files"`
+	TargetFiles int    `json:"target_files"`
+	SourceLines int    `json:"source_lines"`
+	TargetLines int    `json:"target_lines"`
+}
+
+// Languages of files no lexer is registered for.
+const (
+	languageOther  = "Other"
+	languageBinary = "Binary"
+)
+
+// collectRepoStats reads the files of both trees, given as relative path
+// to file, and counts them by language.
+func collectRepoStats(sourceMap, targetMap map[string]sourceFile) *RepoStats {
+	stats := &RepoStats{}
+	languages := make(map[string]*LanguageStats)
+	detected := make(map[string]string) // by extension, or name if none
+
+	count := func(files map[string]sourceFile, tree *TreeStats, source bool) {
+		for relPath, file := range files {
+			content, err := readFileContent(file)
+			if err != nil {
+				continue
+			}
+			tree.Files++
+			tree.Bytes += int64(len(content))
+
+			language, lines := languageBinary, 0
+			if !isBinary(content) {
+				language = detectLanguage(relPath, detected)
+				lines = countLines(string(content))
+				tree.Lines += lines
+			}
+			l := languages[language]
+			if l == nil {
+				l = &LanguageStats{Language: language}
+				languages[language] = l
+			}
+			if source {
+				l.SourceFiles++
+				l.SourceLines += lines
+			} else {
+				l.TargetFiles++
+				l.TargetLines += lines
+			}
+		}
+	}
+	count(sourceMap, &stats.Source, true)
+	count(targetMap, &stats.Target, false)
+
+	for _, l := range languages {
+		stats.Languages = append(stats.Languages, *l)
+	}
+	sort.Slice(stats.Languages, func(i, j int) bool {
+		a, b := stats.Languages[i], stats.Languages[j]
+		if a.SourceLines+a.TargetLines != b.SourceLines+b.TargetLines {
+			return a.SourceLines+a.TargetLines > b.SourceLines+b.TargetLines
+		}
+		return a.Language < b.Language
+	})
+	return stats
+}
+
+// detectLanguage names the language of a file by the lexer matching its
+// name. Lookups are memoized, since matching tries every lexer.
+func detectLanguage(relPath string, detected map[string]string) string {
+	name := path.Base(toSlash(relPath))
+	key := strings.ToLower(path.Ext(name))
+	if key == "" {
+		key = name
+	}
+	if language, ok := detected[key]; ok {
+		return language
+	}
+	language := languageOther
+	if lexer := lexers.Match(name); lexer != nil {
+		language = lexer.Config().Name
+	}
+	detected[key] = language
+	return language
+}
+
+// countLines returns the number of lines of text, counting a final line
+// without a newline.
+func countLines(text string) int {
+	n := strings.Count(text, "\n")
+	if text != "" && !strings.HasSuffix(text, "\n") {
+		n++
+	}
+	return n
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// newRerunCommand creates the rerun subcommand, which repeats the
+// comparison recorded in a JSON result.
+func newRerunCommand(config *Config) *cobra.Command {
+	return &cobra.Command{
+		Use:   "rerun <result.json>",
+		Short: "Repeat a comparison with the options recorded in its JSON result",
+		Long: `Rerun repeats the comparison recorded in a JSON result with the effective
+configuration stored in its metadata, ignoring the config file. Flags given
+on the command line take precedence, e.g. --output-file to keep the
+original report. Relative paths are resolved against the current
+directory, as in the original run.`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			options, err := loadRunOptions(args[0], *config, cmd.Flags())
+			if err != nil {
+				fmt.Println("Error:", err)
+				os.Exit(1)
+			}
+			*config = options
+			runMain(config)
+		},
+	}
+}
+
+// loadRunOptions reads the effective configuration of a JSON result and
+// overlays the settings of the flags changed on the command line, taken
+// from current. Flags bound to nested keys, such as expect_owner.uid,
+// replace only that field of the recorded options.
+func loadRunOptions(file string, current Config, flags *pflag.FlagSet) (Config, error) {
+	var recorded struct {
+		Metadata struct {
+			Config map[string]any `json:"config"`
+		} `json:"metadata"`
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return Config{}, fmt.Errorf("error reading result: %w", err)
+	}
+	if err := decodeJSONNumbers(data, &recorded); err != nil {
+		return Config{}, fmt.Errorf("error parsing result %s: %w", file, err)
+	}
+	options := recorded.Metadata.Config
+	if len(options) == 0 {
+		return Config{}, fmt.Errorf("result %s records no configuration", file)
+	}
+
+	data, err = json.Marshal(current)
+	if err != nil {
+		return Config{}, err
+	}
+	var explicit map[string]any
+	if err := decodeJSONNumbers(data, &explicit); err != nil {
+		return Config{}, err
+	}
+	flags.Visit(func(f *pflag.Flag) {
+		// Flags without a key, such as --config, are not options
+		keys := f.Annotations[configKeyAnnotation]
+		if len(keys) == 0 {
+			return
+		}
+		path := strings.Split(keys[0], ".")
+		if value, ok := lookupOption(explicit, path); ok {
+			setOption(options, path, value)
+		}
+	})
+
+	var config Config
+	if data, err = json.Marshal(options); err == nil {
+		err = json.Unmarshal(data, &config)
+	}
+	if err != nil {
+		return Config{}, fmt.Errorf("invalid configuration in %s: %w", file, err)
+	}
+	return config, nil
+}
+
+// decodeJSONNumbers decodes data keeping numbers as written, so large
+// sizes survive the round trip through map[string]any.
+func decodeJSONNumbers(data []byte, v any) error {
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	return d.Decode(v)
+}
+
+// lookupOption returns the value at a path of nested options.
+func lookupOption(options map[string]any, path []string) (any, bool) {
+	value, ok := options[path[0]]
+	if !ok || len(path) == 1 {
+		return value, ok
+	}
+	nested, ok := value.(map[string]any)
+	if !ok {
+		return nil, false
+	}
+	return lookupOption(nested, path[1:])
+}
+
+// setOption sets the value at a path of nested options, creating missing
+// levels.
+func setOption(options map[string]any, path []string, value any) {
+	if len(path) == 1 {
+		options[path[0]] = value
+		return
+	}
+	nested, ok := options[path[0]].(map[string]any)
+	if !ok {
+		nested = make(map[string]any)
+		options[path[0]] = nested
+	}
+	setOption(nested, path[1:], value)
+}
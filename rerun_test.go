@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+func TestLoadRunOptions(t *testing.T) {
+	recorded := defaultConfig(t)
+	recorded.SourceDir = "recorded-source"
+	recorded.ExpectOwner = OwnerExpectation{UID: 0, GID: 0, Uname: "root", Gname: "root"}
+	recorded.CommandLimits.Timeout = time.Minute
+	recorded.ArchiveLimits.MaxTotalSize = 1 << 40
+	var result ComparisonResult
+	result.Metadata.Config = recorded
+	data, err := json.Marshal(result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(t.TempDir(), "result.json")
+	if err := os.WriteFile(file, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var config Config
+	root := newRootCommand(&config)
+	rerun, _, err := root.Find([]string{"rerun"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = rerun.ParseFlags([]string{"--expect-uid", "1000", "--command-timeout", "5s", "--archive-max-ratio", "50", "-o", "again.json"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := viper.Unmarshal(&config); err != nil {
+		t.Fatal(err)
+	}
+
+	options, err := loadRunOptions(file, config, rerun.Flags())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := recorded
+	want.ExpectOwner.UID = 1000
+	want.CommandLimits.Timeout = 5 * time.Second
+	want.ArchiveLimits.MaxRatio = 50
+	want.OutputFile = []string{"again.json"}
+	if !reflect.DeepEqual(options, want) {
+		t.Errorf("options =\n%+v\nwant\n%+v", options, want)
+	}
+}
@@ -0,0 +1,356 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ResolvedTarget is a target made available for comparison: a directory,
+// an archive or a checksum manifest.
+type ResolvedTarget struct {
+	Location     string // shown in the report
+	Dir          string // directory compared, e.g. a clone; its git metadata is recorded
+	Archive      string // zip or tarball compared
+	Manifest     string // checksum manifest compared
+	Verification *ArchiveVerification
+
+	cleanup []func()
+}
+
+// OnClose registers fn to remove what resolving the target left behind,
+// such as a clone or a download, once the comparison is done.
+func (t *ResolvedTarget) OnClose(fn func()) {
+	t.cleanup = append(t.cleanup, fn)
+}
+
+func (t *ResolvedTarget) close() {
+	for i := len(t.cleanup) - 1; i >= 0; i-- {
+		t.cleanup[i]()
+	}
+	t.cleanup = nil
+}
+
+// TargetResolver makes one kind of target available for comparison, such
+// as a clone of a git URL or a downloaded release archive. New kinds of
+// targets, e.g. an S3 bucket or an OCI artifact, are added by registering
+// a resolver, without changes to runComparison.
+type TargetResolver interface {
+	// Flags names the options selecting the resolver, for messages.
+	Flags() []string
+	// Selected reports whether config asks for this kind of target, by an
+	// option of its own or by the scheme of --target.
+	Selected(config *Config) bool
+	// Resolve makes the target selected in the run's config available in
+	// target.
+	Resolve(run *runState, target *ResolvedTarget) error
+}
+
+// targetResolvers are the registered resolvers, in registration order.
+var targetResolvers []TargetResolver
+
+// RegisterTargetResolver adds a kind of target.
+func RegisterTargetResolver(r TargetResolver) {
+	targetResolvers = append(targetResolvers, r)
+}
+
+func init() {
+	RegisterTargetResolver(gitTargetResolver{})
+	RegisterTargetResolver(pathTargetResolver{})
+	RegisterTargetResolver(zipTargetResolver{})
+	RegisterTargetResolver(moduleTargetResolver{})
+	RegisterTargetResolver(releaseTargetResolver{})
+	RegisterTargetResolver(manifestTargetResolver{})
+}
+
+// targetScheme returns the scheme of a --target given as a URL, such as
+// "s3" for s3://bucket/prefix, or "" for the owner/repo shorthand.
+func targetScheme(target string) string {
+	scheme, _, found := strings.Cut(target, "://")
+	if !found {
+		return ""
+	}
+	return strings.ToLower(scheme)
+}
+
+// selectTargetResolver returns the resolver of the one target config
+// selects.
+func selectTargetResolver(config *Config) (TargetResolver, error) {
+	var selected []TargetResolver
+	var all, conflicting []string
+	for _, r := range targetResolvers {
+		all = append(all, r.Flags()...)
+		if r.Selected(config) {
+			selected = append(selected, r)
+			conflicting = append(conflicting, r.Flags()...)
+		}
+	}
+	switch {
+	case len(selected) == 0 && targetScheme(config.Target) != "":
+		return nil, fmt.Errorf("unsupported target %q: no target resolver handles %s:// URLs", config.Target, targetScheme(config.Target))
+	case len(selected) == 0:
+		return nil, fmt.Errorf("one of %s must be specified", joinOptions(all))
+	case len(selected) > 1:
+		return nil, fmt.Errorf("only one of %s should be specified", joinOptions(conflicting))
+	}
+
+	r := selected[0]
+	if _, ok := r.(gitTargetResolver); config.Locked && !ok {
+		return nil, errors.New("--locked can only be used with --target-url")
+	}
+	return r, nil
+}
+
+// resolveTarget resolves the one target the run's config selects.
+func (run *runState) resolveTarget(target *ResolvedTarget) error {
+	config := run.config
+	r, err := selectTargetResolver(config)
+	if err != nil {
+		return err
+	}
+	if err := r.Resolve(run, target); err != nil {
+		return err
+	}
+	if config.SourceZip != "" && target.Archive == "" {
+		return fmt.Errorf("--source-zip can only be compared with an archive target, not %s", strings.Join(r.Flags(), " or "))
+	}
+	return nil
+}
+
+// joinOptions lists options as "a, b, or c".
+func joinOptions(options []string) string {
+	if len(options) <= 2 {
+		return strings.Join(options, " or ")
+	}
+	return strings.Join(options[:len(options)-1], ", ") + ", or " + options[len(options)-1]
+}
+
+// defaultTempDir sets the directory downloads and clones are written to,
+// unless one is configured.
+func defaultTempDir(config *Config) {
+	if config.TempDir == "" {
+		config.TempDir = "gitparator_temp"
+	}
+}
+
+// warnIgnoredRefs warns that refs only apply to git targets.
+func (run *runState) warnIgnoredRefs(flag string) {
+	if config := run.config; config.Branch != "" || config.Tag != "" || config.TagPattern != "" {
+		fmt.Fprintf(run.out, "Warning: --branch and --tag options are ignored when %s is specified.\n", flag)
+	}
+}
+
+// gitTargetResolver clones a git repository given by URL or as the
+// owner/repo[@ref] shorthand for GitHub.
+type gitTargetResolver struct{}
+
+func (gitTargetResolver) Flags() []string { return []string{"--target", "--target-url"} }
+
+func (gitTargetResolver) Selected(config *Config) bool {
+	return config.TargetURL != "" || (config.Target != "" && targetScheme(config.Target) == "")
+}
+
+func (gitTargetResolver) Resolve(run *runState, target *ResolvedTarget) error {
+	config := run.config
+	if config.Target != "" {
+		if err := expandTargetShorthand(config); err != nil {
+			return err
+		}
+	}
+	defaultTempDir(config)
+	var lock TargetLock
+	var err error
+	if config.Locked {
+		if lock, err = readTargetLock(config.LockFile, config); err != nil {
+			return err
+		}
+		// Clone the ref the lock was resolved from instead of resolving it again
+		config.Branch, config.Tag, config.TagPattern = lock.Branch, lock.Tag, ""
+	}
+	if config.TagPattern == "" && isTagPattern(config.Tag) {
+		config.TagPattern = config.Tag
+	}
+	if config.TagPattern != "" && config.Branch == "" {
+		tag, err := resolveTagPattern(config.TargetURL, config.TagPattern, config.IgnoreCase)
+		if err != nil {
+			return fmt.Errorf("error resolving tag pattern: %w", err)
+		}
+		fmt.Fprintf(run.out, "Resolved tag pattern '%s' to %s\n", config.TagPattern, tag)
+		config.Tag = tag
+	}
+	targetDir := config.TempDir
+	if err := preCloneSpaceCheck(config, run.out); err != nil {
+		return fmt.Errorf("error checking disk space: %w", err)
+	}
+	stopClone := run.timings.Track("clone")
+	if err := prepareTarget(config, targetDir, run.out, run.progress.Writer()); err != nil {
+		return fmt.Errorf("error cloning target repository: %w", err)
+	}
+	stopClone()
+	if !config.ReuseClone {
+		target.OnClose(func() { os.RemoveAll(targetDir) })
+	}
+	if config.Locked {
+		if err := checkoutLocked(targetDir, lock, config, run.progress.Writer()); err != nil {
+			return err
+		}
+		fmt.Fprintf(run.out, "Using locked target commit %s\n", lock.Commit)
+	}
+	if err := checkSubdir(targetDir, config.TargetSubdir, "target"); err != nil {
+		return err
+	}
+	target.Location, target.Dir = config.TargetURL, targetDir
+	return nil
+}
+
+// pathTargetResolver compares with a local directory.
+type pathTargetResolver struct{}
+
+func (pathTargetResolver) Flags() []string { return []string{"--target-path"} }
+
+func (pathTargetResolver) Selected(config *Config) bool { return config.TargetPath != "" }
+
+func (pathTargetResolver) Resolve(run *runState, target *ResolvedTarget) error {
+	config := run.config
+	run.warnIgnoredRefs("--target-path")
+	if _, err := os.Stat(config.TargetPath); os.IsNotExist(err) {
+		return fmt.Errorf("target path '%s' does not exist", config.TargetPath)
+	}
+	if err := checkSubdir(config.TargetPath, config.TargetSubdir, "target"); err != nil {
+		return err
+	}
+	target.Location, target.Dir = config.TargetPath, config.TargetPath
+	return nil
+}
+
+// zipTargetResolver compares with a local or remote zip or tarball.
+type zipTargetResolver struct{}
+
+func (zipTargetResolver) Flags() []string { return []string{"--target-zip"} }
+
+func (zipTargetResolver) Selected(config *Config) bool { return config.TargetZip != "" }
+
+func (zipTargetResolver) Resolve(run *runState, target *ResolvedTarget) error {
+	config := run.config
+	run.warnIgnoredRefs("--target-zip")
+
+	// A remote archive is downloaded and then compared like a local one
+	zipPath := config.TargetZip
+	if isRemoteArchive(zipPath) {
+		defaultTempDir(config)
+		stopDownload := run.timings.Track("download")
+		var err error
+		zipPath, err = run.downloadTargetZip(config.TargetZip, config.TempDir)
+		if err == nil && config.VerifySidecars {
+			for _, path := range run.downloadSidecars(config.TargetZip, zipPath, nil) {
+				target.OnClose(func() { os.Remove(path) })
+			}
+		}
+		stopDownload()
+		if err != nil {
+			return fmt.Errorf("error downloading target zip: %w", err)
+		}
+		target.OnClose(func() { os.Remove(zipPath) })
+	} else if _, err := os.Stat(zipPath); os.IsNotExist(err) {
+		return fmt.Errorf("target zip file '%s' does not exist", zipPath)
+	}
+	if err := verifyTargetArchive(zipPath, config, target); err != nil {
+		return err
+	}
+	target.Location, target.Archive = config.TargetZip, zipPath
+	return nil
+}
+
+// verifyTargetArchive checks a downloaded or local archive against
+// target_zip_sha256 and, with verify_sidecars, its sidecar files.
+func verifyTargetArchive(zipPath string, config *Config, target *ResolvedTarget) error {
+	if config.TargetZipSHA256 != "" {
+		if err := verifySHA256(zipPath, config.TargetZipSHA256); err != nil {
+			return err
+		}
+	}
+	if config.VerifySidecars {
+		verification, err := verifySidecars(zipPath, config.SignatureKeyring)
+		if err != nil {
+			return fmt.Errorf("error verifying target archive: %w", err)
+		}
+		target.Verification = verification
+	}
+	return nil
+}
+
+// moduleTargetResolver downloads a Go module zip from the module proxy.
+// Module zips are verified by the module proxy, not by sidecars.
+type moduleTargetResolver struct{}
+
+func (moduleTargetResolver) Flags() []string { return []string{"--target-module"} }
+
+func (moduleTargetResolver) Selected(config *Config) bool { return config.TargetModule != "" }
+
+func (moduleTargetResolver) Resolve(run *runState, target *ResolvedTarget) error {
+	config := run.config
+	defaultTempDir(config)
+	stopDownload := run.timings.Track("download")
+	zipPath, resolved, err := run.downloadModuleZip(config.TargetModule, config.TempDir)
+	stopDownload()
+	if err != nil {
+		return fmt.Errorf("error downloading module: %w", err)
+	}
+	target.OnClose(func() { os.Remove(zipPath) })
+	if config.TargetZipSHA256 != "" {
+		if err := verifySHA256(zipPath, config.TargetZipSHA256); err != nil {
+			return err
+		}
+	}
+	// Module zip entries are all below "path@version/"
+	config.ZipStripComponents = moduleZipComponents(resolved)
+	target.Location, target.Archive = resolved, zipPath
+	return nil
+}
+
+// releaseTargetResolver downloads the archive of a GitHub or GitLab
+// release.
+type releaseTargetResolver struct{}
+
+func (releaseTargetResolver) Flags() []string { return []string{"--target-release"} }
+
+func (releaseTargetResolver) Selected(config *Config) bool { return config.TargetRelease != "" }
+
+func (releaseTargetResolver) Resolve(run *runState, target *ResolvedTarget) error {
+	config := run.config
+	defaultTempDir(config)
+	stopDownload := run.timings.Track("download")
+	zipPath, sidecars, err := run.downloadRelease()
+	stopDownload()
+	if err != nil {
+		return fmt.Errorf("error downloading release: %w", err)
+	}
+	// Only the downloads are removed, as the temp dir may be shared
+	for _, path := range append(sidecars, zipPath) {
+		target.OnClose(func() { os.Remove(path) })
+	}
+	run.warnIgnoredRefs("--target-release")
+	if err := verifyTargetArchive(zipPath, config, target); err != nil {
+		return err
+	}
+	target.Location, target.Archive = zipPath, zipPath
+	return nil
+}
+
+// manifestTargetResolver compares with the checksums recorded by the
+// manifest command.
+type manifestTargetResolver struct{}
+
+func (manifestTargetResolver) Flags() []string { return []string{"--target-manifest"} }
+
+func (manifestTargetResolver) Selected(config *Config) bool { return config.TargetManifest != "" }
+
+func (manifestTargetResolver) Resolve(run *runState, target *ResolvedTarget) error {
+	config := run.config
+	if config.SourceZip != "" {
+		return errors.New("--target-manifest can only be compared with a source directory")
+	}
+	target.Location, target.Manifest = config.TargetManifest, config.TargetManifest
+	return nil
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"archive/zip"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// runState holds the state of one comparison run: its settings, compiled
+// rules, caches and counters. Several runs may share a process, as in
+// --stdio mode, so none of it is kept in package variables.
+type runState struct {
+	config        *Config
+	out           io.Writer // informational messages
+	timings       *runTimings
+	stats         RunStats
+	progress      *progressReporter
+	cache         *comparisonCache // or nil if caching is disabled
+	owned         *codeOwners      // or nil to compare all paths
+	normalizers   []normalizer
+	redactors     []*regexp.Regexp
+	substitutions *strings.Replacer // or nil if no template tokens are configured
+	formatted     map[formatterKey][]byte
+	tarballs      map[string]*tarball    // indexes of the tarballs read, by path
+	archives      map[string]*zip.Reader // archives held in memory, by the name they are compared under
+	target        ResolvedTarget
+}
+
+// startRun starts a run of config, compiling its rules. Informational
+// messages are written to out. The run must be closed when its result has
+// been reported.
+func startRun(config *Config, out io.Writer) (*runState, error) {
+	run := &runState{
+		config:    config,
+		out:       out,
+		timings:   newRunTimings(),
+		progress:  newProgressReporter(config.Progress),
+		formatted: make(map[formatterKey][]byte),
+		tarballs:  make(map[string]*tarball),
+		archives:  make(map[string]*zip.Reader),
+	}
+
+	var err error
+	if run.normalizers, err = compileNormalizeRules(config.Normalize); err != nil {
+		return nil, err
+	}
+	if run.redactors, err = compileRedactPatterns(config.RedactPatterns); err != nil {
+		return nil, err
+	}
+	if run.substitutions, err = compileSubstitutions(config.SubstituteTokens); err != nil {
+		return nil, err
+	}
+	if err := validateCompareStrategies(config.CompareStrategies); err != nil {
+		return nil, err
+	}
+	if err := validateFormatterRules(config.NormalizeCmd); err != nil {
+		return nil, err
+	}
+	if err := validateLineEndingRules(config.LineEndings); err != nil {
+		return nil, err
+	}
+	if err := validateTheme(config.Theme, config.ThemeVariables); err != nil {
+		return nil, err
+	}
+	if _, err := reportFormat("", config.Format); err != nil {
+		return nil, err
+	}
+	if err := validateOutputFiles(config.OutputFile); err != nil {
+		return nil, err
+	}
+	if err := validatePlugins(config.Plugins); err != nil {
+		return nil, err
+	}
+	return run, nil
+}
+
+// close removes what the run left behind: the spool files of the tarballs
+// read and the clones and downloads of the target. The files of its result
+// cannot be read afterwards.
+func (run *runState) close() {
+	for _, t := range run.tarballs {
+		t.close()
+	}
+	run.tarballs = make(map[string]*tarball)
+	run.target.close()
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// CommandLimits constrains the external helpers gitparator runs, such as
+// normalize_cmd formatters, so a misbehaving helper can neither hang nor
+// bloat a comparison run.
+type CommandLimits struct {
+	Timeout   time.Duration `mapstructure:"timeout" json:"timeout"`       // per invocation; 0 for no limit
+	MaxOutput int64         `mapstructure:"max_output" json:"max_output"` // bytes of stdout; 0 for no limit
+	Env       []string      `mapstructure:"env" json:"env"`               // extra variables passed through
+}
+
+// baseCommandEnv lists the variables helpers always receive. Everything
+// else, such as tokens and credentials, is scrubbed unless listed in
+// CommandLimits.Env.
+var baseCommandEnv = []string{"PATH", "HOME", "TMPDIR", "TEMP", "TMP", "LANG", "LC_ALL", "LC_CTYPE", "SYSTEMROOT"}
+
+// maxCommandStderr caps the stderr kept for warnings.
+const maxCommandStderr = 4096
+
+var errOutputLimit = errors.New("output limit exceeded")
+
+// limitedBuffer refuses writes beyond limit bytes (when limit > 0) and
+// then kills the helper through stop. It wraps rather than embeds its
+// buffer, since the embedded ReadFrom would bypass Write.
+type limitedBuffer struct {
+	buf      bytes.Buffer
+	limit    int64
+	stop     context.CancelFunc
+	exceeded bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.limit > 0 && int64(b.buf.Len()+len(p)) > b.limit {
+		b.exceeded = true
+		b.stop()
+		return 0, errOutputLimit
+	}
+	return b.buf.Write(p)
+}
+
+// truncatedBuffer keeps the first max bytes written to it and silently
+// drops the rest.
+type truncatedBuffer struct {
+	buf bytes.Buffer
+	max int
+}
+
+func (b *truncatedBuffer) Write(p []byte) (int, error) {
+	if room := b.max - b.buf.Len(); room > 0 {
+		b.buf.Write(p[:min(room, len(p))])
+	}
+	return len(p), nil
+}
+
+func commandEnv(extra []string) []string {
+	var env []string
+	for _, name := range append(append([]string{}, baseCommandEnv...), extra...) {
+		if value, ok := os.LookupEnv(name); ok {
+			env = append(env, name+"="+value)
+		}
+	}
+	return env
+}
+
+// runLimited runs command (a program and its arguments, without a shell)
+// with stdin as its input, within limits, and returns its stdout.
+func runLimited(command string, stdin []byte, limits CommandLimits) ([]byte, error) {
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return nil, fmt.Errorf("no command given")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if limits.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, limits.Timeout)
+	}
+	defer cancel()
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Env = commandEnv(limits.Env)
+	cmd.Stdin = bytes.NewReader(stdin)
+	stdout := &limitedBuffer{limit: limits.MaxOutput, stop: cancel}
+	stderr := &truncatedBuffer{max: maxCommandStderr}
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+	// Don't wait forever for grandchildren that inherited the pipes
+	cmd.WaitDelay = time.Second
+
+	err := cmd.Run()
+	switch {
+	case stdout.exceeded:
+		return nil, fmt.Errorf("output exceeded %s", formatBytes(uint64(limits.MaxOutput)))
+	case ctx.Err() == context.DeadlineExceeded:
+		return nil, fmt.Errorf("timed out after %v", limits.Timeout)
+	case err != nil:
+		if msg := strings.TrimSpace(stderr.buf.String()); msg != "" {
+			return nil, fmt.Errorf("%w: %s", err, msg)
+		}
+		return nil, err
+	}
+	return stdout.buf.Bytes(), nil
+}
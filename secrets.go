@@ -0,0 +1,108 @@
+package main
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// secretRule is a pattern for text that looks like a credential.
+type secretRule struct {
+	Name    string
+	Pattern *regexp.Regexp
+}
+
+var secretRules = []secretRule{
+	{"AWS access key ID", regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{"AWS secret access key", regexp.MustCompile(`(?i)aws_?secret_?access_?key\s*[:=]\s*["']?[A-Za-z0-9/+=]{40}\b`)},
+	{"Private key", regexp.MustCompile(`-----BEGIN (?:[A-Z0-9]+ )*PRIVATE KEY( BLOCK)?-----`)},
+	{"GitHub token", regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36}|github_pat_[A-Za-z0-9_]{82})\b`)},
+	{"GitLab token", regexp.MustCompile(`\bglpat-[A-Za-z0-9_-]{20}\b`)},
+	{"Slack token", regexp.MustCompile(`\bxox[abposr]-[A-Za-z0-9-]{10,}\b`)},
+}
+
+// SecretFinding is a changed or added line that matches a secret rule. The
+// matched text itself is masked so the report does not spread the secret.
+type SecretFinding struct {
+	Path   string `json:"path"`
+	Side   string `json:"side"` // "source" or "target"
+	Line   int    `json:"line"`
+	Rule   string `json:"rule"`
+	Masked string `json:"masked"`
+}
+
+// scanSecretsInChanges scans only the lines that differ between the two
+// sides' contents.
+func scanSecretsInChanges(path string, content1, content2 []byte) []SecretFinding {
+	dmp := diffmatchpatch.New()
+	chars1, chars2, linePatches := dmp.DiffLinesToChars(string(content1), string(content2))
+	lines := dmp.DiffCharsToLines(dmp.DiffMain(chars1, chars2, false), linePatches)
+
+	var findings []SecretFinding
+	lineNum1, lineNum2 := 1, 1
+	for _, diff := range lines {
+		n := strings.Count(diff.Text, "\n")
+		if !strings.HasSuffix(diff.Text, "\n") {
+			n++
+		}
+		switch diff.Type {
+		case diffmatchpatch.DiffDelete:
+			findings = append(findings, scanSecretsInText(path, "source", lineNum1, diff.Text)...)
+			lineNum1 += n
+		case diffmatchpatch.DiffInsert:
+			findings = append(findings, scanSecretsInText(path, "target", lineNum2, diff.Text)...)
+			lineNum2 += n
+		case diffmatchpatch.DiffEqual:
+			lineNum1 += n
+			lineNum2 += n
+		}
+	}
+	return findings
+}
+
+// scanSecretsInFile scans a file that exists on one side only.
+func scanSecretsInFile(path, side string, file sourceFile) []SecretFinding {
+	content, err := readFileContent(file)
+	if err != nil {
+		return nil
+	}
+	return scanSecretsInText(path, side, 1, string(content))
+}
+
+// scanSecretsInText scans text whose first line is firstLine.
+func scanSecretsInText(path, side string, firstLine int, text string) []SecretFinding {
+	var findings []SecretFinding
+	for i, line := range strings.Split(text, "\n") {
+		for _, rule := range secretRules {
+			if match := rule.Pattern.FindString(line); match != "" {
+				findings = append(findings, SecretFinding{path, side, firstLine + i, rule.Name, maskSecret(match)})
+			}
+		}
+	}
+	return findings
+}
+
+// maskSecret keeps just enough of a match to recognize it.
+func maskSecret(s string) string {
+	if strings.HasPrefix(s, "-----") {
+		return s // key headers are not secret themselves
+	}
+	if len(s) <= 8 {
+		return strings.Repeat("*", len(s))
+	}
+	return s[:4] + strings.Repeat("*", len(s)-8) + s[len(s)-4:]
+}
+
+func sortSecretFindings(findings []SecretFinding) {
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].Path != findings[j].Path {
+			return findings[i].Path < findings[j].Path
+		}
+		if findings[i].Side != findings[j].Side {
+			return findings[i].Side < findings[j].Side
+		}
+		return findings[i].Line < findings[j].Line
+	})
+}
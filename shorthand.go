@@ -0,0 +1,82 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	gitconfig "github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/storage/memory"
+)
+
+// shorthandPattern matches owner/repo, optionally followed by @ref.
+var shorthandPattern = regexp.MustCompile(`^([A-Za-z0-9][A-Za-z0-9-]*)/([A-Za-z0-9._-]+?)(?:\.git)?(?:@(.+))?$`)
+
+// expandTargetShorthand turns a --target given as owner/repo[@ref] into the
+// GitHub clone URL and the branch or tag named by ref. Without a ref the
+// default branch is compared. Refs containing wildcards are treated as tag
+// patterns.
+func expandTargetShorthand(config *Config) error {
+	if config.TargetURL != "" || config.TargetPath != "" || config.TargetZip != "" || config.TargetRelease != "" {
+		return errors.New("--target cannot be combined with --target-url, --target-path, --target-zip, or --target-release")
+	}
+	m := shorthandPattern.FindStringSubmatch(config.Target)
+	if m == nil {
+		return fmt.Errorf("invalid target %q (expected owner/repo or owner/repo@ref)", config.Target)
+	}
+	owner, repo, ref := m[1], m[2], m[3]
+	config.TargetURL = fmt.Sprintf("https://github.com/%s/%s.git", owner, repo)
+	if ref == "" {
+		return nil
+	}
+	if config.Branch != "" || config.Tag != "" || config.TagPattern != "" {
+		return errors.New("--branch, --tag and --tag-pattern cannot be combined with a --target ref")
+	}
+
+	if isTagPattern(ref) {
+		config.TagPattern = ref
+		return nil
+	}
+	refName, err := resolveRemoteRef(config.TargetURL, ref)
+	if err != nil {
+		return err
+	}
+	if refName.IsTag() {
+		config.Tag = ref
+	} else {
+		config.Branch = ref
+	}
+	return nil
+}
+
+// resolveRemoteRef reports whether ref names a tag or a branch of the
+// remote at url. Tags win when both exist, as they do for git checkout.
+func resolveRemoteRef(url, ref string) (plumbing.ReferenceName, error) {
+	remote := git.NewRemote(memory.NewStorage(), &gitconfig.RemoteConfig{
+		Name: "origin",
+		URLs: []string{url},
+	})
+
+	refs, err := remote.List(&git.ListOptions{})
+	if err != nil {
+		return "", fmt.Errorf("error listing remote refs: %w", err)
+	}
+
+	tag, branch := plumbing.NewTagReferenceName(ref), plumbing.NewBranchReferenceName(ref)
+	found := plumbing.ReferenceName("")
+	for _, r := range refs {
+		switch r.Name() {
+		case tag:
+			return tag, nil
+		case branch:
+			found = branch
+		}
+	}
+	if found == "" {
+		return "", fmt.Errorf("%s has no branch or tag named '%s'", strings.TrimSuffix(url, ".git"), ref)
+	}
+	return found, nil
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+)
+
+// Extensions of the files shipped next to release archives to verify them.
+const (
+	checksumSidecar  = ".sha256"
+	signatureSidecar = ".asc"
+)
+
+// ArchiveVerification records how a target archive was checked against
+// the checksum and signature files shipped next to it. Checksum is
+// "verified" or "missing"; Signature is "verified", "unchecked" (no
+// signature_keyring given) or "missing".
+type ArchiveVerification struct {
+	Archive   string `json:"archive"`
+	Checksum  string `json:"checksum"`
+	Signature string `json:"signature"`
+	Signer    string `json:"signer,omitempty"` // key ID and primary identity
+}
+
+// downloadSidecars downloads the sidecar files of the archive at endpoint
+// next to archivePath, where verifySidecars looks for them. Sidecars that
+// cannot be downloaded are taken to be missing. It returns the paths of
+// the downloaded files.
+func (run *runState) downloadSidecars(endpoint, archivePath string, headers map[string]string) []string {
+	var paths []string
+	for _, ext := range []string{checksumSidecar, signatureSidecar} {
+		path, err := run.downloadFile(endpoint+ext, filepath.Dir(archivePath), filepath.Base(archivePath)+ext, headers)
+		if err == nil {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// verifySidecars checks archivePath against the .sha256 and .asc files
+// next to it. A mismatching checksum or a bad signature is an error, as is
+// a missing signature when keyring names the trusted keys.
+func verifySidecars(archivePath, keyring string) (*ArchiveVerification, error) {
+	v := &ArchiveVerification{Archive: filepath.Base(archivePath), Checksum: "missing", Signature: "missing"}
+
+	checksumFile := archivePath + checksumSidecar
+	if _, err := os.Stat(checksumFile); err == nil {
+		expected, err := readChecksumSidecar(checksumFile, filepath.Base(archivePath))
+		if err != nil {
+			return v, err
+		}
+		if err := verifySHA256(archivePath, expected); err != nil {
+			return v, fmt.Errorf("checksum file %s: %w", checksumFile, err)
+		}
+		v.Checksum = "verified"
+	}
+
+	signatureFile := archivePath + signatureSidecar
+	if _, err := os.Stat(signatureFile); err != nil {
+		if keyring != "" {
+			return v, fmt.Errorf("signature_keyring is set, but %s has no signature file %s", archivePath, signatureFile)
+		}
+		return v, nil
+	}
+	if keyring == "" {
+		v.Signature = "unchecked"
+		return v, nil
+	}
+	signer, err := checkSignature(archivePath, signatureFile, keyring)
+	if err != nil {
+		return v, err
+	}
+	v.Signature, v.Signer = "verified", signer
+	return v, nil
+}
+
+// readChecksumSidecar returns the digest listed for name in a checksum
+// file, which holds either a bare digest or lines of "digest  name" as
+// written by sha256sum. A single digest is taken to be that of the
+// archive, whatever its name, since downloads may be saved under another
+// name.
+func readChecksumSidecar(file, name string) (string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	var digests []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) == 2 && filepath.Base(strings.TrimPrefix(fields[1], "*")) == name {
+			return fields[0], nil
+		}
+		digests = append(digests, fields[0])
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	if len(digests) == 1 {
+		return digests[0], nil
+	}
+	return "", fmt.Errorf("checksum file %s lists no digest for %s", file, name)
+}
+
+// checkSignature verifies the armored detached signature of file against
+// the armored public keys in keyring and returns the signer.
+func checkSignature(file, signatureFile, keyring string) (string, error) {
+	kf, err := os.Open(keyring)
+	if err != nil {
+		return "", fmt.Errorf("error reading signature keyring: %w", err)
+	}
+	defer kf.Close()
+	keys, err := openpgp.ReadArmoredKeyRing(kf)
+	if err != nil {
+		return "", fmt.Errorf("error reading signature keyring %s: %w", keyring, err)
+	}
+
+	signed, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer signed.Close()
+	signature, err := os.Open(signatureFile)
+	if err != nil {
+		return "", err
+	}
+	defer signature.Close()
+
+	entity, err := openpgp.CheckArmoredDetachedSignature(keys, signed, signature, nil)
+	if err != nil {
+		return "", fmt.Errorf("signature %s does not verify %s: %w", signatureFile, file, err)
+	}
+	if entity == nil {
+		return "", errors.New("signature verified by an unknown key")
+	}
+	signer := entity.PrimaryKey.KeyIdString()
+	if id := entity.PrimaryIdentity(); id != nil {
+		signer += " " + id.Name
+	}
+	return signer, nil
+}
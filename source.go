@@ -0,0 +1,202 @@
+package main
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// treeSource is a tree of files compared on one side: a directory, a zip
+// archive, a tarball or a git tree. Files are named by slash-separated
+// paths from the root of the tree, as in any fs.FS, so supporting a new
+// kind of target only takes a new implementation.
+type treeSource interface {
+	fs.FS
+	// String names the directory or archive the tree is read from.
+	String() string
+}
+
+// sourceFile is a file of a tree source.
+type sourceFile struct {
+	tree treeSource
+	name string
+}
+
+// exists reports whether f names a file, rather than the missing side of
+// a one-sided pair.
+func (f sourceFile) exists() bool {
+	return f.tree != nil
+}
+
+func (f sourceFile) String() string {
+	if !f.exists() {
+		return ""
+	}
+	if p, ok := f.diskPath(); ok {
+		return p
+	}
+	return toSlash(f.tree.String()) + ":" + f.name
+}
+
+// diskPath returns the path of f on disk, for files of directory sources
+// that external tools can open directly.
+func (f sourceFile) diskPath() (string, bool) {
+	dir, ok := f.tree.(*dirSource)
+	if !ok {
+		return "", false
+	}
+	return filepath.Join(dir.dir, filepath.FromSlash(f.name)), true
+}
+
+// dirSource is a directory on disk.
+type dirSource struct {
+	fs.FS
+	dir string
+}
+
+func newDirSource(dir string) *dirSource {
+	return &dirSource{FS: os.DirFS(dir), dir: dir}
+}
+
+func (s *dirSource) String() string { return s.dir }
+
+// localFile returns a file on disk, given by any path, as a source file.
+func localFile(file string) sourceFile {
+	return sourceFile{newDirSource(filepath.Dir(file)), filepath.Base(file)}
+}
+
+// zipSource is a zip archive, on disk or held in memory. Archives on disk
+// are opened for each file read, so no handle outlives the read.
+type zipSource struct {
+	archive string
+	memory  *zip.Reader // or nil for archives on disk
+}
+
+func (s *zipSource) String() string { return s.archive }
+
+func (s *zipSource) Open(name string) (fs.File, error) {
+	if s.memory != nil {
+		f, err := s.memory.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		return &zipEntryFile{File: f, archive: nopCloser{}}, nil
+	}
+	r, closer, err := openZip(s.archive)
+	if err != nil {
+		return nil, err
+	}
+	f, err := r.Open(name)
+	if err != nil {
+		closer.Close()
+		return nil, err
+	}
+	return &zipEntryFile{File: f, archive: closer}, nil
+}
+
+// zipEntryFile closes the archive along with the entry.
+type zipEntryFile struct {
+	fs.File
+	archive io.Closer
+}
+
+func (z *zipEntryFile) Close() error {
+	err := z.File.Close()
+	if cerr := z.archive.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// tarSource is a tar or gzipped tar archive, read through the index built
+// by openTarball since tar archives cannot be read at random.
+type tarSource struct {
+	t *tarball
+}
+
+func (s *tarSource) String() string { return s.t.archive }
+
+func (s *tarSource) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	i, ok := s.t.files[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return s.t.open(s.t.entries[i])
+}
+
+// tarEntryFile reads the content of a tarball entry from the archive or
+// from the spool file.
+type tarEntryFile struct {
+	*io.SectionReader
+	info    fs.FileInfo
+	archive *os.File // opened for this read, or nil
+}
+
+func (t *tarEntryFile) Stat() (fs.FileInfo, error) { return t.info, nil }
+
+func (t *tarEntryFile) Close() error {
+	if t.archive == nil {
+		return nil
+	}
+	return t.archive.Close()
+}
+
+// gitTreeSource is a directory of a tree in a git object database, such
+// as the base revision of a three-way comparison.
+type gitTreeSource struct {
+	tree   *object.Tree
+	prefix string // directory in the tree, or ""
+	name   string
+}
+
+func (s *gitTreeSource) String() string { return s.name }
+
+func (s *gitTreeSource) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	f, err := s.tree.File(joinSlash(s.prefix, name))
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	r, err := f.Reader()
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s from %s: %w", name, s.name, err)
+	}
+	return &gitTreeFile{ReadCloser: r, file: f}, nil
+}
+
+// gitTreeFile reads a blob of a git tree.
+type gitTreeFile struct {
+	io.ReadCloser
+	file *object.File
+}
+
+func (g *gitTreeFile) Stat() (fs.FileInfo, error) { return gitFileInfo{g.file}, nil }
+
+type gitFileInfo struct {
+	file *object.File
+}
+
+func (i gitFileInfo) Name() string { return path.Base(i.file.Name) }
+func (i gitFileInfo) Size() int64  { return i.file.Size }
+func (i gitFileInfo) Mode() fs.FileMode {
+	mode, err := i.file.Mode.ToOSFileMode()
+	if err != nil {
+		return 0644
+	}
+	return mode
+}
+func (i gitFileInfo) ModTime() time.Time { return time.Time{} }
+func (i gitFileInfo) IsDir() bool        { return false }
+func (i gitFileInfo) Sys() any           { return nil }
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// RunStats counts the work done by a run so throughput can be tracked
+// across CI runs.
+type RunStats struct {
+	FilesScanned    int     `json:"files_scanned"`
+	FilesCompared   int     `json:"files_compared"`
+	BytesCompared   int64   `json:"bytes_compared"`
+	CacheHits       int     `json:"cache_hits"` // pairs whose result came from --cache-file
+	FilesPerSecond  float64 `json:"files_scanned_per_second"`
+	BytesPerSecond  float64 `json:"bytes_compared_per_second"`
+	TotalDurationMS int64   `json:"total_duration_ms"`
+}
+
+// finalize computes the rates from the counters and the phase timings.
+func (s *RunStats) finalize(phases []PhaseTiming, total time.Duration) {
+	s.TotalDurationMS = total.Milliseconds()
+	for _, p := range phases {
+		seconds := p.Duration.Seconds()
+		if seconds <= 0 {
+			continue
+		}
+		switch p.Name {
+		case "scan":
+			s.FilesPerSecond = float64(s.FilesScanned) / seconds
+		case "compare":
+			s.BytesPerSecond = float64(s.BytesCompared) / seconds
+		}
+	}
+}
+
+// printVerboseStats writes per-phase timings and throughput figures.
+func printVerboseStats(w io.Writer, phases []PhaseTiming, s RunStats) {
+	fmt.Fprintln(w, "Timings:")
+	for _, p := range phases {
+		fmt.Fprintf(w, "  %-10s %12s\n", p.Name, p.Duration.Round(time.Microsecond))
+	}
+	fmt.Fprintln(w, "Statistics:")
+	fmt.Fprintf(w, "  files scanned   %d (%.0f files/s)\n", s.FilesScanned, s.FilesPerSecond)
+	fmt.Fprintf(w, "  files compared  %d\n", s.FilesCompared)
+	fmt.Fprintf(w, "  bytes compared  %s (%s/s)\n", formatBytes(uint64(s.BytesCompared)), formatBytes(uint64(s.BytesPerSecond)))
+	if s.CacheHits > 0 {
+		fmt.Fprintf(w, "  cache hits      %d\n", s.CacheHits)
+	}
+}
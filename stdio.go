@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// stdioRequest is one line of input in --stdio mode. Params are decoded
+// according to the method.
+type stdioRequest struct {
+	ID     json.RawMessage `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+}
+
+type stdioResponse struct {
+	ID     json.RawMessage `json:"id"`
+	Result any             `json:"result,omitempty"`
+	Error  *stdioError     `json:"error,omitempty"`
+}
+
+type stdioError struct {
+	Message string `json:"message"`
+}
+
+// diffFileParams selects two files to diff. Diff options not given default
+// to the session configuration.
+type diffFileParams struct {
+	SourceFile string `json:"source_file"`
+	TargetFile string `json:"target_file"`
+}
+
+type diffFileResult struct {
+	Equal bool   `json:"equal"`
+	HTML  string `json:"html,omitempty"`
+}
+
+type explainParams struct {
+	Path string `json:"path"`
+}
+
+// stdioSession serves requests against a base configuration, keeping clones
+// of the repositories it has seen so later requests can reuse them.
+type stdioSession struct {
+	base   Config
+	clones map[string]bool // clone directories created during the session
+}
+
+// runStdio reads line-delimited JSON requests from stdin and writes one
+// response line per request to stdout until stdin is closed. Each request
+// is served by a run of its own, whose messages go to stderr to keep stdout
+// parseable.
+func runStdio(base Config) error {
+	session := &stdioSession{base: base, clones: make(map[string]bool)}
+	defer session.close()
+	return session.serve(os.Stdin, os.Stdout)
+}
+
+func (s *stdioSession) serve(in io.Reader, out io.Writer) error {
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	encoder := json.NewEncoder(out)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+
+		var req stdioRequest
+		var resp stdioResponse
+		if err := json.Unmarshal(line, &req); err != nil {
+			resp.Error = &stdioError{fmt.Sprintf("invalid request: %v", err)}
+		} else {
+			resp.ID = req.ID
+			result, err := s.handle(req)
+			if err != nil {
+				resp.Error = &stdioError{err.Error()}
+			} else {
+				resp.Result = result
+			}
+		}
+		if err := encoder.Encode(resp); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+func (s *stdioSession) handle(req stdioRequest) (any, error) {
+	switch req.Method {
+	case "compare":
+		config, err := s.config(req.Params)
+		if err != nil {
+			return nil, err
+		}
+		run, err := startRun(&config, os.Stderr)
+		if err != nil {
+			return nil, err
+		}
+		defer run.close()
+		return run.runComparison()
+	case "diff-file":
+		config, err := s.config(req.Params)
+		if err != nil {
+			return nil, err
+		}
+		var params diffFileParams
+		if err := decodeParams(req.Params, &params); err != nil {
+			return nil, err
+		}
+		if params.SourceFile == "" || params.TargetFile == "" {
+			return nil, errors.New("diff-file requires source_file and target_file")
+		}
+		run, err := startRun(&config, os.Stderr)
+		if err != nil {
+			return nil, err
+		}
+		defer run.close()
+		source, target := localFile(params.SourceFile), localFile(params.TargetFile)
+		if run.filesAreEqual(source, target) {
+			return diffFileResult{Equal: true}, nil
+		}
+		pair := filePair{params.SourceFile, params.TargetFile, source, target}
+		return diffFileResult{HTML: run.getFileDiff(pair)}, nil
+	case "explain":
+		config, err := s.config(req.Params)
+		if err != nil {
+			return nil, err
+		}
+		var params explainParams
+		if err := decodeParams(req.Params, &params); err != nil {
+			return nil, err
+		}
+		if params.Path == "" {
+			return nil, errors.New("explain requires path")
+		}
+		return explainPath(&config, params.Path)
+	default:
+		return nil, fmt.Errorf("unknown method %q (expected compare, diff-file, or explain)", req.Method)
+	}
+}
+
+// config overlays the request params, which use the config file keys, onto
+// the session configuration. Remote targets get a clone directory of their
+// own that is kept for the rest of the session.
+func (s *stdioSession) config(params json.RawMessage) (Config, error) {
+	config := s.base
+	config.Progress = false
+	config.Difftool = "" // stdin carries requests
+	if err := decodeParams(params, &config); err != nil {
+		return config, err
+	}
+
+	// Expand the shorthand here, so its clone gets a directory of its own
+	if config.Target != "" {
+		if err := expandTargetShorthand(&config); err != nil {
+			return config, err
+		}
+		config.Target = ""
+	}
+	if config.TargetURL != "" {
+		if config.TempDir == "" {
+			config.TempDir = "gitparator_temp"
+		}
+		sum := sha256.Sum256([]byte(config.TargetURL))
+		config.TempDir = filepath.Join(config.TempDir, hex.EncodeToString(sum[:6]))
+		if !config.ReuseClone {
+			s.clones[config.TempDir] = true
+			config.ReuseClone = true
+		}
+	}
+	return config, nil
+}
+
+// close removes the clones created during the session.
+func (s *stdioSession) close() {
+	for dir := range s.clones {
+		os.RemoveAll(dir)
+	}
+}
+
+func decodeParams(params json.RawMessage, v any) error {
+	if len(params) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(params, v); err != nil {
+		return fmt.Errorf("invalid params: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/adnsv/gitparator/testsupport"
+)
+
+func TestStdioSession(t *testing.T) {
+	source := testsupport.Dir(t, testsupport.Files{"app.conf": "password=hunter2\nmode=debug\n"})
+	target := testsupport.Dir(t, testsupport.Files{"app.conf": "password=hunter2\nmode=release\n"})
+	files := fmt.Sprintf(`"source_file":%q,"target_file":%q`, filepath.Join(source, "app.conf"), filepath.Join(target, "app.conf"))
+	requests := strings.Join([]string{
+		`{"id":1,"method":"diff-file","params":{` + files + `,"redact_patterns":["hunter\\d"]}}`,
+		`{"id":2,"method":"diff-file","params":{` + files + `}}`,
+		fmt.Sprintf(`{"id":3,"method":"compare","params":{"source_dir":%q,"target_path":%q}}`, source, target),
+	}, "\n")
+
+	session := &stdioSession{base: defaultConfig(t), clones: make(map[string]bool)}
+	var out strings.Builder
+	if err := session.serve(strings.NewReader(requests), &out); err != nil {
+		t.Fatal(err)
+	}
+
+	// The output is one response line per request
+	type response struct {
+		ID     int         `json:"id"`
+		Result stdioResult `json:"result"`
+		Error  *stdioError `json:"error"`
+	}
+	var responses []response
+	for _, line := range strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n") {
+		var r response
+		if err := json.Unmarshal([]byte(line), &r); err != nil {
+			t.Fatalf("response %q: %v", line, err)
+		}
+		if r.Error != nil {
+			t.Fatalf("request %d: %s", r.ID, r.Error.Message)
+		}
+		responses = append(responses, r)
+	}
+	if len(responses) != 3 {
+		t.Fatalf("got %d responses, want 3", len(responses))
+	}
+
+	// The redact patterns of one request do not apply to the next
+	if html := responses[0].Result.HTML; strings.Contains(html, "hunter2") || !strings.Contains(html, "mode=release") {
+		t.Errorf("diff with redact_patterns:\n%s", html)
+	}
+	if html := responses[1].Result.HTML; !strings.Contains(html, "hunter2") {
+		t.Errorf("diff without redact_patterns:\n%s", html)
+	}
+	if got := responses[2].Result.DifferentFiles; len(got) != 1 || got[0] != "app.conf" {
+		t.Errorf("different files = %v, want [app.conf]", got)
+	}
+}
+
+// stdioResult holds the fields of the diff-file and compare results the
+// test checks.
+type stdioResult struct {
+	HTML           string   `json:"html"`
+	DifferentFiles []string `json:"different_files"`
+}
+
+func TestStdioSessionErrors(t *testing.T) {
+	source := testsupport.Dir(t, sourceFiles)
+	corrupt := filepath.Join(t.TempDir(), "corrupt.zip")
+	if err := os.WriteFile(corrupt, []byte("not a zip archive"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	bomb := testsupport.Zip(t, "", testsupport.Files{"zeros.bin": strings.Repeat("\x00", 4<<20)})
+	requests := strings.Join([]string{
+		fmt.Sprintf(`{"id":1,"method":"compare","params":{"source_dir":%q,"target_zip":%q}}`, source, corrupt),
+		fmt.Sprintf(`{"id":2,"method":"compare","params":{"source_dir":%q,"target_zip":%q,"archive_limits":{"max_ratio":100}}}`, source, bomb),
+		fmt.Sprintf(`{"id":3,"method":"explain","params":{"source_dir":%q,"path":"README.md"}}`, source),
+	}, "\n")
+
+	session := &stdioSession{base: defaultConfig(t), clones: make(map[string]bool)}
+	var out strings.Builder
+	if err := session.serve(strings.NewReader(requests), &out); err != nil {
+		t.Fatal(err)
+	}
+
+	// A failed request is answered with its error and the session goes on
+	var responses []stdioResponse
+	for _, line := range strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n") {
+		var r stdioResponse
+		if err := json.Unmarshal([]byte(line), &r); err != nil {
+			t.Fatalf("response %q: %v", line, err)
+		}
+		responses = append(responses, r)
+	}
+	if len(responses) != 3 {
+		t.Fatalf("got %d responses, want 3:\n%s", len(responses), out.String())
+	}
+	for i, want := range []string{"not a valid zip file", "archive_limits.max_ratio"} {
+		if e := responses[i].Error; e == nil || !strings.Contains(e.Message, want) {
+			t.Errorf("response %s: error = %+v, want %q", responses[i].ID, e, want)
+		}
+	}
+	if responses[2].Error != nil || responses[2].Result == nil {
+		t.Errorf("explain after failed requests: %+v", responses[2])
+	}
+}
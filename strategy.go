@@ -0,0 +1,262 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Comparison strategies selectable per file pattern.
+const (
+	strategyText         = "text"
+	strategyBinaryHash   = "binary-hash"
+	strategyJSONSemantic = "json-semantic"
+	strategyYAMLSemantic = "yaml-semantic"
+	strategyINISemantic  = "ini-semantic"
+	strategyINIKeys      = "ini-keys"
+	strategyIgnoreEOL    = "ignore-eol"
+)
+
+// CompareStrategy selects how files matching Paths are compared. The first
+// matching entry wins; files matching none are compared as text.
+type CompareStrategy struct {
+	Paths    []string `mapstructure:"paths" json:"paths"`
+	Strategy string   `mapstructure:"strategy" json:"strategy"`
+}
+
+func validateCompareStrategies(strategies []CompareStrategy) error {
+	for i, s := range strategies {
+		switch s.Strategy {
+		case strategyText, strategyBinaryHash, strategyJSONSemantic, strategyYAMLSemantic, strategyINISemantic, strategyINIKeys, strategyIgnoreEOL:
+		default:
+			return fmt.Errorf("compare strategy %d: unknown strategy %q (expected text, binary-hash, json-semantic, yaml-semantic, ini-semantic, ini-keys, or ignore-eol)", i+1, s.Strategy)
+		}
+		if len(s.Paths) == 0 {
+			return fmt.Errorf("compare strategy %d: no paths given", i+1)
+		}
+	}
+	return nil
+}
+
+// comparisonStrategy returns the strategy configured for relPath. With
+// semantic comparison enabled, JSON, YAML, INI and .env files not matched by
+// any entry are compared semantically.
+func comparisonStrategy(relPath string, config *Config) string {
+	relPath = toSlash(relPath)
+	for _, s := range config.CompareStrategies {
+		if shouldExclude(relPath, s.Paths) {
+			return s.Strategy
+		}
+	}
+	if config.SemanticCompare {
+		switch strings.ToLower(path.Ext(relPath)) {
+		case ".json":
+			return strategyJSONSemantic
+		case ".yaml", ".yml":
+			return strategyYAMLSemantic
+		case ".ini", ".env":
+			return strategyINISemantic
+		}
+		if base := path.Base(relPath); base == ".env" || strings.HasPrefix(base, ".env.") {
+			return strategyINISemantic
+		}
+	}
+	return strategyText
+}
+
+// isStructuredStrategy reports whether a strategy compares decoded data,
+// so that differences are listed by path rather than by line.
+func isStructuredStrategy(strategy string) bool {
+	switch strategy {
+	case strategyJSONSemantic, strategyYAMLSemantic, strategyINISemantic, strategyINIKeys:
+		return true
+	}
+	return false
+}
+
+// strategyEqual compares a pair that is not byte-identical using a lenient
+// strategy. Text and binary-hash comparisons are byte-exact, so they never
+// match here.
+func strategyEqual(pair filePair, strategy string) bool {
+	if !isStructuredStrategy(strategy) && strategy != strategyIgnoreEOL {
+		return false
+	}
+
+	source, err1 := readFileContent(pair.SourceFile)
+	target, err2 := readFileContent(pair.TargetFile)
+	if err1 != nil || err2 != nil {
+		return false
+	}
+
+	if strategy == strategyIgnoreEOL {
+		return bytes.Equal(normalizeEOL(source), normalizeEOL(target))
+	}
+	a, b, err := parseStructured(source, target, strategy)
+	if err != nil {
+		return false
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// parseStructured decodes both sides of a pair compared with a semantic
+// strategy. YAML maps are converted to string-keyed maps so that all
+// formats produce the same kind of values.
+func parseStructured(source, target []byte, strategy string) (a, b any, err error) {
+	unmarshal := json.Unmarshal
+	switch strategy {
+	case strategyYAMLSemantic:
+		unmarshal = yaml.Unmarshal
+	case strategyINISemantic, strategyINIKeys:
+		unmarshal = func(data []byte, v any) error {
+			m, err := parseKeyValues(data, strategy == strategyINIKeys)
+			*v.(*any) = m
+			return err
+		}
+	}
+	if err := unmarshal(source, &a); err != nil {
+		return nil, nil, err
+	}
+	if err := unmarshal(target, &b); err != nil {
+		return nil, nil, err
+	}
+	return normalizeStructured(a), normalizeStructured(b), nil
+}
+
+func normalizeStructured(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, e := range v {
+			v[k] = normalizeStructured(e)
+		}
+		return v
+	case map[any]any:
+		m := make(map[string]any, len(v))
+		for k, e := range v {
+			m[fmt.Sprint(k)] = normalizeStructured(e)
+		}
+		return m
+	case []any:
+		for i, e := range v {
+			v[i] = normalizeStructured(e)
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+// structuralChange is a value added, removed or changed between two
+// decoded documents, addressed by a JSONPath-like location.
+type structuralChange struct {
+	Path               string
+	Source             any // nil when added on the target side
+	Target             any // nil when removed from the target side
+	InSource, InTarget bool
+}
+
+// structuralDiff lists the differences between two decoded documents.
+func structuralDiff(location string, a, b any) []structuralChange {
+	ma, aIsMap := a.(map[string]any)
+	mb, bIsMap := b.(map[string]any)
+	if aIsMap && bIsMap {
+		keys := make(map[string]bool)
+		for k := range ma {
+			keys[k] = true
+		}
+		for k := range mb {
+			keys[k] = true
+		}
+		sorted := make([]string, 0, len(keys))
+		for k := range keys {
+			sorted = append(sorted, k)
+		}
+		sort.Strings(sorted)
+
+		var changes []structuralChange
+		for _, k := range sorted {
+			va, inA := ma[k]
+			vb, inB := mb[k]
+			child := location + "." + k
+			switch {
+			case !inA:
+				changes = append(changes, structuralChange{Path: child, Target: vb, InTarget: true})
+			case !inB:
+				changes = append(changes, structuralChange{Path: child, Source: va, InSource: true})
+			default:
+				changes = append(changes, structuralDiff(child, va, vb)...)
+			}
+		}
+		return changes
+	}
+
+	la, aIsList := a.([]any)
+	lb, bIsList := b.([]any)
+	if aIsList && bIsList {
+		var changes []structuralChange
+		for i := 0; i < max(len(la), len(lb)); i++ {
+			child := fmt.Sprintf("%s[%d]", location, i)
+			switch {
+			case i >= len(la):
+				changes = append(changes, structuralChange{Path: child, Target: lb[i], InTarget: true})
+			case i >= len(lb):
+				changes = append(changes, structuralChange{Path: child, Source: la[i], InSource: true})
+			default:
+				changes = append(changes, structuralDiff(child, la[i], lb[i])...)
+			}
+		}
+		return changes
+	}
+
+	if reflect.DeepEqual(a, b) {
+		return nil
+	}
+	return []structuralChange{{Path: location, Source: a, Target: b, InSource: true, InTarget: true}}
+}
+
+// getStructuralDiff renders the structural differences of a pair compared
+// with a semantic strategy, using the same markup as line diffs. It returns
+// false when either side does not parse, so a line diff can be used instead.
+func (run *runState) getStructuralDiff(file1, file2 sourceFile, strategy string) (string, bool) {
+	content1, err1 := readFileContent(file1)
+	content2, err2 := readFileContent(file2)
+	if err1 != nil || err2 != nil {
+		return "", false
+	}
+	a, b, err := parseStructured(run.redactContent(content1), run.redactContent(content2), strategy)
+	if err != nil {
+		return "", false
+	}
+
+	changes := structuralDiff("$", a, b)
+	additions, deletions := 0, 0
+	var rows strings.Builder
+	line := func(class, marker, path string, value any) {
+		encoded, _ := json.Marshal(value)
+		fmt.Fprintf(&rows, "<div class=\"diff-line %s\"><span class=\"line-num\"></span><span class=\"diff-marker\">%s</span>%s: %s</div>",
+			class, marker, template.HTMLEscapeString(path), template.HTMLEscapeString(string(encoded)))
+	}
+	for _, c := range changes {
+		if c.InSource {
+			line("diff-deleted", "-", c.Path, c.Source)
+			deletions++
+		}
+		if c.InTarget {
+			line("diff-inserted", "+", c.Path, c.Target)
+			additions++
+		}
+	}
+	return fmt.Sprintf("<div class=\"diff-content structural\" data-additions=\"%d\" data-deletions=\"%d\">%s</div>",
+		additions, deletions, rows.String()), true
+}
+
+func normalizeEOL(content []byte) []byte {
+	content = bytes.ReplaceAll(content, []byte("\r\n"), []byte("\n"))
+	return bytes.ReplaceAll(content, []byte("\r"), []byte("\n"))
+}
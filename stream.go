@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"io"
+)
+
+// compareBufferSize is the size of each of the two buffers used to compare
+// files as streams.
+const compareBufferSize = 64 << 10
+
+// openFileContent opens a file of a tree source for streaming and returns
+// its size.
+func openFileContent(file sourceFile) (io.ReadCloser, int64, error) {
+	f, err := file.tree.Open(file.name)
+	if err != nil {
+		return nil, 0, err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, 0, err
+	}
+	return f, fi.Size(), nil
+}
+
+// fileSniff is what the size and the first binarySniffLen bytes of a file
+// tell about it, enough to report its encoding and size delta without
+// reading it whole.
+type fileSniff struct {
+	size     int64
+	encoding string
+}
+
+func sniffFile(file sourceFile) (fileSniff, error) {
+	r, size, err := openFileContent(file)
+	if err != nil {
+		return fileSniff{}, err
+	}
+	defer r.Close()
+	prefix := make([]byte, min(size, binarySniffLen))
+	n, err := io.ReadFull(r, prefix)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return fileSniff{}, err
+	}
+	return fileSniff{size: size, encoding: sniffEncoding(prefix[:n], size)}, nil
+}
+
+// fileContains reports whether file contains needle, reading it in chunks
+// rather than whole.
+func fileContains(file sourceFile, needle []byte) (bool, error) {
+	r, _, err := openFileContent(file)
+	if err != nil {
+		return false, err
+	}
+	defer r.Close()
+	// Keep the end of each chunk, where needle may start
+	overlap := len(needle) - 1
+	buf := make([]byte, compareBufferSize+overlap)
+	kept := 0
+	for {
+		n, err := io.ReadFull(r, buf[kept:])
+		window := buf[:kept+n]
+		if bytes.Contains(window, needle) {
+			return true, nil
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		kept = copy(buf, window[len(window)-overlap:])
+	}
+}
+
+// streamsEqual compares two readers chunk by chunk, so neither is loaded
+// into memory as a whole. It returns the number of bytes read from both.
+func streamsEqual(r1, r2 io.Reader) (bool, int64, error) {
+	buf1 := make([]byte, compareBufferSize)
+	buf2 := make([]byte, compareBufferSize)
+	var read int64
+	for {
+		n1, err1 := io.ReadFull(r1, buf1)
+		n2, err2 := io.ReadFull(r2, buf2)
+		read += int64(n1 + n2)
+		if err1 != nil && err1 != io.EOF && err1 != io.ErrUnexpectedEOF {
+			return false, read, err1
+		}
+		if err2 != nil && err2 != io.EOF && err2 != io.ErrUnexpectedEOF {
+			return false, read, err2
+		}
+		if !bytes.Equal(buf1[:n1], buf2[:n2]) {
+			return false, read, nil
+		}
+		// A short read means the end of both streams, as they matched
+		if err1 != nil || err2 != nil {
+			return err1 != nil && err2 != nil, read, nil
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/adnsv/gitparator/testsupport"
+)
+
+func TestFileContains(t *testing.T) {
+	needle := "gitparator:ignore-"
+	padding := strings.Repeat("x", compareBufferSize-5)
+	tests := []struct {
+		name    string
+		content string
+		want    bool
+	}{
+		{"empty", "", false},
+		{"short", "// " + needle + "next-line\n", true},
+		{"absent", padding + padding, false},
+		{"across chunks", padding + needle + "start\n", true},
+		{"in a later chunk", padding + padding + needle, true},
+		{"truncated at the end", padding + needle[:10], false},
+	}
+	for _, tt := range tests {
+		dir := testsupport.Dir(t, testsupport.Files{"file.txt": tt.content})
+		got, err := fileContains(localFile(filepath.Join(dir, "file.txt")), []byte(needle))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("%s: fileContains = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
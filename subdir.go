@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// PathMapping pairs source files under one directory with target files
+// under another, for trees whose layouts differ.
+type PathMapping struct {
+	Source string `mapstructure:"source" json:"source"`
+	Target string `mapstructure:"target" json:"target"`
+}
+
+// checkSubdir verifies that subdir exists as a directory below root.
+func checkSubdir(root, subdir, label string) error {
+	if subdir == "" {
+		return nil
+	}
+	info, err := os.Stat(filepath.Join(root, subdir))
+	if err != nil || !info.IsDir() {
+		return fmt.Errorf("%s subdirectory '%s' does not exist in %s", label, subdir, root)
+	}
+	return nil
+}
+
+// archivePrefix turns a subdirectory into the entry name prefix used in
+// archives ("" for the archive root).
+func archivePrefix(subdir string) string {
+	subdir = strings.Trim(toSlash(filepath.Clean(subdir)), "/")
+	if subdir == "" || subdir == "." {
+		return ""
+	}
+	return subdir + "/"
+}
+
+// scopeArchiveNames keeps the names below prefix, with the prefix removed.
+func scopeArchiveNames(names []string, prefix string) []string {
+	if prefix == "" {
+		return names
+	}
+	var scoped []string
+	for _, name := range names {
+		if strings.HasPrefix(name, prefix) {
+			scoped = append(scoped, strings.TrimPrefix(name, prefix))
+		}
+	}
+	return scoped
+}
+
+// archiveRoot returns the leading directories to strip from archive entry
+// names. With components > 0 that many directories are stripped, and all
+// entries must share them. With components < 0 a single directory wrapping
+// every entry, as in GitHub's "Download ZIP" archives, is detected, unless
+// sourceDir (if given) has a top-level directory of the same name.
+func archiveRoot(names []string, components int, sourceDir string) (string, error) {
+	if components == 0 || len(names) == 0 {
+		return "", nil
+	}
+	detect := components < 0
+	if detect {
+		components = 1
+	}
+	unshared := func(name string) (string, error) {
+		if detect {
+			return "", nil
+		}
+		return "", fmt.Errorf("archive entry '%s' is not below %d shared leading directories", name, components)
+	}
+
+	parts := strings.SplitN(names[0], "/", components+1)
+	if len(parts) <= components {
+		return unshared(names[0])
+	}
+	root := strings.Join(parts[:components], "/") + "/"
+	for _, name := range names[1:] {
+		if !strings.HasPrefix(name, root) {
+			return unshared(name)
+		}
+	}
+
+	if detect && sourceDir != "" {
+		if info, err := os.Stat(filepath.Join(sourceDir, parts[0])); err == nil && info.IsDir() {
+			return "", nil
+		}
+	}
+	return root, nil
+}
+
+// mapPath rewrites a source-relative path to the target layout using the
+// first mapping whose source directory contains it.
+func mapPath(relPath string, mappings []PathMapping) string {
+	relPath = toSlash(relPath)
+	for _, m := range mappings {
+		from := strings.Trim(toSlash(m.Source), "/")
+		to := strings.Trim(toSlash(m.Target), "/")
+		switch {
+		case from == "":
+			return joinSlash(to, relPath)
+		case relPath == from:
+			return to
+		case strings.HasPrefix(relPath, from+"/"):
+			return joinSlash(to, strings.TrimPrefix(relPath, from+"/"))
+		}
+	}
+	return relPath
+}
+
+func joinSlash(dir, name string) string {
+	if dir == "" {
+		return name
+	}
+	return dir + "/" + name
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Substitution replaces a placeholder of a project template, such as
+// {{project_name}}, with the value the generated project uses. The target
+// is taken to be the template.
+type Substitution struct {
+	Token string `mapstructure:"token" json:"token"`
+	Value string `mapstructure:"value" json:"value"`
+}
+
+func compileSubstitutions(subs []Substitution) (*strings.Replacer, error) {
+	if len(subs) == 0 {
+		return nil, nil
+	}
+	var oldnew []string
+	for i, sub := range subs {
+		if sub.Token == "" {
+			return nil, fmt.Errorf("substitute_tokens %d: no token given", i+1)
+		}
+		oldnew = append(oldnew, sub.Token, sub.Value)
+	}
+	return strings.NewReplacer(oldnew...), nil
+}
+
+// substitutePath fills the template tokens into a target path, so that
+// templated file names such as {{project_name}}/main.go pair up.
+func (run *runState) substitutePath(relPath string) string {
+	if run.substitutions == nil {
+		return relPath
+	}
+	return run.substitutions.Replace(relPath)
+}
+
+// substituteContent fills the template tokens into target text content.
+// Binary content is left alone.
+func (run *runState) substituteContent(content []byte) []byte {
+	if run.substitutions == nil || isBinary(content) {
+		return content
+	}
+	return []byte(run.substitutions.Replace(string(content)))
+}
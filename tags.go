@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/adnsv/gitparator/wildpath"
+	"github.com/blang/semver/v4"
+	"github.com/go-git/go-git/v5"
+	gitconfig "github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/storage/memory"
+)
+
+// isTagPattern reports whether tag contains wildcard characters and should
+// be resolved against the remote tag list rather than used verbatim.
+func isTagPattern(tag string) bool {
+	return strings.ContainsAny(tag, "*?[{")
+}
+
+// resolveTagPattern lists the tags advertised by the target remote and
+// returns the highest semantic version whose name matches pattern, ignoring
+// case if ignoreCase is set.
+func resolveTagPattern(url, pattern string, ignoreCase bool) (string, error) {
+	compile := wildpath.Compile
+	if ignoreCase {
+		compile = wildpath.CompileFold
+	}
+	matcher, err := compile(pattern)
+	if err != nil {
+		return "", fmt.Errorf("invalid tag pattern: %w", err)
+	}
+
+	remote := git.NewRemote(memory.NewStorage(), &gitconfig.RemoteConfig{
+		Name: "origin",
+		URLs: []string{url},
+	})
+
+	refs, err := remote.List(&git.ListOptions{})
+	if err != nil {
+		return "", fmt.Errorf("error listing remote tags: %w", err)
+	}
+
+	bestTag := ""
+	var bestVer semver.Version
+	for _, ref := range refs {
+		if !ref.Name().IsTag() {
+			continue
+		}
+		name := ref.Name().Short()
+		if !matcher.Match(name) {
+			continue
+		}
+		ver, err := semver.ParseTolerant(name)
+		if err != nil {
+			// Tags that are not semantic versions cannot be ordered
+			continue
+		}
+		if bestTag == "" || ver.GT(bestVer) {
+			bestTag = name
+			bestVer = ver
+		}
+	}
+
+	if bestTag == "" {
+		return "", fmt.Errorf("no semver tags match pattern %q", pattern)
+	}
+	return bestTag, nil
+}
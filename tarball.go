@@ -0,0 +1,284 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// tarEntry is an entry of a tarball target. The content of a regular file
+// starts at offset in the archive or, when spooled, in the spool file of
+// the tarball.
+type tarEntry struct {
+	Header  *tar.Header
+	offset  int64
+	spooled bool
+}
+
+// tarball indexes a tarball target, since tar archives cannot be read at
+// random like zip archives. The entries are listed once; the content of
+// plain tarballs is then read at its offset in the archive, while gzipped
+// and sparse content is decompressed once into a temporary spool file.
+type tarball struct {
+	archive string
+	entries []tarEntry
+	files   map[string]int // regular files by entry name, as index into entries
+	spool   *os.File       // or nil
+}
+
+// isTarball reports whether a target archive is a tar archive rather than a
+// zip archive.
+func isTarball(archivePath string) bool {
+	format := detectArchiveFormat(archivePath)
+	return format == tarArchive || format == tarGzArchive
+}
+
+// openTarball indexes the entries of a tar or gzipped tar archive, within
+// the archive limits of the run. The index is kept until the run is closed.
+func (run *runState) openTarball(archivePath string) (*tarball, error) {
+	if t, ok := run.tarballs[archivePath]; ok {
+		return t, nil
+	}
+
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	budget := newArchiveBudget(archivePath, run.config.ArchiveLimits)
+	counter := &countingReader{f: f}
+	var r io.Reader = counter
+	gzipped := detectArchiveFormat(archivePath) == tarGzArchive
+	if gzipped {
+		fi, err := f.Stat()
+		if err != nil {
+			return nil, err
+		}
+		zr, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		r = &guardedReader{r: zr, budget: budget, compressed: fi.Size()}
+	}
+
+	t := &tarball{archive: archivePath, files: make(map[string]int)}
+	if err := t.index(tar.NewReader(r), counter, budget, gzipped); err != nil {
+		t.close()
+		return nil, err
+	}
+	run.tarballs[archivePath] = t
+	return t, nil
+}
+
+// index lists the entries read by tr. Regular files are located by the
+// position of counter, which tar.Reader leaves at the start of their
+// content, or spooled.
+func (t *tarball) index(tr *tar.Reader, counter *countingReader, budget *archiveBudget, spoolAll bool) error {
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		entry := tarEntry{Header: hdr, offset: counter.n}
+		if hdr.Typeflag == tar.TypeReg {
+			if err := budget.add(hdr.Name, hdr.Size, -1); err != nil {
+				return err
+			}
+			// Sparse content is stored in fragments, so it is spooled whole
+			if spoolAll || isSparse(hdr) {
+				if t.spool == nil {
+					if t.spool, err = os.CreateTemp("", "gitparator-tar-*"); err != nil {
+						return err
+					}
+				}
+				if entry.offset, err = t.spool.Seek(0, io.SeekEnd); err != nil {
+					return err
+				}
+				if _, err := io.Copy(t.spool, tr); err != nil {
+					return err
+				}
+				entry.spooled = true
+			}
+			t.files[tarEntryName(hdr)] = len(t.entries)
+		}
+		t.entries = append(t.entries, entry)
+	}
+}
+
+// isSparse reports whether hdr is a GNU sparse file, in the old GNU or
+// the PAX format.
+func isSparse(hdr *tar.Header) bool {
+	if hdr.Typeflag == tar.TypeGNUSparse {
+		return true
+	}
+	for key := range hdr.PAXRecords {
+		if strings.HasPrefix(key, "GNU.sparse.") {
+			return true
+		}
+	}
+	return false
+}
+
+// open returns the content of a regular file entry. Content in the
+// archive is read through a handle of its own, closed with the file.
+func (t *tarball) open(e tarEntry) (fs.File, error) {
+	if e.spooled {
+		return &tarEntryFile{SectionReader: io.NewSectionReader(t.spool, e.offset, e.Header.Size), info: e.Header.FileInfo()}, nil
+	}
+	f, err := os.Open(t.archive)
+	if err != nil {
+		return nil, err
+	}
+	return &tarEntryFile{SectionReader: io.NewSectionReader(f, e.offset, e.Header.Size), info: e.Header.FileInfo(), archive: f}, nil
+}
+
+// close removes the spool file.
+func (t *tarball) close() {
+	if t.spool != nil {
+		t.spool.Close()
+		os.Remove(t.spool.Name())
+	}
+}
+
+// countingReader counts the bytes read from or skipped in a file. It seeks
+// like the file, so tar.Reader skips content without reading it.
+type countingReader struct {
+	f *os.File
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.f.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func (c *countingReader) Seek(offset int64, whence int) (int64, error) {
+	n, err := c.f.Seek(offset, whence)
+	if err == nil {
+		c.n = n
+	}
+	return n, err
+}
+
+// tarEntryName normalizes entry names, since some tools write "./name" and
+// others "name".
+func tarEntryName(hdr *tar.Header) string {
+	return strings.TrimSuffix(strings.TrimPrefix(hdr.Name, "./"), "/")
+}
+
+func (run *runState) getAllFilesFromTarball(archivePath string, excludePaths []string, respectGitignore bool) ([]sourceFile, []string, error) {
+	var files []sourceFile
+	var excludedFiles []string
+	t, err := run.openTarball(archivePath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error opening tarball %s: %w", archivePath, err)
+	}
+	entries := t.entries
+
+	gitignorePatterns := make(map[string][]string)
+	if respectGitignore {
+		for _, e := range entries {
+			name := tarEntryName(e.Header)
+			if e.Header.Typeflag != tar.TypeReg || path.Base(name) != ".gitignore" {
+				continue
+			}
+			f, err := t.open(e)
+			if err != nil {
+				continue
+			}
+			if patterns, err := parseGitignorePatterns(f); err == nil {
+				gitignorePatterns[path.Dir(name)] = patterns
+			}
+			f.Close()
+		}
+	}
+	shouldIgnore := archiveIgnoreFunc(gitignorePatterns, respectGitignore)
+
+	source := &tarSource{t}
+	run.progress.Start("Scanning "+toSlash(archivePath), len(entries))
+	defer run.progress.Finish()
+	for _, e := range entries {
+		run.progress.Add(1)
+		if e.Header.Typeflag != tar.TypeReg {
+			continue
+		}
+		name := tarEntryName(e.Header)
+		if path.Base(name) == ".gitignore" {
+			continue
+		}
+		if shouldExclude(name, excludePaths) || shouldIgnore(name) {
+			excludedFiles = append(excludedFiles, name)
+			continue
+		}
+		files = append(files, sourceFile{source, name})
+	}
+
+	return files, excludedFiles, nil
+}
+
+// OwnerExpectation lists the ownership every tarball entry should have.
+// Negative ids and empty names are not checked.
+type OwnerExpectation struct {
+	UID   int    `mapstructure:"uid" json:"uid"`
+	GID   int    `mapstructure:"gid" json:"gid"`
+	Uname string `mapstructure:"uname" json:"uname"`
+	Gname string `mapstructure:"gname" json:"gname"`
+}
+
+func (o OwnerExpectation) enabled() bool {
+	return o.UID >= 0 || o.GID >= 0 || o.Uname != "" || o.Gname != ""
+}
+
+// OwnershipIssue is a tarball entry whose ownership differs from the
+// expectation.
+type OwnershipIssue struct {
+	Path     string `json:"path"`
+	Field    string `json:"field"`
+	Expected string `json:"expected"`
+	Actual   string `json:"actual"`
+}
+
+// checkTarballOwnership compares the ownership of all entries not excluded
+// by excludePaths, directories included, against expect.
+func (run *runState) checkTarballOwnership(archivePath string, expect OwnerExpectation, excludePaths []string) ([]OwnershipIssue, error) {
+	t, err := run.openTarball(archivePath)
+	if err != nil {
+		return nil, err
+	}
+
+	var issues []OwnershipIssue
+	for _, e := range t.entries {
+		name := tarEntryName(e.Header)
+		if name == "" || name == "." || shouldExclude(name, excludePaths) {
+			continue
+		}
+		add := func(field, expected, actual string) {
+			issues = append(issues, OwnershipIssue{name, field, expected, actual})
+		}
+		if expect.UID >= 0 && e.Header.Uid != expect.UID {
+			add("uid", strconv.Itoa(expect.UID), strconv.Itoa(e.Header.Uid))
+		}
+		if expect.GID >= 0 && e.Header.Gid != expect.GID {
+			add("gid", strconv.Itoa(expect.GID), strconv.Itoa(e.Header.Gid))
+		}
+		if expect.Uname != "" && e.Header.Uname != expect.Uname {
+			add("uname", expect.Uname, e.Header.Uname)
+		}
+		if expect.Gname != "" && e.Header.Gname != expect.Gname {
+			add("gname", expect.Gname, e.Header.Gname)
+		}
+	}
+	return issues, nil
+}
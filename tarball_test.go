@@ -0,0 +1,92 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/adnsv/gitparator/testsupport"
+)
+
+func TestTarballIndex(t *testing.T) {
+	files := testsupport.Files{
+		"README.md": "# project\n",
+		// Names over 100 bytes take a PAX header of their own
+		strings.Repeat("nested/", 20) + "deep.txt": "deep\n",
+		"data.bin": strings.Repeat("\x00\x01", 3000),
+		"empty":    "",
+	}
+	for _, gzipped := range []bool{false, true} {
+		archive := testsupport.Tarball(t, "project-1.0/", files, gzipped)
+		config := defaultConfig(t)
+		run := startTestRun(t, &config)
+		tb, err := run.openTarball(archive)
+		if err != nil {
+			t.Fatal(err)
+		}
+		source := &tarSource{tb}
+		for p, want := range files {
+			got, err := fs.ReadFile(source, "project-1.0/"+p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != want {
+				t.Errorf("gzipped=%v: %s = %q, want %q", gzipped, p, got, want)
+			}
+		}
+		if _, err := source.Open("project-1.0/missing"); !os.IsNotExist(err) {
+			t.Errorf("gzipped=%v: opening a missing entry: %v", gzipped, err)
+		}
+
+		// Only gzipped content is spooled, and the spool ends with the run
+		spool := tb.spool
+		if (spool != nil) != gzipped {
+			t.Fatalf("gzipped=%v: spool = %v", gzipped, spool)
+		}
+		run.close()
+		if spool != nil {
+			if _, err := os.Stat(spool.Name()); !os.IsNotExist(err) {
+				t.Errorf("spool file left behind: %v", err)
+			}
+		}
+	}
+}
+
+func TestTarballOwnership(t *testing.T) {
+	result := compareFixtures(t, func(config *Config) {
+		config.SourceDir = testsupport.Dir(t, sourceFiles)
+		config.TargetZip = testsupport.Tarball(t, "", targetFiles, false)
+		config.ExpectOwner = OwnerExpectation{UID: 1000, GID: -1}
+		config.ExcludePaths = []string{"docs/**"}
+	})
+	want := []OwnershipIssue{
+		{"README.md", "uid", "1000", "0"},
+		{"src/main.go", "uid", "1000", "0"},
+	}
+	if !reflect.DeepEqual(result.OwnershipIssues, want) {
+		t.Errorf("ownership issues = %+v, want %+v", result.OwnershipIssues, want)
+	}
+}
+
+func TestTarballLimits(t *testing.T) {
+	archive := testsupport.Tarball(t, "", testsupport.Files{"zeros.bin": strings.Repeat("\x00", 4<<20)}, true)
+	for _, tt := range []struct {
+		limits ArchiveLimits
+		want   string
+	}{
+		{ArchiveLimits{MaxRatio: 100}, "max_ratio"},
+		{ArchiveLimits{MaxEntrySize: 1 << 20}, "max_entry_size"},
+		{ArchiveLimits{MaxTotalSize: 1 << 20}, "max_total_size"},
+	} {
+		config := defaultConfig(t)
+		config.ArchiveLimits = tt.limits
+		_, err := startTestRun(t, &config).openTarball(archive)
+		var limitErr *limitError
+		if !errors.As(err, &limitErr) || limitErr.limit != tt.want {
+			t.Errorf("opening a tarball over %s: %v", tt.want, err)
+		}
+	}
+}
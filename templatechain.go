@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// originSource attributes a difference to the source itself, when no
+// template level has the file.
+const originSource = "source"
+
+// TemplateLevel is one template the target derives from, such as a base
+// template that a language template was generated from. Levels are read
+// from a clone of URL or from the local directory Path.
+type TemplateLevel struct {
+	Name   string `mapstructure:"name" json:"name"`
+	URL    string `mapstructure:"url" json:"url"`
+	Path   string `mapstructure:"path" json:"path"`
+	Branch string `mapstructure:"branch" json:"branch"`
+	Tag    string `mapstructure:"tag" json:"tag"`
+	Subdir string `mapstructure:"subdir" json:"subdir"`
+}
+
+// templateChain holds the directories of the levels of a template chain,
+// from the most basic template to the target.
+type templateChain struct {
+	names []string
+	dirs  []string
+	temp  []string // clones removed by close
+}
+
+// openTemplateChain clones or locates the levels of config.TemplateChain.
+// The target, in targetDir, is the last level of the chain. Sideband output
+// of cloned remotes is written to progress, which may be nil. On error, the
+// levels cloned so far are removed.
+func openTemplateChain(config *Config, targetDir string, progress io.Writer) (*templateChain, error) {
+	chain := &templateChain{}
+	if err := chain.addLevels(config, progress); err != nil {
+		chain.close()
+		return nil, err
+	}
+	chain.names = append(chain.names, "target")
+	chain.dirs = append(chain.dirs, filepath.Join(targetDir, config.TargetSubdir))
+	return chain, nil
+}
+
+func (c *templateChain) addLevels(config *Config, progress io.Writer) error {
+	for i, level := range config.TemplateChain {
+		name := level.Name
+		if name == "" {
+			name = fmt.Sprintf("level %d", i+1)
+		}
+		dir := level.Path
+		switch {
+		case level.URL != "" && level.Path != "":
+			return fmt.Errorf("template_chain level %s: only one of url and path should be specified", name)
+		case level.URL != "":
+			temp, err := os.MkdirTemp("", "gitparator-chain-")
+			if err != nil {
+				return err
+			}
+			c.temp = append(c.temp, temp)
+			levelConfig := *config
+			levelConfig.TargetURL, levelConfig.Branch, levelConfig.Tag = level.URL, level.Branch, level.Tag
+			if err := cloneRepo(&levelConfig, temp, progress); err != nil {
+				return fmt.Errorf("error cloning template_chain level %s: %w", name, err)
+			}
+			dir = temp
+		case level.Path == "":
+			return fmt.Errorf("template_chain level %s: one of url and path must be specified", name)
+		}
+		if err := checkSubdir(dir, level.Subdir, "template_chain level "+name); err != nil {
+			return err
+		}
+		c.names = append(c.names, name)
+		c.dirs = append(c.dirs, filepath.Join(dir, level.Subdir))
+	}
+	return nil
+}
+
+func (c *templateChain) close() {
+	for _, dir := range c.temp {
+		os.RemoveAll(dir)
+	}
+}
+
+// origin returns the level the target state of relPath originates from:
+// the earliest level since which the file has had the content it has in
+// the target, or has been missing from it. A file that no level has is
+// attributed to the source.
+func (c *templateChain) origin(relPath string) string {
+	last := len(c.dirs) - 1
+	target, targetExists := c.read(last, relPath)
+	origin := last
+	for i := last - 1; i >= 0; i-- {
+		content, exists := c.read(i, relPath)
+		if exists != targetExists || !bytes.Equal(content, target) {
+			break
+		}
+		origin = i
+	}
+	if !targetExists && origin == 0 {
+		return originSource
+	}
+	return c.names[origin]
+}
+
+func (c *templateChain) read(level int, relPath string) ([]byte, bool) {
+	content, err := os.ReadFile(filepath.Join(c.dirs[level], filepath.FromSlash(relPath)))
+	if err != nil {
+		return nil, false
+	}
+	return content, true
+}
+
+// attributeOrigins records for each difference the template level its
+// target state originates from.
+func attributeOrigins(result *ComparisonResult, chain *templateChain, config *Config) {
+	result.Origins = make(map[string]string)
+	for _, pair := range result.differing {
+		result.Origins[pair.SourcePath] = chain.origin(pair.TargetPath)
+	}
+	for _, p := range result.SourceOnlyFiles {
+		name := p
+		if len(config.PathMap) > 0 {
+			name = mapPath(p, config.PathMap)
+		}
+		result.Origins[p] = chain.origin(name)
+	}
+	for _, p := range result.TargetOnlyFiles {
+		result.Origins[p] = chain.origin(p)
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"os"
+	"testing"
+
+	"github.com/adnsv/gitparator/testsupport"
+)
+
+func TestOpenTemplateChainError(t *testing.T) {
+	repo := testsupport.NewRepo(t)
+	repo.Commit(testsupport.Files{"README.md": "# base\n"}, "base")
+	temp := t.TempDir()
+	t.Setenv("TMPDIR", temp)
+
+	config := defaultConfig(t)
+	config.TemplateChain = []TemplateLevel{
+		{Name: "base", URL: repo.Dir},
+		{Name: "broken", URL: repo.Dir, Path: "."},
+	}
+	chain, err := openTemplateChain(&config, testsupport.Dir(t, nil), nil)
+	if err == nil || chain != nil {
+		t.Fatalf("openTemplateChain = %v, %v, want an error", chain, err)
+	}
+	// The clone of the first level is removed
+	entries, err := os.ReadDir(temp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("clones left behind: %v", entries)
+	}
+}
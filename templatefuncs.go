@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"regexp"
+)
+
+// TemplateFunction is a text transformation defined in the config and
+// exposed to report templates, e.g. to redact internal host names. It is a
+// list entry rather than a map key because config keys are case-folded.
+type TemplateFunction struct {
+	Name         string            `mapstructure:"name" json:"name"`
+	Replacements []TextReplacement `mapstructure:"replacements" json:"replacements"`
+}
+
+// TextReplacement is one regex replacement step of a template function.
+type TextReplacement struct {
+	Pattern string `mapstructure:"pattern" json:"pattern"`
+	Replace string `mapstructure:"replace" json:"replace"`
+}
+
+var templateFuncName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// addConfigTemplateFuncs adds the config-defined text transformations to
+// funcMap. Each function takes a string and applies its replacements in
+// order; names already used by built-in functions are rejected.
+func addConfigTemplateFuncs(funcMap template.FuncMap, defs []TemplateFunction) error {
+	for _, def := range defs {
+		name, steps := def.Name, def.Replacements
+		if !templateFuncName.MatchString(name) {
+			return fmt.Errorf("template function %q: invalid name", name)
+		}
+		if _, exists := funcMap[name]; exists {
+			return fmt.Errorf("template function %q: name is already used by a built-in function", name)
+		}
+
+		patterns := make([]*regexp.Regexp, len(steps))
+		for i, step := range steps {
+			re, err := regexp.Compile(step.Pattern)
+			if err != nil {
+				return fmt.Errorf("template function %q: invalid pattern: %w", name, err)
+			}
+			patterns[i] = re
+		}
+		funcMap[name] = func(s string) string {
+			for i, re := range patterns {
+				s = re.ReplaceAllString(s, steps[i].Replace)
+			}
+			return s
+		}
+	}
+	return nil
+}
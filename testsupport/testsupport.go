@@ -0,0 +1,246 @@
+// Package testsupport builds the fixtures of end-to-end tests: directory
+// trees, git repositories, zip archives and tarballs, all in temporary
+// directories of the test, so comparisons can be tested without network
+// access.
+package testsupport
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// Files is the content of a fixture tree by slash-separated path.
+type Files map[string]string
+
+// paths returns the paths of files in a stable order, so archives built
+// from the same files are identical.
+func (files Files) paths() []string {
+	paths := make([]string, 0, len(files))
+	for p := range files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// modTime is the modification time of archive entries and commits.
+var modTime = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// WriteFiles writes files below dir, creating directories as needed.
+func WriteFiles(t testing.TB, dir string, files Files) {
+	t.Helper()
+	for _, p := range files.paths() {
+		name := filepath.Join(dir, filepath.FromSlash(p))
+		if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(name, []byte(files[p]), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// ReadFiles returns the files below dir, to check a tree after a command
+// changed it.
+func ReadFiles(t testing.TB, dir string) Files {
+	t.Helper()
+	files := make(Files)
+	err := filepath.WalkDir(dir, func(name string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		content, err := os.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, name)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(rel)] = string(content)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+// Dir writes files into a new temporary directory and returns its path.
+func Dir(t testing.TB, files Files) string {
+	t.Helper()
+	dir := t.TempDir()
+	WriteFiles(t, dir, files)
+	return dir
+}
+
+// Zip writes files into a new zip archive and returns its path. Entries
+// are named prefix+path, so a prefix like "repo-1.0/" gives the wrapping
+// directory of GitHub archives.
+func Zip(t testing.TB, prefix string, files Files) string {
+	t.Helper()
+	name := filepath.Join(t.TempDir(), "fixture.zip")
+	f, err := os.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	zw := zip.NewWriter(f)
+	for _, p := range files.paths() {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: prefix + p, Method: zip.Deflate, Modified: modTime})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.WriteString(w, files[p]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return name
+}
+
+// Tarball writes files into a new tar archive, gzipped if gzipped is set,
+// and returns its path. Entries are named prefix+path as with Zip.
+func Tarball(t testing.TB, prefix string, files Files, gzipped bool) string {
+	t.Helper()
+	name := filepath.Join(t.TempDir(), "fixture.tar")
+	if gzipped {
+		name += ".gz"
+	}
+	f, err := os.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var w io.Writer = f
+	if gzipped {
+		zw := gzip.NewWriter(f)
+		defer func() {
+			if err := zw.Close(); err != nil {
+				t.Fatal(err)
+			}
+		}()
+		w = zw
+	}
+	tw := tar.NewWriter(w)
+	for _, p := range files.paths() {
+		hdr := &tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     prefix + p,
+			Mode:     0o644,
+			Size:     int64(len(files[p])),
+			ModTime:  modTime,
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.WriteString(tw, files[p]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return name
+}
+
+// Repo is a fixture git repository with a working tree. Its path can be
+// given as --target-url, since local repositories are cloned without
+// network access.
+type Repo struct {
+	Dir string
+
+	t       testing.TB
+	repo    *git.Repository
+	commits int
+}
+
+// NewRepo creates an empty repository in a new temporary directory. Its
+// first commit is on the master branch.
+func NewRepo(t testing.TB) *Repo {
+	t.Helper()
+	dir := t.TempDir()
+	repo, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Repo{Dir: dir, t: t, repo: repo}
+}
+
+// Commit replaces the working tree with files, so files of earlier commits
+// missing from files are deleted, and commits it on the current branch.
+func (r *Repo) Commit(files Files, message string) plumbing.Hash {
+	r.t.Helper()
+	entries, err := os.ReadDir(r.Dir)
+	if err != nil {
+		r.t.Fatal(err)
+	}
+	for _, e := range entries {
+		if e.Name() == git.GitDirName {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(r.Dir, e.Name())); err != nil {
+			r.t.Fatal(err)
+		}
+	}
+	WriteFiles(r.t, r.Dir, files)
+
+	wt, err := r.repo.Worktree()
+	if err != nil {
+		r.t.Fatal(err)
+	}
+	if err := wt.AddWithOptions(&git.AddOptions{All: true}); err != nil {
+		r.t.Fatal(err)
+	}
+	// Commits a minute apart keep hashes stable and history ordered
+	when := modTime.Add(time.Duration(r.commits) * time.Minute)
+	r.commits++
+	hash, err := wt.Commit(message, &git.CommitOptions{
+		Author:            &object.Signature{Name: "Fixture", Email: "fixture@example.com", When: when},
+		AllowEmptyCommits: true,
+	})
+	if err != nil {
+		r.t.Fatal(err)
+	}
+	return hash
+}
+
+// Tag creates a lightweight tag at the current commit.
+func (r *Repo) Tag(name string) {
+	r.t.Helper()
+	head, err := r.repo.Head()
+	if err != nil {
+		r.t.Fatal(err)
+	}
+	if _, err := r.repo.CreateTag(name, head.Hash(), nil); err != nil {
+		r.t.Fatal(err)
+	}
+}
+
+// Branch creates a branch at the current commit and checks it out, so
+// later commits go to the branch.
+func (r *Repo) Branch(name string) {
+	r.t.Helper()
+	wt, err := r.repo.Worktree()
+	if err != nil {
+		r.t.Fatal(err)
+	}
+	if err := wt.Checkout(&git.CheckoutOptions{Branch: plumbing.NewBranchReferenceName(name), Create: true}); err != nil {
+		r.t.Fatal(err)
+	}
+}
@@ -0,0 +1,162 @@
+package testsupport
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+var fixture = Files{
+	"README.md":   "# fixture\n",
+	"src/main.go": "package main\n",
+}
+
+func TestDir(t *testing.T) {
+	dir := Dir(t, fixture)
+	for p, want := range fixture {
+		got, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(p)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != want {
+			t.Errorf("%s = %q, want %q", p, got, want)
+		}
+	}
+}
+
+func TestReadFiles(t *testing.T) {
+	if got := ReadFiles(t, Dir(t, fixture)); !reflect.DeepEqual(got, fixture) {
+		t.Errorf("ReadFiles = %v, want %v", got, fixture)
+	}
+}
+
+func TestZip(t *testing.T) {
+	zr, err := zip.OpenReader(Zip(t, "repo-1.0/", fixture))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zr.Close()
+
+	got := make(Files)
+	for _, f := range zr.File {
+		r, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got[f.Name] = string(data)
+	}
+	want := Files{"repo-1.0/README.md": fixture["README.md"], "repo-1.0/src/main.go": fixture["src/main.go"]}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("entries = %v, want %v", got, want)
+	}
+}
+
+func TestTarball(t *testing.T) {
+	for _, gzipped := range []bool{false, true} {
+		f, err := os.Open(Tarball(t, "", fixture, gzipped))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		var r io.Reader = f
+		if gzipped {
+			if r, err = gzip.NewReader(f); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		got := make(Files)
+		tr := tar.NewReader(r)
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, err := io.ReadAll(tr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got[hdr.Name] = string(data)
+		}
+		if !reflect.DeepEqual(got, fixture) {
+			t.Errorf("gzipped %v: entries = %v, want %v", gzipped, got, fixture)
+		}
+	}
+}
+
+// treeFiles lists the files committed at ref.
+func treeFiles(t *testing.T, r *Repo, ref string) []string {
+	t.Helper()
+	hash, err := r.repo.ResolveRevision(plumbing.Revision(ref))
+	if err != nil {
+		t.Fatal(err)
+	}
+	commit, err := r.repo.CommitObject(*hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var files []string
+	tree.Files().ForEach(func(f *object.File) error {
+		files = append(files, f.Name)
+		return nil
+	})
+	sort.Strings(files)
+	return files
+}
+
+func TestRepo(t *testing.T) {
+	r := NewRepo(t)
+	r.Commit(fixture, "initial")
+	r.Tag("v1.0.0")
+	r.Branch("feature")
+	r.Commit(Files{"README.md": "# changed\n", "docs/guide.md": "guide\n"}, "replace src")
+
+	if got, want := treeFiles(t, r, "v1.0.0"), []string{"README.md", "src/main.go"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("v1.0.0 files = %v, want %v", got, want)
+	}
+	if got, want := treeFiles(t, r, "master"), []string{"README.md", "src/main.go"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("master files = %v, want %v", got, want)
+	}
+	if got, want := treeFiles(t, r, "feature"), []string{"README.md", "docs/guide.md"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("feature files = %v, want %v", got, want)
+	}
+}
+
+func TestRepoClone(t *testing.T) {
+	r := NewRepo(t)
+	r.Commit(fixture, "initial")
+
+	dir := t.TempDir()
+	if _, err := git.PlainClone(dir, false, &git.CloneOptions{URL: r.Dir}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(filepath.Join(dir, "src", "main.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != fixture["src/main.go"] {
+		t.Errorf("cloned src/main.go = %q", got)
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Themes of the HTML report.
+const (
+	themeAuto  = "auto" // follows the reader's prefers-color-scheme
+	themeLight = "light"
+	themeDark  = "dark"
+)
+
+// ThemeVariables overrides the CSS variables of the HTML report, such as
+// bg, text or different, in the light and the dark theme, to restyle the
+// report without a custom template.
+type ThemeVariables struct {
+	Light map[string]string `mapstructure:"light" json:"light,omitempty"`
+	Dark  map[string]string `mapstructure:"dark" json:"dark,omitempty"`
+}
+
+var (
+	cssVariableName = regexp.MustCompile(`^[a-z][a-z0-9-]*$`)
+	// Values cannot end the declaration or the style element
+	cssVariableValue = regexp.MustCompile(`^[^;{}<>\\\n]+$`)
+)
+
+func validateTheme(theme string, variables ThemeVariables) error {
+	switch theme {
+	case themeAuto, themeLight, themeDark:
+	default:
+		return fmt.Errorf("unknown theme %q (expected auto, light or dark)", theme)
+	}
+	for _, vars := range []map[string]string{variables.Light, variables.Dark} {
+		for name, value := range vars {
+			if !cssVariableName.MatchString(name) {
+				return fmt.Errorf("theme_variables: invalid name %q (expected lowercase letters, digits and dashes)", name)
+			}
+			if !cssVariableValue.MatchString(value) {
+				return fmt.Errorf("theme_variables: invalid value %q for %s", value, name)
+			}
+		}
+	}
+	return nil
+}
+
+// themeAttribute returns the data-theme of the report for a theme, or ""
+// to follow prefers-color-scheme.
+func themeAttribute(theme string) string {
+	if theme == themeAuto {
+		return ""
+	}
+	return theme
+}
+
+// themeCSS returns the rules applying the configured theme variables after
+// the built-in ones.
+func themeCSS(variables ThemeVariables) template.CSS {
+	declarations := func(vars map[string]string) string {
+		names := make([]string, 0, len(vars))
+		for name := range vars {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		var b strings.Builder
+		for _, name := range names {
+			fmt.Fprintf(&b, " --%s: %s;", name, strings.TrimSpace(vars[name]))
+		}
+		return b.String()
+	}
+
+	var css strings.Builder
+	// Same selectors as the built-in values, which come first
+	if len(variables.Light) > 0 {
+		fmt.Fprintf(&css, ":root {%s }\n", declarations(variables.Light))
+	}
+	if len(variables.Dark) > 0 {
+		fmt.Fprintf(&css, ":root[data-theme=\"dark\"] {%s }\n", declarations(variables.Dark))
+		fmt.Fprintf(&css, "@media (prefers-color-scheme: dark) { :root:not([data-theme=\"light\"]) {%s } }\n", declarations(variables.Dark))
+	}
+	return template.CSS(css.String())
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+// Sides a difference was introduced on, relative to the base revision.
+const (
+	changedInSource = "source"
+	changedInTarget = "target"
+	changedInBoth   = "both"
+)
+
+// baseTree is the tree of the base revision, the common ancestor of source
+// and target, in the repository containing one of the compared sides.
+type baseTree struct {
+	files *gitTreeSource // compared directory of the revision
+	side  string         // side whose repository holds the revision
+}
+
+// openBaseTree resolves ref in the repository of the source directory or,
+// failing that, of the target directory. Either directory may be "" when
+// that side is not a git working tree.
+func openBaseTree(ref, sourceDir, targetDir string) (*baseTree, error) {
+	var lastErr error
+	for _, side := range []struct{ name, dir string }{{changedInSource, sourceDir}, {changedInTarget, targetDir}} {
+		if side.dir == "" {
+			continue
+		}
+		base, err := resolveBaseTree(ref, side.dir)
+		if err == nil {
+			base.side = side.name
+			return base, nil
+		}
+		lastErr = err
+	}
+	if lastErr == nil {
+		return nil, fmt.Errorf("--base needs a git working tree on the source or target side")
+	}
+	return nil, fmt.Errorf("cannot resolve base '%s' (a shallow clone may need --full-history): %w", ref, lastErr)
+}
+
+func resolveBaseTree(ref, dir string) (*baseTree, error) {
+	repo, err := git.PlainOpenWithOptions(dir, &git.PlainOpenOptions{DetectDotGit: true})
+	if err != nil {
+		return nil, err
+	}
+	hash, err := repo.ResolveRevision(plumbing.Revision(ref))
+	if err != nil {
+		return nil, err
+	}
+	commit, err := repo.CommitObject(*hash)
+	if err != nil {
+		return nil, err
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, err
+	}
+
+	wt, err := repo.Worktree()
+	if err != nil {
+		return nil, err
+	}
+	root, err := filepath.Abs(wt.Filesystem.Root())
+	if err != nil {
+		return nil, err
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	prefix, err := filepath.Rel(root, abs)
+	if err != nil {
+		return nil, err
+	}
+	if prefix == "." {
+		prefix = ""
+	}
+	return &baseTree{files: &gitTreeSource{tree: tree, prefix: toSlash(prefix), name: ref}}, nil
+}
+
+// read returns the base content of a compared path, and false if the file
+// did not exist in the base revision.
+func (b *baseTree) read(relPath string) ([]byte, bool) {
+	content, err := readFileContent(sourceFile{b.files, toSlash(relPath)})
+	if err != nil {
+		return nil, false
+	}
+	return content, true
+}
+
+// classifyChanges records for each difference whether the source, the
+// target or both changed the file since the base revision.
+func classifyChanges(result *ComparisonResult, base *baseTree) {
+	result.Changes = make(map[string]string)
+	changed := func(content []byte, exists bool, file sourceFile) bool {
+		if !exists {
+			return true
+		}
+		current, err := readFileContent(file)
+		return err != nil || !bytes.Equal(current, content)
+	}
+
+	for _, pair := range result.differing {
+		relPath := pair.SourcePath
+		if base.side == changedInTarget {
+			relPath = pair.TargetPath
+		}
+		content, exists := base.read(relPath)
+		inSource := changed(content, exists, pair.SourceFile)
+		inTarget := changed(content, exists, pair.TargetFile)
+		switch {
+		case inSource && inTarget:
+			result.Changes[pair.SourcePath] = changedInBoth
+		case inTarget:
+			result.Changes[pair.SourcePath] = changedInTarget
+		default:
+			result.Changes[pair.SourcePath] = changedInSource
+		}
+	}
+
+	// A one-sided file was added on its side, or deleted on the other
+	for _, p := range result.SourceOnlyFiles {
+		if _, exists := base.read(p); exists {
+			result.Changes[p] = changedInTarget
+		} else {
+			result.Changes[p] = changedInSource
+		}
+	}
+	for _, p := range result.TargetOnlyFiles {
+		if _, exists := base.read(p); exists {
+			result.Changes[p] = changedInSource
+		} else {
+			result.Changes[p] = changedInTarget
+		}
+	}
+}
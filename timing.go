@@ -0,0 +1,53 @@
+package main
+
+import "time"
+
+// PhaseTiming is the accumulated wall time spent in one phase of a run.
+type PhaseTiming struct {
+	Name     string        `json:"name"`
+	Duration time.Duration `json:"duration"`
+}
+
+// runTimings records how long each phase (clone, scan, compare, diff,
+// render, ...) takes. Phases tracked several times accumulate.
+type runTimings struct {
+	start  time.Time
+	phases []PhaseTiming
+}
+
+func newRunTimings() *runTimings {
+	return &runTimings{start: time.Now()}
+}
+
+// Track starts timing a phase and returns the function that stops it.
+func (t *runTimings) Track(name string) func() {
+	start := time.Now()
+	return func() {
+		t.add(name, time.Since(start))
+	}
+}
+
+func (t *runTimings) add(name string, d time.Duration) {
+	for i := range t.phases {
+		if t.phases[i].Name == name {
+			t.phases[i].Duration += d
+			return
+		}
+	}
+	t.phases = append(t.phases, PhaseTiming{Name: name, Duration: d})
+}
+
+// Phases returns the phases recorded so far in the order they first ran.
+func (t *runTimings) Phases() []PhaseTiming {
+	return append([]PhaseTiming(nil), t.phases...)
+}
+
+// Started returns the time the run began.
+func (t *runTimings) Started() time.Time {
+	return t.start
+}
+
+// Elapsed returns the wall time since the run began.
+func (t *runTimings) Elapsed() time.Duration {
+	return time.Since(t.start)
+}
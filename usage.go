@@ -0,0 +1,60 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// usageError is a misuse of the command line, such as flags that cannot be
+// combined. It is reported with exit status 2 before any work starts.
+type usageError struct {
+	err error
+}
+
+func (e usageError) Error() string { return e.err.Error() }
+
+func (e usageError) Unwrap() error { return e.err }
+
+// markExclusiveFlags records the flags of cmd that cannot be combined: the
+// options of the registered target resolvers, so new kinds of targets are
+// covered too, the refs to clone and the options only some targets take.
+func markExclusiveFlags(cmd *cobra.Command) {
+	var targets []string
+	for _, r := range targetResolvers {
+		for _, flag := range r.Flags() {
+			name := strings.TrimPrefix(flag, "--")
+			targets = append(targets, name)
+			// Only clones have a commit to lock, and only archives can be
+			// compared with a source archive
+			if _, ok := r.(gitTargetResolver); !ok {
+				cmd.MarkFlagsMutuallyExclusive("locked", name)
+			}
+			switch r.(type) {
+			case gitTargetResolver, pathTargetResolver, manifestTargetResolver:
+				cmd.MarkFlagsMutuallyExclusive("source-zip", name)
+			}
+		}
+	}
+	cmd.MarkFlagsMutuallyExclusive(targets...)
+	cmd.MarkFlagsMutuallyExclusive("branch", "tag", "tag-pattern")
+}
+
+// checkCombinations returns the PreRunE of commands running a comparison.
+// It rejects flags that cannot be combined, and a missing or ambiguous
+// target in the flags and the config file, as usage errors.
+func checkCombinations(config *Config) func(cmd *cobra.Command, args []string) error {
+	return func(cmd *cobra.Command, args []string) error {
+		if err := cmd.ValidateFlagGroups(); err != nil {
+			return usageError{err}
+		}
+		// Requests served over stdio name their own targets
+		if stdio, _ := cmd.Flags().GetBool("stdio"); stdio {
+			return nil
+		}
+		if _, err := selectTargetResolver(config); err != nil {
+			return usageError{err}
+		}
+		return nil
+	}
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// newVerifyCommand creates the verify subcommand, which checks a release
+// archive against the tagged tree it claims to be built from.
+func newVerifyCommand(config *Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "verify",
+		Short: "Verify that a release archive is identical to the repository at its tag",
+		Long: `Verify clones the repository named by --target (or --target-url and --tag)
+at the tag and compares it with the release archive given by --archive.
+Files marked export-ignore in .gitattributes are not expected in the archive.
+The command exits with status 1 if the archive and the tag differ.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			archive, _ := cmd.Flags().GetString("archive")
+			passed, err := runVerify(cmd, config, archive)
+			if err != nil {
+				fmt.Println("Error:", err)
+				os.Exit(1)
+			}
+			if !passed {
+				fmt.Println("FAIL: the archive differs from the tagged tree, see the report")
+				os.Exit(1)
+			}
+			fmt.Println("PASS: the archive matches the tagged tree")
+		},
+	}
+	cmd.Flags().StringP("archive", "", "", "Path or http(s) URL of the release archive (.zip, .tar, .tar.gz or .tgz)")
+	cmd.MarkFlagRequired("archive")
+	return cmd
+}
+
+// runVerify clones the tagged tree, compares it as the source with the
+// archive as the target and reports whether both hold the same files.
+func runVerify(cmd *cobra.Command, config *Config, archive string) (bool, error) {
+	if config.Target != "" {
+		if err := expandTargetShorthand(config); err != nil {
+			return false, err
+		}
+		config.Target = ""
+	}
+	if config.TargetURL == "" || config.Tag == "" {
+		return false, errors.New("verify requires the repository and tag, as --target owner/repo@tag or --target-url with --tag")
+	}
+	if config.TargetPath != "" || config.TargetZip != "" || config.TargetModule != "" || config.TargetRelease != "" {
+		return false, errors.New("verify takes the archive from --archive; remove the other target settings")
+	}
+
+	// git archive includes tracked files even if they match .gitignore
+	if !isExplicit(cmd, "respect_gitignore", "respect-gitignore") {
+		config.RespectGitignore = false
+	}
+
+	if config.TempDir == "" {
+		config.TempDir = "gitparator_temp"
+	}
+	// Clone below the temp dir, so a downloaded archive lands next to the
+	// tree rather than in it
+	cloneDir := filepath.Join(config.TempDir, "tree")
+	fmt.Printf("Cloning %s at %s\n", config.TargetURL, config.Tag)
+	if err := prepareTarget(config, cloneDir, os.Stdout, nil); err != nil {
+		return false, fmt.Errorf("error cloning repository: %w", err)
+	}
+	// Leave the temp dir alone, it may hold files of other runs
+	defer os.RemoveAll(cloneDir)
+
+	ignored, err := exportIgnorePatterns(cloneDir)
+	if err != nil {
+		return false, fmt.Errorf("error reading .gitattributes: %w", err)
+	}
+	config.ExcludePaths = append(config.ExcludePaths, ignored...)
+
+	config.SourceDir, config.SourceSubdir = cloneDir, ""
+	config.TargetURL, config.Branch, config.Tag, config.TagPattern = "", "", "", ""
+	config.TargetZip = archive
+
+	run, err := startRun(config, os.Stdout)
+	if err != nil {
+		return false, err
+	}
+	defer run.close()
+	result, err := run.runComparison()
+	if err != nil {
+		return false, err
+	}
+	if err := run.report(result); err != nil {
+		return false, err
+	}
+	// Entries with unsafe names are left out of the comparison, so they
+	// would otherwise pass unnoticed
+	passed := len(result.DifferentFiles) == 0 && len(result.SourceOnlyFiles) == 0 && len(result.TargetOnlyFiles) == 0 &&
+		len(result.Anomalies) == 0
+	return passed, nil
+}
+
+// exportIgnorePatterns collects the paths marked export-ignore in the
+// .gitattributes files of a tree, as exclude patterns relative to root.
+func exportIgnorePatterns(root string) ([]string, error) {
+	var patterns []string
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && d.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		if d.IsDir() || d.Name() != ".gitattributes" {
+			return nil
+		}
+		dir, err := filepath.Rel(root, filepath.Dir(p))
+		if err != nil {
+			return err
+		}
+		found, err := readExportIgnore(p, toSlash(dir))
+		patterns = append(patterns, found...)
+		return err
+	})
+	return patterns, err
+}
+
+func readExportIgnore(file, dir string) ([]string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var patterns []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		for _, attr := range fields[1:] {
+			if attr == "export-ignore" {
+				patterns = append(patterns, exportIgnoreGlobs(fields[0], dir)...)
+				break
+			}
+		}
+	}
+	return patterns, scanner.Err()
+}
+
+// exportIgnoreGlobs turns a .gitattributes pattern found in dir into globs
+// matching the files it excludes, including those below a matched
+// directory. As in .gitignore, a pattern without a slash matches at any
+// depth.
+func exportIgnoreGlobs(pattern, dir string) []string {
+	pattern = strings.TrimSuffix(pattern, "/")
+	var glob string
+	if strings.Contains(pattern, "/") {
+		glob = path.Join(dir, strings.TrimPrefix(pattern, "/"))
+	} else {
+		glob = path.Join(dir, "**", pattern)
+	}
+	glob = strings.TrimPrefix(glob, "./")
+	return []string{glob, glob + "/**"}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/adnsv/gitparator/testsupport"
+)
+
+func TestVerify(t *testing.T) {
+	tree := testsupport.Files{"README.md": "# project\n", "main.go": "package main\n"}
+	tests := []struct {
+		name    string
+		archive testsupport.Files
+		want    bool
+	}{
+		{"identical", tree, true},
+		{"different", testsupport.Files{"README.md": "# project\n", "main.go": "package other\n"}, false},
+		{"traversal", testsupport.Files{"README.md": "# project\n", "main.go": "package main\n", "../evil.sh": "rm -rf ~\n"}, false},
+		{"control character", testsupport.Files{"README.md": "# project\n", "main.go": "package main\n", "notes\x1b.txt": "\n"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := testsupport.NewRepo(t)
+			repo.Commit(tree, "release")
+			repo.Tag("v1.0.0")
+
+			config := defaultConfig(t)
+			config.TargetURL, config.Tag = repo.Dir, "v1.0.0"
+			config.TempDir = t.TempDir()
+			kept := filepath.Join(config.TempDir, "kept.txt")
+			if err := os.WriteFile(kept, nil, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			config.OutputFile = []string{filepath.Join(t.TempDir(), "report.json")}
+			config.LockFile = ""
+			passed, err := runVerify(newVerifyCommand(&config), &config, testsupport.Zip(t, "", tt.archive))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if passed != tt.want {
+				t.Errorf("passed = %v, want %v", passed, tt.want)
+			}
+			// Only the clone is removed from the temp dir
+			if _, err := os.Stat(filepath.Join(config.TempDir, "tree")); !os.IsNotExist(err) {
+				t.Errorf("clone not removed: %v", err)
+			}
+			if _, err := os.Stat(kept); err != nil {
+				t.Errorf("file in the temp dir removed: %v", err)
+			}
+		})
+	}
+}
+
+func TestVerifyMissingArchive(t *testing.T) {
+	repo := testsupport.NewRepo(t)
+	repo.Commit(testsupport.Files{"README.md": "# project\n"}, "release")
+	repo.Tag("v1.0.0")
+
+	config := defaultConfig(t)
+	config.TargetURL, config.Tag = repo.Dir, "v1.0.0"
+	config.TempDir = t.TempDir()
+	config.LockFile = ""
+	// The error is returned rather than ending the process, so the clone
+	// is removed
+	if _, err := runVerify(newVerifyCommand(&config), &config, filepath.Join(t.TempDir(), "missing.zip")); err == nil {
+		t.Fatal("runVerify succeeded with a missing archive")
+	}
+	if _, err := os.Stat(filepath.Join(config.TempDir, "tree")); !os.IsNotExist(err) {
+		t.Errorf("clone not removed: %v", err)
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// Kinds of files whose differences may be routine release bumps.
+const (
+	bumpNone = iota
+	bumpChangelog
+	bumpVersionFile
+	bumpManifest
+)
+
+// versionManifests are package manifests that record the package version
+// in a field named "version".
+var versionManifests = map[string]bool{
+	"package.json":      true,
+	"composer.json":     true,
+	"manifest.json":     true,
+	"cargo.toml":        true,
+	"pyproject.toml":    true,
+	"setup.py":          true,
+	"setup.cfg":         true,
+	"pom.xml":           true,
+	"build.gradle":      true,
+	"build.gradle.kts":  true,
+	"gradle.properties": true,
+	"chart.yaml":        true,
+	"pubspec.yaml":      true,
+}
+
+var (
+	bumpDate    = regexp.MustCompile(`\b\d{4}-\d{2}-\d{2}\b`)
+	bumpVersion = regexp.MustCompile(`\bv?\d+(\.\d+){1,3}([-+][0-9A-Za-z.-]+)?\b`)
+)
+
+// bumpKind classifies a file by name.
+func bumpKind(relPath string) int {
+	name := strings.ToLower(path.Base(toSlash(relPath)))
+	base := strings.TrimSuffix(name, path.Ext(name))
+	switch {
+	case base == "changelog" || base == "changes" || base == "history" || base == "news" || base == "releases":
+		return bumpChangelog
+	case base == "version" || name == ".version":
+		return bumpVersionFile
+	case versionManifests[name] || path.Ext(name) == ".gemspec" || path.Ext(name) == ".csproj" || path.Ext(name) == ".nuspec":
+		return bumpManifest
+	}
+	return bumpNone
+}
+
+// maskVersions replaces version numbers and dates with a placeholder.
+func maskVersions(line string) string {
+	line = bumpDate.ReplaceAllString(line, "\x00")
+	return bumpVersion.ReplaceAllString(line, "\x00")
+}
+
+// versionBumpOnly reports whether a pair of changelog, version or manifest
+// files differs only as routine releases make them differ: changelog
+// entries added or removed, and version numbers or release dates changed
+// in a version file or in the version field of a package manifest.
+func (run *runState) versionBumpOnly(pair filePair) bool {
+	kind := bumpKind(pair.SourcePath)
+	if kind == bumpNone {
+		return false
+	}
+	source, target, err := run.readPair(pair, run.config.NormalizeCmd)
+	if err != nil || isBinary(source) || isBinary(target) {
+		return false
+	}
+
+	dmp := diffmatchpatch.New()
+	chars1, chars2, linePatches := dmp.DiffLinesToChars(string(source), string(target))
+	diffs := dmp.DiffCharsToLines(dmp.DiffMain(chars1, chars2, false), linePatches)
+
+	var removed, inserted []string
+	hunkIsBump := func() bool {
+		defer func() { removed, inserted = nil, nil }()
+		if kind == bumpChangelog && (len(removed) == 0 || len(inserted) == 0) {
+			return true
+		}
+		if len(removed) != len(inserted) {
+			return false
+		}
+		for i := range removed {
+			if maskVersions(removed[i]) != maskVersions(inserted[i]) {
+				return false
+			}
+			if kind == bumpManifest && !strings.Contains(strings.ToLower(removed[i]), "version") {
+				return false
+			}
+		}
+		return true
+	}
+	for _, diff := range diffs {
+		lines := strings.SplitAfter(diff.Text, "\n")
+		if lines[len(lines)-1] == "" {
+			lines = lines[:len(lines)-1]
+		}
+		switch diff.Type {
+		case diffmatchpatch.DiffDelete:
+			removed = append(removed, lines...)
+		case diffmatchpatch.DiffInsert:
+			inserted = append(inserted, lines...)
+		case diffmatchpatch.DiffEqual:
+			if !hunkIsBump() {
+				return false
+			}
+		}
+	}
+	return hunkIsBump()
+}
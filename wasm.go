@@ -0,0 +1,77 @@
+//go:build js && wasm
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"syscall/js"
+
+	"github.com/spf13/viper"
+)
+
+// main exposes the comparison to JavaScript instead of running the command
+// line. gitparatorCompare(source, target, options) compares two zip
+// archives given as Uint8Arrays; options is an optional JSON string of
+// config file settings. It returns an object holding the result as JSON
+// and the HTML report, or an error message.
+func main() {
+	js.Global().Set("gitparatorCompare", js.FuncOf(compareJS))
+	select {}
+}
+
+func compareJS(this js.Value, args []js.Value) (response any) {
+	defer func() {
+		if r := recover(); r != nil {
+			response = map[string]any{"error": fmt.Sprint(r)}
+		}
+	}()
+	fail := func(err error) any { return map[string]any{"error": err.Error()} }
+	if len(args) < 2 {
+		return fail(fmt.Errorf("gitparatorCompare expects a source and a target archive"))
+	}
+	source := make([]byte, args[0].Length())
+	js.CopyBytesToGo(source, args[0])
+	target := make([]byte, args[1].Length())
+	js.CopyBytesToGo(target, args[1])
+	var options string
+	if len(args) > 2 && args[2].Type() == js.TypeString {
+		options = args[2].String()
+	}
+
+	config, err := wasmConfig(options)
+	if err != nil {
+		return fail(err)
+	}
+	result, err := compareArchiveBytes(source, target, &config)
+	if err != nil {
+		return fail(err)
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		return fail(err)
+	}
+	var html strings.Builder
+	if err := renderHTMLReport(&html, result, &config); err != nil {
+		return fail(err)
+	}
+	return map[string]any{"result": string(data), "html": html.String()}
+}
+
+// wasmConfig starts from the defaults of the command line flags and
+// applies the given settings.
+func wasmConfig(options string) (Config, error) {
+	var config Config
+	newRootCommand(&config)
+	if err := viper.Unmarshal(&config); err != nil {
+		return config, err
+	}
+	config.Progress = false
+	if options != "" {
+		if err := json.Unmarshal([]byte(options), &config); err != nil {
+			return config, fmt.Errorf("invalid options: %w", err)
+		}
+	}
+	return config, nil
+}
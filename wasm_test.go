@@ -0,0 +1,55 @@
+//go:build js && wasm
+
+package main
+
+import (
+	"os"
+	"strings"
+	"syscall/js"
+	"testing"
+
+	"github.com/adnsv/gitparator/testsupport"
+)
+
+// jsBytes copies content into a new Uint8Array.
+func jsBytes(content []byte) js.Value {
+	a := js.Global().Get("Uint8Array").New(len(content))
+	js.CopyBytesToJS(a, content)
+	return a
+}
+
+func TestCompareJSErrors(t *testing.T) {
+	archive := func(files testsupport.Files) []byte {
+		content, err := os.ReadFile(testsupport.Zip(t, "", files))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return content
+	}
+	source := archive(sourceFiles)
+	bomb := archive(testsupport.Files{"zeros.bin": strings.Repeat("\x00", 4<<20)})
+
+	tests := []struct {
+		name    string
+		target  []byte
+		options string
+		want    string
+	}{
+		{"corrupt zip", []byte("not a zip archive"), "", "error reading target archive"},
+		{"archive limits", bomb, `{"archive_limits": {"max_ratio": 100}}`, "archive_limits.max_ratio"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response := compareJS(js.Undefined(), []js.Value{jsBytes(source), jsBytes(tt.target), js.ValueOf(tt.options)})
+			got, _ := response.(map[string]any)["error"].(string)
+			if !strings.Contains(got, tt.want) {
+				t.Errorf("error = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	response := compareJS(js.Undefined(), []js.Value{jsBytes(source), jsBytes(archive(targetFiles))})
+	if _, ok := response.(map[string]any)["result"]; !ok {
+		t.Errorf("comparing valid archives: %v", response)
+	}
+}
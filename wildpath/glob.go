@@ -0,0 +1,88 @@
+package wildpath
+
+import (
+	"errors"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Glob returns the names of the files in fsys matching pattern, sorted.
+// Leading slashes are ignored, as fsys has a single root. Only directories
+// that can contain matches are read, so a pattern such as "src/*.go" does
+// not walk the whole file system. It fails if the pattern is malformed or
+// a directory cannot be read.
+func Glob(fsys fs.FS, pattern string) ([]string, error) {
+	p, err := Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	var matches []string
+	for _, alt := range p.alternatives {
+		// Alternatives are matched as relative paths below the root
+		alt.hasRoot = false
+		root := literalPrefix(alt.parts)
+		err := fs.WalkDir(fsys, root, func(name string, d fs.DirEntry, err error) error {
+			if err != nil {
+				// A missing literal prefix has no matches
+				if name == root && errors.Is(err, fs.ErrNotExist) {
+					return fs.SkipDir
+				}
+				return err
+			}
+			if d.IsDir() {
+				if name != "." && !matchDirPrefix(alt.parts, strings.Split(name, "/"), p.fold) {
+					return fs.SkipDir
+				}
+				return nil
+			}
+			if !seen[name] && matchParts(alt.parts, strings.Split(name, "/"), 0, 0, p.fold) {
+				seen[name] = true
+				matches = append(matches, name)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.Strings(matches)
+	return matches, nil
+}
+
+// literalPrefix returns the directory named by the leading components of a
+// pattern that contain no special characters, or ".".
+func literalPrefix(parts []string) string {
+	var literal []string
+	for _, part := range parts[:max(len(parts)-1, 0)] {
+		if strings.ContainsAny(part, `*?[{\`) {
+			break
+		}
+		literal = append(literal, part)
+	}
+	if len(literal) == 0 {
+		return "."
+	}
+	return path.Join(literal...)
+}
+
+// matchDirPrefix reports whether paths below the directory dir may match
+// the pattern components.
+func matchDirPrefix(pattern, dir []string, fold bool) bool {
+	for i, part := range dir {
+		if i >= len(pattern) {
+			return false
+		}
+		if pattern[i] == "**" {
+			return true
+		}
+		if !matchSinglePart(pattern[i], part, fold) {
+			return false
+		}
+	}
+	// Files of dir match only if components remain for them
+	return len(dir) < len(pattern)
+}
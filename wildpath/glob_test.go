@@ -0,0 +1,95 @@
+package wildpath
+
+import (
+	"io/fs"
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func TestGlob(t *testing.T) {
+	fsys := fstest.MapFS{
+		"README.md":             {},
+		"go.mod":                {},
+		"src/main.go":           {},
+		"src/util.go":           {},
+		"src/util_test.go":      {},
+		"src/pkg/a/a.go":        {},
+		"src/pkg/b/b.go":        {},
+		"src/pkg/b/b.txt":       {},
+		"docs/guide.md":         {},
+		"docs/api/index.md":     {},
+		"v1/file.txt":           {},
+		"v2/file.txt":           {},
+		"v3/file.txt":           {},
+		"node_modules/x/x.go":   {},
+		"[id]/page.tsx":         {},
+		"src/pkg/a/sub/deep.go": {},
+	}
+
+	tests := []struct {
+		name    string
+		pattern string
+		want    []string
+	}{
+		{"literal", "go.mod", []string{"go.mod"}},
+		{"star in dir", "src/*.go", []string{"src/main.go", "src/util.go", "src/util_test.go"}},
+		{"globstar", "src/**/*.go", []string{"src/main.go", "src/pkg/a/a.go", "src/pkg/a/sub/deep.go", "src/pkg/b/b.go", "src/util.go", "src/util_test.go"}},
+		{"leading globstar", "**/*.md", []string{"README.md", "docs/api/index.md", "docs/guide.md"}},
+		{"trailing globstar", "docs/**", []string{"docs/api/index.md", "docs/guide.md"}},
+		{"braces", "src/pkg/{a,b}/*.go", []string{"src/pkg/a/a.go", "src/pkg/b/b.go"}},
+		{"overlapping braces", "{src,src/pkg/a}/**/a.go", []string{"src/pkg/a/a.go"}},
+		{"sequence", "v{1..2}/*.txt", []string{"v1/file.txt", "v2/file.txt"}},
+		{"escaped", `\[id\]/*.tsx`, []string{"[id]/page.tsx"}},
+		{"root relative", "/src/main.go", []string{"src/main.go"}},
+		{"missing directory", "missing/*.go", nil},
+		{"directories are not matched", "src/pkg/*", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Glob(fsys, tt.pattern)
+			if err != nil {
+				t.Fatalf("Glob(%q) failed: %v", tt.pattern, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Glob(%q) = %v, want %v", tt.pattern, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGlobPrunes(t *testing.T) {
+	fsys := fstest.MapFS{
+		"src/main.go":    {},
+		"vendor/x/x.go":  {},
+		"node_modules/y": {},
+	}
+	var opened []string
+	recording := openRecorder{fsys, &opened}
+	if _, err := Glob(recording, "src/*.go"); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range opened {
+		if name != "src" && name != "src/main.go" {
+			t.Errorf("Glob(%q) read %s", "src/*.go", name)
+		}
+	}
+}
+
+func TestGlobMalformed(t *testing.T) {
+	if _, err := Glob(fstest.MapFS{}, "src/[ab.go"); err == nil {
+		t.Error("Glob of an unclosed bracket succeeded")
+	}
+}
+
+// openRecorder records the names opened in a file system.
+type openRecorder struct {
+	fs.FS
+	opened *[]string
+}
+
+func (r openRecorder) Open(name string) (fs.File, error) {
+	*r.opened = append(*r.opened, name)
+	return r.FS.Open(name)
+}
@@ -1,7 +1,11 @@
 package wildpath
 
 import (
+	"fmt"
+	"math"
+	"strconv"
 	"strings"
+	"unicode"
 )
 
 // Match checks if the given filename matches the pattern.
@@ -25,70 +29,367 @@ import (
 //   - [!abc] or [^abc] matches any character not in brackets
 //   - {js,ts} matches any of the comma-separated patterns
 //   - Leading / makes the pattern root-relative
+//
+// Matching is case-sensitive unless IgnoreCase is set.
 func Match(pattern, filename string) bool {
-	// Handle brace expansion
-	if strings.Contains(pattern, "{") {
-		patterns := expandBraces(pattern)
-		for _, p := range patterns {
-			if matchSinglePattern(p, filename) {
-				return true
-			}
-		}
-		return false
+	return compile(pattern, IgnoreCase).Match(filename)
+}
+
+// MatchFold is like Match but matches case-insensitively, as on the
+// case-insensitive file systems of macOS and Windows.
+func MatchFold(pattern, filename string) bool {
+	return compile(pattern, true).Match(filename)
+}
+
+// IgnoreCase makes Match and Compile match case-insensitively, for all
+// callers of the package. Set it before matching starts.
+var IgnoreCase bool
+
+// Pattern is a parsed pattern that matches paths without parsing the
+// pattern again. Use it for patterns matched against many paths.
+type Pattern struct {
+	pattern      string
+	alternatives []alternative
+	fold         bool
+}
+
+// alternative is one pattern of a brace expansion, split into path
+// components.
+type alternative struct {
+	parts   []string
+	hasRoot bool
+}
+
+// Error describes a malformed pattern.
+type Error struct {
+	Pattern string
+	Offset  int // byte offset of the offending character
+	Msg     string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("wildpath: %s at offset %d in %q", e.Msg, e.Offset, e.Pattern)
+}
+
+// MaxExpansions is the most patterns a brace expansion may yield, so a typo
+// like "v{1..100000000}" cannot exhaust memory.
+const MaxExpansions = 4096
+
+// Compile parses a pattern into a Pattern. Unlike Match, which treats
+// malformed patterns leniently, it fails on unclosed brackets and braces,
+// on empty character classes and on brace groups expanding to more than
+// MaxExpansions patterns. The pattern matches case-insensitively if
+// IgnoreCase is set when it is compiled.
+func Compile(pattern string) (*Pattern, error) {
+	if err := validate(pattern); err != nil {
+		return nil, err
 	}
-	return matchSinglePattern(pattern, filename)
+	return compile(pattern, IgnoreCase), nil
 }
 
-// expandBraces expands patterns like "*.{js,ts}" into []string{"*.js", "*.ts"}
-func expandBraces(pattern string) []string {
-	start := strings.Index(pattern, "{")
-	if start == -1 {
-		return []string{pattern}
+// CompileFold is like Compile but the pattern always matches
+// case-insensitively.
+func CompileFold(pattern string) (*Pattern, error) {
+	if err := validate(pattern); err != nil {
+		return nil, err
+	}
+	return compile(pattern, true), nil
+}
+
+// MustCompile is like Compile but panics if the pattern is malformed.
+func MustCompile(pattern string) *Pattern {
+	p, err := Compile(pattern)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+func compile(pattern string, fold bool) *Pattern {
+	p := &Pattern{pattern: pattern, fold: fold}
+	expanded := []string{pattern}
+	// Matching leniently, braces expanding too far are literals
+	if strings.Contains(pattern, "{") && countExpansions(pattern) <= MaxExpansions {
+		expanded = expandBraces(pattern)
 	}
+	for _, e := range expanded {
+		parts, hasRoot := normalize(e)
+		p.alternatives = append(p.alternatives, alternative{parts, hasRoot})
+	}
+	return p
+}
+
+// Match checks if the given filename matches the pattern.
+func (p *Pattern) Match(filename string) bool {
+	filenameParts, filenameHasRoot := normalize(filename)
+	for _, alt := range p.alternatives {
+		// If pattern is root-relative, the file path must also be root-relative
+		if alt.hasRoot == filenameHasRoot && matchParts(alt.parts, filenameParts, 0, 0, p.fold) {
+			return true
+		}
+	}
+	return false
+}
 
-	end := strings.Index(pattern[start:], "}")
-	if end == -1 {
-		return []string{pattern} // unclosed brace, treat as literal
+// String returns the source text of the pattern.
+func (p *Pattern) String() string {
+	return p.pattern
+}
+
+// validate reports the first malformed bracket or brace of a pattern.
+func validate(pattern string) error {
+	var braces []int // offsets of the open braces
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '\\':
+			i++ // the escaped character is a literal
+		case '[':
+			// Character classes end within their path component
+			end := indexUnescaped(pattern[i+1:], "]/")
+			if end == -1 || pattern[i+1+end] == '/' {
+				return &Error{Pattern: pattern, Offset: i, Msg: "unclosed bracket"}
+			}
+			class := pattern[i+1 : i+1+end]
+			if class == "" || class == "!" || class == "^" {
+				return &Error{Pattern: pattern, Offset: i, Msg: "empty character class"}
+			}
+			i += end + 1
+		case '{':
+			braces = append(braces, i)
+		case '}':
+			if len(braces) > 0 {
+				braces = braces[:len(braces)-1]
+			}
+		}
+	}
+	if len(braces) > 0 {
+		return &Error{Pattern: pattern, Offset: braces[0], Msg: "unclosed brace"}
 	}
-	end += start
+	if countExpansions(pattern) > MaxExpansions {
+		start, _ := findBraceGroup(pattern)
+		return &Error{Pattern: pattern, Offset: start, Msg: fmt.Sprintf("brace expansion exceeds %d patterns", MaxExpansions)}
+	}
+	return nil
+}
 
-	// Get content between braces
-	content := pattern[start+1 : end]
+// indexUnescaped returns the index of the first character of s in chars
+// that is not escaped with a backslash, or -1.
+func indexUnescaped(s, chars string) int {
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' {
+			i++
+		} else if strings.IndexByte(chars, s[i]) >= 0 {
+			return i
+		}
+	}
+	return -1
+}
 
-	// Empty braces or no comma - treat as literal
-	if content == "" || !strings.Contains(content, ",") {
+// expandBraces expands patterns like "*.{js,ts}" into []string{"*.js", "*.ts"}.
+// Every group is expanded, including groups nested in alternatives, so
+// "{a,{b,c}}.{x,y}" yields six patterns. Sequences such as "{1..3}" and
+// "{a..c}" expand as in bash. Other groups without a comma and unclosed
+// braces are literals, as are escaped braces and commas, which are kept
+// escaped.
+func expandBraces(pattern string) []string {
+	start, end := findBraceGroup(pattern)
+	if start == -1 {
 		return []string{pattern}
 	}
 
 	prefix := pattern[:start]
 	suffix := pattern[end+1:]
-	alternatives := strings.Split(content, ",")
+	var alternatives []string
+	if seq, ok := parseSequence(pattern[start+1 : end]); ok {
+		alternatives = seq.values()
+	} else {
+		alternatives = splitAlternatives(pattern[start+1 : end])
+	}
 
 	var results []string
-	// Recursively handle nested braces in suffix
+	// Recursively handle the braces in alternatives and suffix
 	suffixExpanded := expandBraces(suffix)
 
 	for _, alt := range alternatives {
-		for _, suffixPattern := range suffixExpanded {
-			results = append(results, prefix+alt+suffixPattern)
+		for _, altPattern := range expandBraces(alt) {
+			for _, suffixPattern := range suffixExpanded {
+				results = append(results, prefix+altPattern+suffixPattern)
+			}
 		}
 	}
 
 	return results
 }
 
-func matchSinglePattern(pattern, filename string) bool {
-	// Normalize paths by removing consecutive slashes
-	// Keep track if pattern starts with slash (root-relative)
-	patternParts, patternHasRoot := normalize(pattern)
-	filenameParts, filenameHasRoot := normalize(filename)
+// countExpansions returns the number of patterns expandBraces yields for
+// pattern without expanding it, or MaxExpansions+1 for any larger number.
+func countExpansions(pattern string) int {
+	start, end := findBraceGroup(pattern)
+	if start == -1 {
+		return 1
+	}
+	alternatives := 0
+	if seq, ok := parseSequence(pattern[start+1 : end]); ok {
+		alternatives = min(seq.len(), MaxExpansions+1)
+	} else {
+		for _, alt := range splitAlternatives(pattern[start+1 : end]) {
+			alternatives = min(alternatives+countExpansions(alt), MaxExpansions+1)
+		}
+	}
+	suffix := countExpansions(pattern[end+1:])
+	if alternatives > (MaxExpansions+1)/suffix {
+		return MaxExpansions + 1
+	}
+	return min(alternatives*suffix, MaxExpansions+1)
+}
 
-	// If pattern is root-relative, the file path must also be root-relative
-	if patternHasRoot != filenameHasRoot {
-		return false
+// findBraceGroup returns the positions of the braces of the first group
+// with alternatives, or -1. Literal groups are searched for nested groups.
+func findBraceGroup(pattern string) (start, end int) {
+	for i := 0; i < len(pattern); i++ {
+		if pattern[i] == '\\' {
+			i++
+			continue
+		}
+		if pattern[i] != '{' {
+			continue
+		}
+		if end := matchingBrace(pattern, i); end != -1 {
+			content := pattern[i+1 : end]
+			if _, ok := parseSequence(content); ok || len(splitAlternatives(content)) > 1 {
+				return i, end
+			}
+		}
+	}
+	return -1, -1
+}
+
+// matchingBrace returns the position of the brace closing the one at
+// start, or -1 if it is unclosed.
+func matchingBrace(pattern string, start int) int {
+	depth := 0
+	for i := start; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '\\':
+			i++
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// braceSequence is a brace group of the form "x..y" or "x..y..step",
+// where x and y are both integers or both single letters.
+type braceSequence struct {
+	first, last, step int
+	width             int // of integers written with leading zeros, or 0
+	letters           bool
+}
+
+// parseSequence parses the contents of a brace group as a sequence.
+// Integers written with leading zeros are padded to the same width.
+func parseSequence(content string) (braceSequence, bool) {
+	parts := strings.Split(content, "..")
+	if len(parts) != 2 && len(parts) != 3 {
+		return braceSequence{}, false
+	}
+	seq := braceSequence{step: 1}
+	if len(parts) == 3 {
+		var err error
+		if seq.step, err = strconv.Atoi(parts[2]); err != nil {
+			return braceSequence{}, false
+		}
+		if seq.step < 0 {
+			seq.step = -seq.step
+		}
+		if seq.step == 0 {
+			seq.step = 1
+		}
+	}
+
+	if first, err := strconv.Atoi(parts[0]); err == nil {
+		last, err := strconv.Atoi(parts[1])
+		if err != nil {
+			return braceSequence{}, false
+		}
+		seq.first, seq.last = first, last
+		if padded(parts[0]) || padded(parts[1]) {
+			seq.width = max(len(parts[0]), len(parts[1]))
+		}
+		return seq, true
 	}
 
-	return matchParts(patternParts, filenameParts, 0, 0)
+	first, last := []rune(parts[0]), []rune(parts[1])
+	if len(first) != 1 || len(last) != 1 || !unicode.IsLetter(first[0]) || !unicode.IsLetter(last[0]) {
+		return braceSequence{}, false
+	}
+	seq.first, seq.last, seq.letters = int(first[0]), int(last[0]), true
+	return seq, true
+}
+
+// len returns the number of values of the sequence.
+func (seq braceSequence) len() int {
+	low, high := min(seq.first, seq.last), max(seq.first, seq.last)
+	n := (uint64(high)-uint64(low))/uint64(seq.step) + 1
+	if n == 0 || n > math.MaxInt {
+		return math.MaxInt // the whole range of int
+	}
+	return int(n)
+}
+
+// values expands the sequence, counting downwards if last is smaller.
+func (seq braceSequence) values() []string {
+	direction := 1
+	if seq.last < seq.first {
+		direction = -1
+	}
+	results := make([]string, seq.len())
+	for i := range results {
+		n := seq.first + i*seq.step*direction
+		if seq.letters {
+			results[i] = string(rune(n))
+		} else {
+			results[i] = fmt.Sprintf("%0*d", seq.width, n)
+		}
+	}
+	return results
+}
+
+// padded reports whether an integer is written with leading zeros.
+func padded(n string) bool {
+	n = strings.TrimPrefix(n, "-")
+	return len(n) > 1 && n[0] == '0'
+}
+
+// splitAlternatives splits the contents of a brace group at the commas
+// outside nested groups.
+func splitAlternatives(content string) []string {
+	var alternatives []string
+	depth, last := 0, 0
+	for i := 0; i < len(content); i++ {
+		switch content[i] {
+		case '\\':
+			i++
+		case '{':
+			depth++
+		case '}':
+			if depth > 0 {
+				depth--
+			}
+		case ',':
+			if depth == 0 {
+				alternatives = append(alternatives, content[last:i])
+				last = i + 1
+			}
+		}
+	}
+	return append(alternatives, content[last:])
 }
 
 func normalize(s string) ([]string, bool) {
@@ -106,7 +407,7 @@ func normalize(s string) ([]string, bool) {
 	return result, hasRoot
 }
 
-func matchParts(pattern, filename []string, patternIdx, filenameIdx int) bool {
+func matchParts(pattern, filename []string, patternIdx, filenameIdx int, fold bool) bool {
 	for patternIdx < len(pattern) {
 		// If we've consumed all filename parts
 		if filenameIdx == len(filename) {
@@ -128,7 +429,7 @@ func matchParts(pattern, filename []string, patternIdx, filenameIdx int) bool {
 
 			// Try matching rest of pattern at current position and every subsequent position
 			for i := filenameIdx; i <= len(filename); i++ {
-				if matchParts(pattern, filename, nextPattern, i) {
+				if matchParts(pattern, filename, nextPattern, i, fold) {
 					return true
 				}
 			}
@@ -137,7 +438,7 @@ func matchParts(pattern, filename []string, patternIdx, filenameIdx int) bool {
 
 		// If we have filename parts to match
 		if filenameIdx < len(filename) {
-			if !matchSinglePart(pattern[patternIdx], filename[filenameIdx]) {
+			if !matchSinglePart(pattern[patternIdx], filename[filenameIdx], fold) {
 				return false
 			}
 			patternIdx++
@@ -152,8 +453,11 @@ func matchParts(pattern, filename []string, patternIdx, filenameIdx int) bool {
 	return filenameIdx == len(filename)
 }
 
-func matchSinglePart(pattern, str string) bool {
-	if pattern == "*" || pattern == str {
+func matchSinglePart(pattern, str string, fold bool) bool {
+	if pattern == "*" {
+		return true
+	}
+	if !strings.ContainsRune(pattern, '\\') && (pattern == str || (fold && strings.EqualFold(pattern, str))) {
 		return true
 	}
 
@@ -165,11 +469,16 @@ func matchSinglePart(pattern, str string) bool {
 	starMatch := 0
 
 	for j < len(s) {
+		// A backslash makes the next character a literal
+		escaped := i+1 < len(p) && p[i] == '\\'
 		if i < len(p) && (p[i] == '*') {
 			starIdx = i
 			starMatch = j
 			i++
-		} else if i < len(p) && (p[i] == '?' || p[i] == s[j]) {
+		} else if escaped && equalRune(p[i+1], s[j], fold) {
+			i += 2
+			j++
+		} else if i < len(p) && !escaped && (p[i] == '?' || equalRune(p[i], s[j], fold)) {
 			i++
 			j++
 		} else if i < len(p) && p[i] == '[' {
@@ -177,7 +486,7 @@ func matchSinglePart(pattern, str string) bool {
 			if closeIdx == -1 {
 				return false
 			}
-			if matchCharacterRange(p[i+1:i+closeIdx], s[j]) {
+			if matchCharacterRange(p[i+1:i+closeIdx], s[j], fold) {
 				i += closeIdx + 1
 				j++
 			} else {
@@ -207,14 +516,32 @@ func matchSinglePart(pattern, str string) bool {
 
 func findClosingBracket(pattern []rune) int {
 	for i := 1; i < len(pattern); i++ {
-		if pattern[i] == ']' {
+		if pattern[i] == '\\' {
+			i++
+		} else if pattern[i] == ']' {
 			return i
 		}
 	}
 	return -1
 }
 
-func matchCharacterRange(rangePattern []rune, char rune) bool {
+// equalRune compares two runes, ignoring case if fold is set.
+func equalRune(a, b rune, fold bool) bool {
+	if a == b {
+		return true
+	}
+	if !fold {
+		return false
+	}
+	for r := unicode.SimpleFold(a); r != a; r = unicode.SimpleFold(r) {
+		if r == b {
+			return true
+		}
+	}
+	return false
+}
+
+func matchCharacterRange(rangePattern []rune, char rune, fold bool) bool {
 	if len(rangePattern) == 0 {
 		return false
 	}
@@ -226,23 +553,35 @@ func matchCharacterRange(rangePattern []rune, char rune) bool {
 		startIdx = 1
 	}
 
-	matched := false
-	for i := startIdx; i < len(rangePattern); i++ {
-		if i+2 < len(rangePattern) && rangePattern[i+1] == '-' {
-			start := rangePattern[i]
-			end := rangePattern[i+2]
-			if char >= start && char <= end {
-				matched = true
-				break
-			}
-			i += 2
-		} else {
-			if rangePattern[i] == char {
-				matched = true
-				break
-			}
+	matched := inCharacterRange(rangePattern[startIdx:], char)
+	if fold {
+		// Any case of the character may fall into the range
+		for r := unicode.SimpleFold(char); !matched && r != char; r = unicode.SimpleFold(r) {
+			matched = inCharacterRange(rangePattern[startIdx:], r)
 		}
 	}
 
 	return matched != isNegated
 }
+
+func inCharacterRange(rangePattern []rune, char rune) bool {
+	// unescaped returns the character at i, skipping a backslash before it
+	unescaped := func(i int) (rune, int) {
+		if rangePattern[i] == '\\' && i+1 < len(rangePattern) {
+			i++
+		}
+		return rangePattern[i], i
+	}
+	for i := 0; i < len(rangePattern); i++ {
+		var start, end rune
+		start, i = unescaped(i)
+		end = start
+		if i+2 < len(rangePattern) && rangePattern[i+1] == '-' {
+			end, i = unescaped(i + 2)
+		}
+		if char >= start && char <= end {
+			return true
+		}
+	}
+	return false
+}
@@ -74,8 +74,17 @@ func TestMatch(t *testing.T) {
 		{"empty pattern", "", "", true},
 		{"empty pattern no match", "", "file.txt", false},
 		{"pattern with spaces", "* *.txt", "a b.txt", true},
-		{"unclosed range", "[a-z.txt", "[a-z.txt", true},     // treated as literal
-		{"escaped range", "\\[a-z].txt", "[a-z].txt", false}, // we don't support escaping
+		{"unclosed range", "[a-z.txt", "[a-z.txt", true}, // treated as literal
+		{"escaped range", "\\[a-z].txt", "[a-z].txt", true},
+		{"escaped range no match", "\\[a-z].txt", "a.txt", false},
+		{"escaped star", "file\\*.txt", "file*.txt", true},
+		{"escaped star no match", "file\\*.txt", "file1.txt", false},
+		{"escaped question", "what\\?.txt", "what?.txt", true},
+		{"escaped question no match", "what\\?.txt", "whats.txt", false},
+		{"escaped brace", "\\{js,ts}.txt", "{js,ts}.txt", true},
+		{"escaped backslash", "a\\\\b", "a\\b", true},
+		{"escaped bracket in class", "file[\\]x].txt", "file].txt", true},
+		{"escaped letter", "\\file.txt", "file.txt", true},
 		{"multiple stars", "**.txt", "file.txt", true},
 		{"mixed slashes", "dir/*/file.txt", "dir\\sub\\file.txt", false}, // strict slash matching
 
@@ -167,6 +176,31 @@ func TestExpandBraces(t *testing.T) {
 		{"just opening", "{", []string{"{"}},
 		{"just closing", "}", []string{"}"}},
 		{"no braces", "file.js", []string{"file.js"}},
+		{"escaped opening", "file.\\{js,ts}", []string{"file.\\{js,ts}"}},
+		{"escaped closing", "file.{js,ts\\}", []string{"file.{js,ts\\}"}},
+		{"escaped comma", "{a\\,b,c}", []string{"a\\,b", "c"}},
+
+		// Multiple and nested groups
+		{"two groups", "{a,b}.{x,y}", []string{"a.x", "a.y", "b.x", "b.y"}},
+		{"literal group before group", "{x}.{a,b}", []string{"{x}.a", "{x}.b"}},
+		{"nested", "{a,{b,c}}", []string{"a", "b", "c"}},
+		{"nested with path", "src/{cmd,pkg/{a,b}}/*.go", []string{"src/cmd/*.go", "src/pkg/a/*.go", "src/pkg/b/*.go"}},
+		{"nested in literal group", "{{a,b}}", []string{"{a}", "{b}"}},
+		{"unclosed outer", "{a,{b,c}", []string{"{a,b", "{a,c"}},
+		{"nested and suffix", "{a,{b,c}}.{x,y}", []string{"a.x", "a.y", "b.x", "b.y", "c.x", "c.y"}},
+
+		// Sequences
+		{"numeric sequence", "v{1..3}", []string{"v1", "v2", "v3"}},
+		{"descending sequence", "{3..1}", []string{"3", "2", "1"}},
+		{"negative sequence", "{-1..1}", []string{"-1", "0", "1"}},
+		{"padded sequence", "{08..10}", []string{"08", "09", "10"}},
+		{"sequence with step", "{1..10..4}", []string{"1", "5", "9"}},
+		{"character sequence", "{a..c}", []string{"a", "b", "c"}},
+		{"descending characters", "{c..a}", []string{"c", "b", "a"}},
+		{"sequence in alternative", "{x,{1..2}}", []string{"x", "1", "2"}},
+		{"mixed sequence", "{a..3}", []string{"{a..3}"}},
+		{"long bounds", "{ab..cd}", []string{"{ab..cd}"}},
+		{"incomplete sequence", "{1..}", []string{"{1..}"}},
 
 		// Valid expansions with empty alternatives
 		{"empty alternatives", "file.{,ts}", []string{"file.", "file.ts"}},
@@ -212,6 +246,14 @@ func TestMatchWithBraces(t *testing.T) {
 		{"braces with globstar", "**/*.{js,ts}", "dir/test.ts", true},
 		{"empty alternative", "file.{,js}", "file.", true},
 		{"empty alternative 2", "file.{,js}", "file.js", true},
+		{"nested braces", "src/{cmd,pkg/{a,b}}/**/*.go", "src/pkg/b/x/y.go", true},
+		{"nested braces outer", "src/{cmd,pkg/{a,b}}/**/*.go", "src/cmd/main.go", true},
+		{"nested braces no match", "src/{cmd,pkg/{a,b}}/**/*.go", "src/pkg/c/y.go", false},
+		{"multiple groups", "{src,lib}/*.{js,ts}", "lib/index.ts", true},
+		{"numeric sequence", "v{1..3}/**", "v2/pkg/file.go", true},
+		{"numeric sequence no match", "v{1..3}/**", "v4/pkg/file.go", false},
+		{"character sequence", "part-{a..f}.bin", "part-c.bin", true},
+		{"character sequence no match", "part-{a..f}.bin", "part-g.bin", false},
 	}
 
 	for _, tt := range tests {
@@ -224,3 +266,133 @@ func TestMatchWithBraces(t *testing.T) {
 		})
 	}
 }
+
+func TestCompile(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		path    string
+		want    bool
+	}{
+		{"literal", "file.txt", "file.txt", true},
+		{"globstar", "src/**/*.go", "src/pkg/main.go", true},
+		{"range", "[a-z]*.txt", "test.txt", true},
+		{"braces", "lib/*.{js,ts}", "lib/utils.ts", true},
+		{"braces no match", "lib/*.{js,ts}", "lib/utils.go", false},
+		{"root relative", "/root/*.txt", "/root/file.txt", true},
+		{"root relative no match", "/root/*.txt", "root/file.txt", false},
+		{"literal braces", "file.{}", "file.{}", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := Compile(tt.pattern)
+			if err != nil {
+				t.Fatalf("Compile(%q) failed: %v", tt.pattern, err)
+			}
+			if got := p.Match(tt.path); got != tt.want {
+				t.Errorf("Compile(%q).Match(%q) = %v, want %v",
+					tt.pattern, tt.path, got, tt.want)
+			}
+			if got := Match(tt.pattern, tt.path); got != tt.want {
+				t.Errorf("Match(%q, %q) = %v, want %v",
+					tt.pattern, tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		offset  int
+	}{
+		{"unclosed bracket", "file[ab.txt", 4},
+		{"bracket closed in next component", "dir[a/b]", 3},
+		{"empty class", "file[].txt", 4},
+		{"empty negated class", "file[!].txt", 4},
+		{"unclosed brace", "file.{js,ts", 5},
+		{"brace after closed brace", "{a,b}.{c", 6},
+		{"escaped closing bracket", "file[a\\].txt", 4},
+		{"escaped closing brace", "{a,b\\}", 0},
+		{"unclosed outer brace", "{a,{b,c}", 0},
+		{"huge sequence", "v{1..100000000}/**", 1},
+		{"whole int range", "{-9223372036854775808..9223372036854775807}", 0},
+		{"multiplied groups", "src/{a,b}/{1..100}/{1..100}", 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Compile(tt.pattern)
+			perr, ok := err.(*Error)
+			if !ok {
+				t.Fatalf("Compile(%q) error = %v, want *Error", tt.pattern, err)
+			}
+			if perr.Offset != tt.offset {
+				t.Errorf("Compile(%q) error offset = %d, want %d", tt.pattern, perr.Offset, tt.offset)
+			}
+		})
+	}
+}
+
+func TestExpansionLimit(t *testing.T) {
+	if _, err := Compile("{1..64}{1..64}"); err != nil {
+		t.Errorf("Compile of %d expansions failed: %v", MaxExpansions, err)
+	}
+	if got := countExpansions("{a,{b,c}}.{1..3}"); got != 9 {
+		t.Errorf("countExpansions = %d, want 9", got)
+	}
+	// Match takes patterns expanding too far literally
+	if !Match("v{1..100000000}", "v{1..100000000}") || Match("v{1..100000000}", "v1") {
+		t.Error("Match expanded a pattern over the limit")
+	}
+}
+
+func TestMatchFold(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		path    string
+		want    bool
+	}{
+		{"literal", "README.md", "readme.md", true},
+		{"star", "*.TXT", "notes.txt", true},
+		{"question", "FILE?.txt", "file1.TXT", true},
+		{"globstar", "SRC/**/*.go", "src/pkg/Main.GO", true},
+		{"range", "[A-C]*.txt", "beta.txt", true},
+		{"range lowercase", "[a-c]*.txt", "Beta.txt", true},
+		{"negated range", "[!a-c]*.txt", "Beta.txt", false},
+		{"braces", "*.{JS,TS}", "module.ts", true},
+		{"non-ASCII", "ÄRGER.txt", "ärger.txt", true},
+		{"different name", "*.txt", "file.exe", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MatchFold(tt.pattern, tt.path); got != tt.want {
+				t.Errorf("MatchFold(%q, %q) = %v, want %v",
+					tt.pattern, tt.path, got, tt.want)
+			}
+			p, err := CompileFold(tt.pattern)
+			if err != nil {
+				t.Fatalf("CompileFold(%q) failed: %v", tt.pattern, err)
+			}
+			if got := p.Match(tt.path); got != tt.want {
+				t.Errorf("CompileFold(%q).Match(%q) = %v, want %v",
+					tt.pattern, tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIgnoreCase(t *testing.T) {
+	if Match("*.TXT", "notes.txt") {
+		t.Errorf("Match is case-insensitive by default")
+	}
+	IgnoreCase = true
+	defer func() { IgnoreCase = false }()
+	if !Match("*.TXT", "notes.txt") {
+		t.Errorf("Match is case-sensitive with IgnoreCase set")
+	}
+}
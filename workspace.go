@@ -0,0 +1,169 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/adnsv/gitparator/wildpath"
+	"golang.org/x/mod/modfile"
+	"gopkg.in/yaml.v3"
+)
+
+// WorkspacePackage summarizes the drift of one package of a monorepo, as
+// listed by go.work or pnpm-workspace.yaml. A package passes when it has
+// no differing, source-only or target-only files.
+type WorkspacePackage struct {
+	Name           string `json:"name"`
+	Path           string `json:"path"` // relative to the compared tree; "." for its root
+	Identical      int    `json:"identical"`
+	Different      int    `json:"different"`
+	FormattingOnly int    `json:"formatting_only"`
+	VersionBumps   int    `json:"version_bumps"`
+	Recompressed   int    `json:"recompressed"`
+	SourceOnly     int    `json:"source_only"`
+	TargetOnly     int    `json:"target_only"`
+	Passed         bool   `json:"passed"`
+}
+
+// findWorkspacePackages lists the packages of the workspace files at root:
+// the modules used by go.work and the package.json directories matched by
+// pnpm-workspace.yaml. It fails if root has neither file.
+func findWorkspacePackages(root string) ([]WorkspacePackage, error) {
+	var packages []WorkspacePackage
+	found := false
+
+	if data, err := os.ReadFile(filepath.Join(root, "go.work")); err == nil {
+		found = true
+		work, err := modfile.ParseWork("go.work", data, nil)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing go.work: %w", err)
+		}
+		for _, use := range work.Use {
+			dir := path.Clean(toSlash(use.Path))
+			name := dir
+			if mod, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(dir), "go.mod")); err == nil {
+				if modulePath := modfile.ModulePath(mod); modulePath != "" {
+					name = modulePath
+				}
+			}
+			packages = append(packages, WorkspacePackage{Name: name, Path: dir})
+		}
+	}
+
+	if data, err := os.ReadFile(filepath.Join(root, "pnpm-workspace.yaml")); err == nil {
+		found = true
+		var workspace struct {
+			Packages []string `yaml:"packages"`
+		}
+		if err := yaml.Unmarshal(data, &workspace); err != nil {
+			return nil, fmt.Errorf("error parsing pnpm-workspace.yaml: %w", err)
+		}
+		dirs, err := pnpmPackageDirs(root, workspace.Packages)
+		if err != nil {
+			return nil, err
+		}
+		for _, dir := range dirs {
+			packages = append(packages, WorkspacePackage{Name: npmPackageName(root, dir), Path: dir})
+		}
+	}
+
+	if !found {
+		return nil, fmt.Errorf("%s has no go.work or pnpm-workspace.yaml", root)
+	}
+	sort.Slice(packages, func(i, j int) bool { return packages[i].Path < packages[j].Path })
+	return packages, nil
+}
+
+// pnpmPackageDirs returns the directories with a package.json matched by
+// the patterns of pnpm-workspace.yaml, less those matched by negated
+// patterns.
+func pnpmPackageDirs(root string, patterns []string) ([]string, error) {
+	fsys := os.DirFS(root)
+	matched := make(map[string]bool)
+	var excluded []string
+	for _, pattern := range patterns {
+		pattern = strings.TrimPrefix(path.Clean(pattern), "./")
+		if strings.HasPrefix(pattern, "!") {
+			excluded = append(excluded, strings.TrimPrefix(pattern[1:], "./"))
+			continue
+		}
+		manifests, err := wildpath.Glob(fsys, path.Join(pattern, "package.json"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid pnpm-workspace.yaml pattern %q: %w", pattern, err)
+		}
+		for _, manifest := range manifests {
+			matched[path.Dir(manifest)] = true
+		}
+	}
+
+	var dirs []string
+	for dir := range matched {
+		if !shouldExclude(dir, excluded) {
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs, nil
+}
+
+// npmPackageName returns the name in the package.json of dir, or dir.
+func npmPackageName(root, dir string) string {
+	data, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(dir), "package.json"))
+	if err != nil {
+		return dir
+	}
+	var pkg struct {
+		Name string `json:"name"`
+	}
+	if json.Unmarshal(data, &pkg) != nil || pkg.Name == "" {
+		return dir
+	}
+	return pkg.Name
+}
+
+// summarizePackages counts the outcomes of the files of each package.
+// Files are attributed to the innermost package containing them; files
+// outside every package are not counted.
+func summarizePackages(packages []WorkspacePackage, result *ComparisonResult) []WorkspacePackage {
+	// Inner packages come after the packages containing them
+	byDepth := make([]int, len(packages))
+	for i := range byDepth {
+		byDepth[i] = i
+	}
+	sort.SliceStable(byDepth, func(a, b int) bool {
+		return len(packages[byDepth[a]].Path) > len(packages[byDepth[b]].Path)
+	})
+	owner := func(file string) *WorkspacePackage {
+		for _, i := range byDepth {
+			dir := packages[i].Path
+			if dir == "." || strings.HasPrefix(file, dir+"/") {
+				return &packages[i]
+			}
+		}
+		return nil
+	}
+	count := func(files []string, field func(*WorkspacePackage) *int) {
+		for _, file := range files {
+			if p := owner(file); p != nil {
+				*field(p)++
+			}
+		}
+	}
+	count(result.IdenticalFiles, func(p *WorkspacePackage) *int { return &p.Identical })
+	count(result.DifferentFiles, func(p *WorkspacePackage) *int { return &p.Different })
+	count(result.FormattingOnlyFiles, func(p *WorkspacePackage) *int { return &p.FormattingOnly })
+	count(result.VersionBumpFiles, func(p *WorkspacePackage) *int { return &p.VersionBumps })
+	count(result.RecompressedFiles, func(p *WorkspacePackage) *int { return &p.Recompressed })
+	count(result.SourceOnlyFiles, func(p *WorkspacePackage) *int { return &p.SourceOnly })
+	count(result.TargetOnlyFiles, func(p *WorkspacePackage) *int { return &p.TargetOnly })
+
+	for i := range packages {
+		p := &packages[i]
+		p.Passed = p.Different == 0 && p.SourceOnly == 0 && p.TargetOnly == 0
+	}
+	return packages
+}